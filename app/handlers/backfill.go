@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/cmcd97/bytesize/app/components"
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/cmcd97/bytesize/lib"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+func toBackfillJob(job *models.Record) types.BackfillJob {
+	result := types.BackfillJob{
+		Status:    job.GetString("status"),
+		Total:     job.GetInt("total"),
+		Completed: job.GetInt("completed"),
+	}
+	if err := job.UnmarshalJSONField("failedGameweeks", &result.FailedGameweeks); err != nil {
+		log.Printf("Error decoding failed gameweeks for backfill %s: %v", job.Id, err)
+	}
+	if lib.BackfillStale(job) {
+		result.Status = lib.BackfillFailed
+	}
+	return result
+}
+
+func latestBackfillJob(pb *pocketbase.PocketBase, userID string) (*models.Record, error) {
+	jobs, err := pb.Dao().FindRecordsByFilter(
+		lib.BackfillJobsCollection,
+		"userID = {:userID}",
+		"-created",
+		1,
+		0,
+		dbx.Params{"userID": userID},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+	return jobs[0], nil
+}
+
+// BackfillStatus is polled by the onboarding page while the user's history
+// imports. It sends them on to their profile once every week is in.
+func BackfillStatus(c echo.Context) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	job, err := latestBackfillJob(pb, record.Id)
+	if err != nil {
+		log.Printf("Error fetching backfill job: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+	if job == nil {
+		return lib.HtmxRedirect(c, "/app/profile")
+	}
+
+	status := toBackfillJob(job)
+	if status.Status == lib.BackfillDone && len(status.FailedGameweeks) == 0 {
+		return lib.HtmxRedirect(c, "/app/profile")
+	}
+
+	return lib.Render(c, http.StatusOK, components.BackfillProgress(status))
+}
+
+// BackfillRetry re-runs the weeks the last import couldn't fetch, or the
+// whole import if it never got as far as learning which weeks to fetch.
+func BackfillRetry(c echo.Context) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	job, err := latestBackfillJob(pb, record.Id)
+	if err != nil {
+		log.Printf("Error fetching backfill job: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+	if job == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Nothing to retry")
+	}
+
+	status := toBackfillJob(job)
+	if status.Status == lib.BackfillRunning {
+		return lib.Render(c, http.StatusOK, components.BackfillProgress(status))
+	}
+
+	retry, err := lib.StartBackfill(pb, record.Id, job.GetInt("teamID"), status.FailedGameweeks)
+	if err != nil {
+		log.Printf("Error restarting backfill: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to restart import")
+	}
+
+	return lib.Render(c, http.StatusOK, components.BackfillProgress(toBackfillJob(retry)))
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/cmcd97/bytesize/app/components"
+	"github.com/cmcd97/bytesize/lib"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// DisciplineGet shows the signed in manager why they're suspended or how
+// close they are: their open cards, the gameweek a suspension would apply to,
+// the fines that would avoid it and what their XI is picking up in the
+// gameweek being played.
+func DisciplineGet(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), leaguesTimeout)
+	defer cancel()
+
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	status, err := lib.GetDisciplinaryStatus(ctx, pb.Dao(), record.Id, record.GetInt("teamID"))
+	if err != nil {
+		log.Printf("Error loading disciplinary status for %s: %v", record.Id, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	return lib.Render(c, http.StatusOK, components.DisciplinaryStatus(status))
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/cmcd97/bytesize/lib"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// parseExportRequest reads the dataset, format and gameweek range of an export
+// from the query string. The range defaults to the whole season.
+func parseExportRequest(c echo.Context, leagueID int) (lib.ExportRequest, error) {
+	request := lib.NewExportRequest(leagueID, c.QueryParam("dataset"), c.QueryParam("format"))
+	if request.Format == "" {
+		request.Format = lib.ExportCSV
+	}
+
+	for param, gameweek := range map[string]*int{"from": &request.FromGameweek, "to": &request.ToGameweek} {
+		value := c.QueryParam(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return request, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%s must be a gameweek number", param))
+		}
+		*gameweek = parsed
+	}
+
+	if err := request.Validate(); err != nil {
+		return request, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return request, nil
+}
+
+// LeagueExportGet downloads the cards, standings, fines or nominations of the
+// viewer's default league as CSV or JSON. Only its admins can export it. The
+// rows are streamed as they're read, so once the download has started an
+// error can only cut it short.
+func LeagueExportGet(c echo.Context) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	var request lib.ExportRequest
+	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		defaultLeague, err := getDefaultLeague(txDao, record.Get("teamID"))
+		if err != nil {
+			return fmt.Errorf("default league not found: %w", err)
+		}
+		leagueID := defaultLeague.GetInt("leagueID")
+
+		settings, err := getLeagueSettings(txDao, leagueID)
+		if err != nil {
+			return fmt.Errorf("league settings not found: %w", err)
+		}
+		if !isLeagueAdmin(settings, record.Id) {
+			return echo.NewHTTPError(http.StatusForbidden, "Only league admins can export the league's data")
+		}
+
+		request, err = parseExportRequest(c, leagueID)
+		return err
+	})
+	if err != nil {
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		log.Printf("Transaction failed: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	response := c.Response()
+	response.Header().Set(echo.HeaderContentType, request.ContentType())
+	response.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", request.Filename()))
+	response.WriteHeader(http.StatusOK)
+
+	if err := lib.WriteLeagueExport(pb.Dao(), response, request); err != nil {
+		log.Printf("Export of league %d %s failed: %v", request.LeagueID, request.Dataset, err)
+	}
+	return nil
+}
@@ -0,0 +1,513 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/cmcd97/bytesize/app/views"
+	"github.com/cmcd97/bytesize/lib"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	pbtypes "github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	fineLedgerEntryLimit = 50
+	maxFineNoteLength    = 200
+)
+
+// addMissingMembers gives every current member of the league a balance, so
+// members who haven't been fined yet are listed too
+func addMissingMembers(txDao *daos.Dao, leagueID int, balances []types.FineBalance) ([]types.FineBalance, error) {
+	var members []struct {
+		UserID    string `db:"userID"`
+		FirstName string `db:"firstName"`
+		LastName  string `db:"lastName"`
+	}
+	err := txDao.DB().
+		Select("l.userID", "u.firstName", "u.lastName").
+		From("leagues l").
+		InnerJoin("users u", dbx.NewExp("u.id = l.userID")).
+		Where(dbx.NewExp("l.leagueID = {:leagueID} AND l.hasLeft = FALSE", dbx.Params{"leagueID": leagueID})).
+		OrderBy("u.firstName asc").
+		All(&members)
+	if err != nil {
+		return nil, fmt.Errorf("fetch members: %w", err)
+	}
+
+	listed := make(map[string]bool, len(balances))
+	for _, balance := range balances {
+		listed[balance.UserID] = true
+	}
+	for _, member := range members {
+		if !listed[member.UserID] {
+			balances = append(balances, types.FineBalance{UserID: member.UserID, Name: lib.ManagerName(member.FirstName, member.LastName)})
+		}
+	}
+	return balances, nil
+}
+
+// chargeLeagueFines charges for cards members hand out themselves, such as
+// nominations and reverses, straight away rather than at the ETL's next sync.
+// Leagues without settings don't have fines.
+func chargeLeagueFines(txDao *daos.Dao, leagueID int) error {
+	settings, err := txDao.FindFirstRecordByFilter(
+		leagueSettingsCollection,
+		"leagueID = {:leagueID}",
+		dbx.Params{"leagueID": leagueID},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("find league settings: %w", err)
+	}
+	return lib.SyncFineCharges(txDao, settings)
+}
+
+func loadFineLedgerPage(txDao *daos.Dao, record *models.Record) (types.FineLedgerPage, error) {
+	var page types.FineLedgerPage
+
+	defaultLeague, err := getDefaultLeague(txDao, record.Get("teamID"))
+	if err != nil {
+		return page, fmt.Errorf("default league not found: %w", err)
+	}
+	leagueID := defaultLeague.GetInt("leagueID")
+
+	settings, err := getLeagueSettings(txDao, leagueID)
+	if err != nil {
+		return page, fmt.Errorf("league settings not found: %w", err)
+	}
+	leagueSettings := readLeagueSettings(settings, defaultLeague.GetString("leagueName"))
+	page.LeagueName = leagueSettings.DisplayName
+	page.Currency = leagueSettings.FineCurrency
+	page.Enabled = leagueSettings.FinesEnabled
+	page.ViewerIsAdmin = isLeagueAdmin(settings, record.Id)
+
+	season := lib.CurrentSeasonStartYear(txDao)
+	page.SeasonName = lib.SeasonName(season)
+
+	balances, totals, err := lib.GetFineBalances(txDao, leagueID, season)
+	if err != nil {
+		return page, err
+	}
+	page.Balances, err = addMissingMembers(txDao, leagueID, balances)
+	if err != nil {
+		return page, err
+	}
+	page.Totals = totals
+
+	page.Pot, err = lib.FinePot(txDao, leagueID, season)
+	if err != nil {
+		return page, err
+	}
+	page.Settlements, err = lib.GetFineSettlements(txDao, leagueID, season)
+	if err != nil {
+		return page, err
+	}
+
+	var entries []struct {
+		Kind         string           `db:"kind"`
+		FirstName    string           `db:"firstName"`
+		LastName     string           `db:"lastName"`
+		CardType     string           `db:"cardType"`
+		Note         string           `db:"note"`
+		Amount       int              `db:"amount"`
+		Gameweek     int              `db:"gameweek"`
+		Cancelled    bool             `db:"cancelled"`
+		SettlementID string           `db:"settlementID"`
+		Created      pbtypes.DateTime `db:"created"`
+	}
+	err = txDao.DB().
+		Select(
+			"f.kind",
+			"COALESCE(u.firstName, '') as firstName",
+			"COALESCE(u.lastName, '') as lastName",
+			"f.cardType",
+			"f.note",
+			"f.amount",
+			"f.gameweek",
+			"f.cancelled",
+			"f.settlementID",
+			"f.created").
+		From(lib.FineLedgerCollection+" f").
+		LeftJoin("users u", dbx.NewExp("u.id = f.userID")).
+		Where(dbx.HashExp{"f.leagueID": leagueID, "f.seasonStartYear": season}).
+		OrderBy("f.created desc").
+		Limit(fineLedgerEntryLimit).
+		All(&entries)
+	if err != nil {
+		return page, fmt.Errorf("fetch ledger: %w", err)
+	}
+	for _, entry := range entries {
+		description := entry.Note
+		if entry.Kind == lib.FineCharge {
+			description = fmt.Sprintf("%s, GW%d", lib.ReplaceUnderscoresWithSpaces(entry.CardType), entry.Gameweek)
+		}
+		page.Entries = append(page.Entries, types.FineLedgerEntry{
+			Kind:        entry.Kind,
+			Name:        lib.ManagerName(entry.FirstName, entry.LastName),
+			Description: description,
+			Amount:      entry.Amount,
+			Gameweek:    entry.Gameweek,
+			Cancelled:   entry.Cancelled,
+			Settled:     entry.SettlementID != "",
+			Created:     entry.Created.Time(),
+		})
+	}
+
+	return page, nil
+}
+
+// FineLedgerGet shows what each member of the viewer's default league has
+// been charged, paid and had waived this season, the pot waiting to be paid
+// out and the latest ledger entries.
+func FineLedgerGet(c echo.Context) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	var page types.FineLedgerPage
+	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		var err error
+		page, err = loadFineLedgerPage(txDao, record)
+		return err
+	})
+	if err != nil {
+		log.Printf("Transaction failed: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	return lib.Render(c, http.StatusOK, views.FineLedger(page))
+}
+
+// FineEntryPost records a payment a member made towards their fines, or an
+// amount an admin let them off. Only admins can add to the ledger.
+func FineEntryPost(c echo.Context) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	userID := c.FormValue("userID")
+	kind := c.FormValue("kind")
+	if kind != lib.FinePayment && kind != lib.FineWaiver {
+		return echo.NewHTTPError(http.StatusBadRequest, "Choose whether this is a payment or a waiver")
+	}
+	amount, err := parseFineAmount(c.FormValue("amount"))
+	if err != nil {
+		return err
+	}
+	if amount == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Enter an amount")
+	}
+	note := strings.TrimSpace(c.FormValue("note"))
+	if len(note) > maxFineNoteLength {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Notes can't be longer than %d characters", maxFineNoteLength))
+	}
+	if note == "" {
+		note = "Payment"
+		if kind == lib.FineWaiver {
+			note = "Waived"
+		}
+	}
+
+	err = pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		defaultLeague, err := getDefaultLeague(txDao, record.Get("teamID"))
+		if err != nil {
+			return fmt.Errorf("default league not found: %w", err)
+		}
+		leagueID := defaultLeague.GetInt("leagueID")
+
+		settings, err := getLeagueSettings(txDao, leagueID)
+		if err != nil {
+			return fmt.Errorf("league settings not found: %w", err)
+		}
+		if !isLeagueAdmin(settings, record.Id) {
+			return echo.NewHTTPError(http.StatusForbidden, "Only league admins can update the fine ledger")
+		}
+		if !isLeagueMember(txDao, leagueID, userID) {
+			return echo.NewHTTPError(http.StatusBadRequest, "That manager isn't in this league")
+		}
+		// bring the charges up to date before taking a payment against them
+		if err := lib.SyncFineCharges(txDao, settings); err != nil {
+			return err
+		}
+
+		collection, err := txDao.FindCollectionByNameOrId(lib.FineLedgerCollection)
+		if err != nil {
+			return fmt.Errorf("find collection: %w", err)
+		}
+		entry := models.NewRecord(collection)
+		entry.Set("leagueID", leagueID)
+		entry.Set("seasonStartYear", lib.CurrentSeasonStartYear(txDao))
+		entry.Set("userID", userID)
+		entry.Set("kind", kind)
+		entry.Set("amount", amount)
+		entry.Set("note", note)
+		entry.Set("createdBy", record.Id)
+		entry.Set("gameweek", currentGameweek(txDao))
+		if err := txDao.SaveRecord(entry); err != nil {
+			return fmt.Errorf("save ledger entry: %w", err)
+		}
+
+		err = lib.WriteAuditLog(txDao, record.Id, "fine_"+kind+"_recorded", userID, map[string]any{
+			"leagueID": leagueID,
+			"amount":   amount,
+			"currency": readLeagueSettings(settings, "").FineCurrency,
+			"note":     note,
+		})
+		if err != nil {
+			return err
+		}
+		return recordAdminActivity(txDao, settings)
+	})
+	if err != nil {
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		log.Printf("Transaction failed: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	return FineLedgerGet(c)
+}
+
+// FineSettlementPost marks the pot paid out: every payment collected this
+// season that isn't in a settlement yet goes into a new one.
+func FineSettlementPost(c echo.Context) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	note := strings.TrimSpace(c.FormValue("note"))
+	if len(note) > maxFineNoteLength {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Notes can't be longer than %d characters", maxFineNoteLength))
+	}
+
+	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		defaultLeague, err := getDefaultLeague(txDao, record.Get("teamID"))
+		if err != nil {
+			return fmt.Errorf("default league not found: %w", err)
+		}
+		leagueID := defaultLeague.GetInt("leagueID")
+
+		settings, err := getLeagueSettings(txDao, leagueID)
+		if err != nil {
+			return fmt.Errorf("league settings not found: %w", err)
+		}
+		if !isLeagueAdmin(settings, record.Id) {
+			return echo.NewHTTPError(http.StatusForbidden, "Only league admins can pay out the pot")
+		}
+
+		season := lib.CurrentSeasonStartYear(txDao)
+		payments, err := txDao.FindRecordsByFilter(lib.FineLedgerCollection,
+			"leagueID = {:leagueID} && seasonStartYear = {:season} && kind = {:kind} && settlementID = ''", "", 0, 0,
+			dbx.Params{"leagueID": leagueID, "season": season, "kind": lib.FinePayment})
+		if err != nil {
+			return fmt.Errorf("fetch payments: %w", err)
+		}
+		if len(payments) == 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "There's nothing in the pot to pay out")
+		}
+		amount := 0
+		for _, payment := range payments {
+			amount += payment.GetInt("amount")
+		}
+
+		collection, err := txDao.FindCollectionByNameOrId(lib.FineSettlementsCollection)
+		if err != nil {
+			return fmt.Errorf("find collection: %w", err)
+		}
+		currency := readLeagueSettings(settings, "").FineCurrency
+		settlement := models.NewRecord(collection)
+		settlement.Set("leagueID", leagueID)
+		settlement.Set("seasonStartYear", season)
+		settlement.Set("amount", amount)
+		settlement.Set("currency", currency)
+		settlement.Set("payments", len(payments))
+		settlement.Set("note", note)
+		settlement.Set("settledBy", record.Id)
+		if err := txDao.SaveRecord(settlement); err != nil {
+			return fmt.Errorf("save settlement: %w", err)
+		}
+		for _, payment := range payments {
+			payment.Set("settlementID", settlement.Id)
+			if err := txDao.SaveRecord(payment); err != nil {
+				return fmt.Errorf("save payment: %w", err)
+			}
+		}
+		log.Printf("User %s paid out %s from league %d", record.Id, lib.FormatMoney(amount, currency), leagueID)
+
+		err = lib.WriteAuditLog(txDao, record.Id, "fine_pot_settled", "", map[string]any{
+			"leagueID":     leagueID,
+			"settlementID": settlement.Id,
+			"amount":       amount,
+			"currency":     currency,
+			"payments":     len(payments),
+		})
+		if err != nil {
+			return err
+		}
+		return recordAdminActivity(txDao, settings)
+	})
+	if err != nil {
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		log.Printf("Transaction failed: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	return FineLedgerGet(c)
+}
+
+// FineReportGet totals a season of the viewer's league's fines: each member's
+// charges, payments, waivers and what they still owe, the charges by card
+// type and how much of the pot has been paid out. It's the current season
+// unless another is picked.
+func FineReportGet(c echo.Context) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	selected := 0
+	if season := c.QueryParam("season"); season != "" {
+		year, err := strconv.Atoi(season)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "season must be the year it started")
+		}
+		selected = year
+	}
+
+	var page types.FineReportPage
+	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		defaultLeague, err := getDefaultLeague(txDao, record.Get("teamID"))
+		if err != nil {
+			return fmt.Errorf("default league not found: %w", err)
+		}
+		leagueID := defaultLeague.GetInt("leagueID")
+		page.LeagueName = leagueDisplayName(txDao, defaultLeague)
+
+		settings, err := getLeagueSettings(txDao, leagueID)
+		if err != nil {
+			return fmt.Errorf("league settings not found: %w", err)
+		}
+		page.Currency = readLeagueSettings(settings, "").FineCurrency
+
+		current := lib.CurrentSeasonStartYear(txDao)
+		if selected == 0 {
+			selected = current
+		}
+		var seasons []struct {
+			StartYear int `db:"seasonStartYear"`
+		}
+		err = txDao.DB().
+			Select("seasonStartYear").
+			Distinct(true).
+			From(lib.FineLedgerCollection).
+			Where(dbx.HashExp{"leagueID": leagueID}).
+			All(&seasons)
+		if err != nil {
+			return fmt.Errorf("fetch seasons: %w", err)
+		}
+		years := []int{current}
+		for _, season := range seasons {
+			if season.StartYear != current {
+				years = append(years, season.StartYear)
+			}
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(years)))
+		for _, year := range years {
+			page.Seasons = append(page.Seasons, types.SeasonOption{
+				StartYear: year,
+				Name:      lib.SeasonName(year),
+				Selected:  year == selected,
+			})
+		}
+		page.SeasonName = lib.SeasonName(selected)
+
+		page.Balances, page.Totals, err = lib.GetFineBalances(txDao, leagueID, selected)
+		if err != nil {
+			return err
+		}
+		page.Settlements, err = lib.GetFineSettlements(txDao, leagueID, selected)
+		if err != nil {
+			return err
+		}
+		for _, settlement := range page.Settlements {
+			page.Settled += settlement.Amount
+		}
+		page.Unsettled = page.Totals.Paid - page.Settled
+
+		var byType []struct {
+			Type    string `db:"cardType"`
+			Cards   int    `db:"cards"`
+			Charged int    `db:"charged"`
+		}
+		err = txDao.DB().
+			Select("cardType", "COUNT(*) as cards", "SUM(amount) as charged").
+			From(lib.FineLedgerCollection).
+			Where(dbx.HashExp{"leagueID": leagueID, "seasonStartYear": selected, "kind": lib.FineCharge, "cancelled": false}).
+			GroupBy("cardType").
+			OrderBy("charged desc").
+			All(&byType)
+		if err != nil {
+			return fmt.Errorf("fetch charges by type: %w", err)
+		}
+		for _, row := range byType {
+			page.ByType = append(page.ByType, types.FineTypeTotal{Type: row.Type, Cards: row.Cards, Charged: row.Charged})
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Transaction failed: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	return lib.Render(c, http.StatusOK, views.FineReport(page))
+}
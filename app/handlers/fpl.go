@@ -1,22 +1,27 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/cmcd97/bytesize/app/components"
 	"github.com/cmcd97/bytesize/app/types"
 	"github.com/cmcd97/bytesize/lib"
 	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/daos"
 	"github.com/pocketbase/pocketbase/models"
+	pbtypes "github.com/pocketbase/pocketbase/tools/types"
 )
 
 func FetchFplTeam(c echo.Context) error {
@@ -25,7 +30,7 @@ func FetchFplTeam(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "teamID is required")
 	}
 
-	teamURL := fmt.Sprintf("https://fantasy.premierleague.com/api/entry/%s/", teamID)
+	teamURL := fmt.Sprintf("%s/entry/%s/", lib.FPLAPIBase, teamID)
 	log.Printf("Fetching team data from: %s", teamURL)
 
 	resp, err := http.Get(teamURL)
@@ -59,167 +64,131 @@ func FetchFplTeam(c echo.Context) error {
 	leagueData := leagueResponse.Leagues
 	// Access classic leagues
 	classicLeagues := leagueData.Classic
-	// fmt.Println(classicLeagues)
 	teamIDInt, err := strconv.Atoi(teamID)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to convert teamID to int: %v", err))
 	}
-	// add user leagues to user struct
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Failed to get auth record for request: %v", c.Request().RequestURI)
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthorized")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Error: PocketBase instance is nil or type assertion failed")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection error")
+	}
+
+	// add user leagues to user struct, as leagues of the current season
+	seasonStartYear := lib.CurrentSeasonStartYear(pb.Dao())
 	userCustomLeagues := []types.FPLUserLeague{}
 	for _, league := range classicLeagues {
 		if league.LeagueType == "s" {
 			continue
 		}
-		// Parse date string and get year
-		dateStr := league.Created
-		t, err := time.Parse(time.RFC3339Nano, dateStr)
-		if err != nil {
-			// handle error
-		}
-		year := t.Year()
 		userCustomLeagues = append(userCustomLeagues, types.FPLUserLeague{
 			LeagueID:        league.LeagueID,
 			AdminUserID:     "temp",
 			UserTeamID:      teamIDInt,
-			LeagueName:      lib.ReplaceSpacesWithUnderscores(league.Name),
-			SeasonStartYear: year,
+			LeagueName:      league.Name,
+			SeasonStartYear: seasonStartYear,
 			UserID:          "temp",
 			IsLinked:        false,
 			IsActive:        false,
 		})
 	}
 
-	//store userCustomLeagues in a temporary cookie that expires in 5 minutes
-	cookie := new(http.Cookie)
-	cookie.Name = "userCustomLeagues"
-	cookie.Value = fmt.Sprintf("%v", userCustomLeagues)
-	cookie.Expires = time.Now().Add(5 * time.Minute)
-	c.SetCookie(cookie)
-
-	return lib.Render(c, http.StatusOK, components.TeamCheck(teamID, teamData))
-}
-
-func convertToJSON(input string) (string, error) {
-	// Log input
-	log.Printf("Converting to JSON, input: %s", input)
-
-	if input == "" {
-		return "", fmt.Errorf("empty input string")
-	}
-
-	// Remove outer brackets and split into items
-	input = strings.Trim(input, "[]")
-	items := strings.Split(input, "} {")
-
-	log.Printf("Split into %d items", len(items))
-	var jsonObjects []string
-
-	for i, item := range items {
-		// Clean up each item
-		item = strings.Trim(item, "{}")
-		fields := strings.Fields(item)
-
-		// Validate fields length
-		if len(fields) != 8 {
-			return "", fmt.Errorf("item %d has %d fields, expected 8: %v", i, len(fields), fields)
-		}
-
-		// Log fields for debugging
-		log.Printf("Processing item %d: %v", i, fields)
-
-		// Map to struct fields in order
-		jsonObj := fmt.Sprintf(`{
-            "LeagueID": %s,
-            "AdminUserID": "%s",
-            "UserTeamID": %s,
-            "LeagueName": "%s",
-            "SeasonStartYear": %s,
-            "UserID": "%s",
-            "IsLinked": %s,
-            "IsActive": %s
-        }`, fields[0], fields[1], fields[2], fields[3], fields[4], fields[5], fields[6], fields[7])
-
-		// Validate JSON format
-		var js map[string]interface{}
-		if err := json.Unmarshal([]byte(jsonObj), &js); err != nil {
-			return "", fmt.Errorf("invalid JSON format for item %d: %v", i, err)
+	// Skip the team name challenge if a league admin has vouched for this team
+	code := ""
+	if !hasOwnershipOverride(pb.Dao(), teamIDInt, record.Id) {
+		code, err = ownershipCode(pb.Dao(), record.Id, teamIDInt)
+		if err != nil {
+			log.Printf("Error creating ownership code: %v", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save team details")
 		}
-
-		jsonObjects = append(jsonObjects, jsonObj)
 	}
 
-	result := "[" + strings.Join(jsonObjects, ",") + "]"
-	log.Printf("Conversion completed, result length: %d", len(result))
+	draft := types.OnboardingDraft{
+		TeamID:    teamIDInt,
+		FirstName: teamData.PlayerFirstName,
+		LastName:  teamData.PlayerLastName,
+		TeamName:  teamData.Name,
+		Leagues:   userCustomLeagues,
+	}
+	if err := saveOnboardingDraft(pb.Dao(), record.Id, draft); err != nil {
+		log.Printf("Error saving onboarding draft: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save team details")
+	}
 
-	return result, nil
+	return lib.Render(c, http.StatusOK, components.TeamCheck(teamData, userCustomLeagues, code))
 }
 
-func SetTeamID(c echo.Context) error {
-
-	teamID := c.FormValue("teamID")
-	log.Printf("Received teamID form value: %v", teamID)
-
-	firstName := c.FormValue("firstName")
-	log.Printf("Received fisrtName form value: %v", firstName)
-
-	lastName := c.FormValue("lastName")
-	log.Printf("Received lastName form value: %v", lastName)
-
-	teamName := c.FormValue("teamName")
-	log.Printf("Received teamName form value: %v", teamName)
+const (
+	onboardingDraftsCollection = "onboarding_drafts"
+	onboardingDraftExpiration  = 30 * time.Minute
+)
 
-	//get userCustomLeagues from cookie
-	cookie, err := c.Cookie("userCustomLeagues")
-	log.Printf("Received userCustomLeagues cookie")
-	if err != nil {
-		log.Printf("Error getting userCustomLeagues cookie: %v", err)
-		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to get userCustomLeagues cookie: %v", err))
+// saveOnboardingDraft stores what FetchFplTeam found for the user so SetTeamID
+// can import it once they've confirmed. A user has at most one draft.
+func saveOnboardingDraft(dao *daos.Dao, userID string, draft types.OnboardingDraft) error {
+	record, err := dao.FindFirstRecordByFilter(
+		onboardingDraftsCollection,
+		"userID = {:userID}",
+		dbx.Params{"userID": userID},
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("find onboarding draft: %w", err)
 	}
-
-	jsonStr, err := convertToJSON(cookie.Value)
-	if err != nil {
-		log.Printf("Error converting userCustomLeagues cookie value to JSON: %v", err)
-		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to convert userCustomLeagues cookie value to JSON: %v", err))
+	if record == nil {
+		collection, err := dao.FindCollectionByNameOrId(onboardingDraftsCollection)
+		if err != nil {
+			return fmt.Errorf("find collection: %w", err)
+		}
+		record = models.NewRecord(collection)
+		record.Set("userID", userID)
 	}
 
-	log.Printf("Converted userCustomLeagues cookie value to JSON: %v", jsonStr)
-
-	userCustomLeagues := []types.FPLUserLeague{}
-	log.Print("Received userCustomLeagues cookie value")
-	err = json.Unmarshal([]byte(jsonStr), &userCustomLeagues)
+	expires, err := pbtypes.ParseDateTime(time.Now().Add(onboardingDraftExpiration))
 	if err != nil {
-		log.Printf("Error unmarshalling userCustomLeagues: %v", err)
-		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to unmarshal userCustomLeagues: %v", err))
+		return fmt.Errorf("draft expiry: %w", err)
 	}
 
-	if teamID == "" {
-		log.Printf("Error: Empty teamID received")
-		return echo.NewHTTPError(http.StatusBadRequest, "teamID is required")
-	}
+	record.Set("teamID", draft.TeamID)
+	record.Set("firstName", draft.FirstName)
+	record.Set("lastName", draft.LastName)
+	record.Set("teamName", draft.TeamName)
+	record.Set("leagues", draft.Leagues)
+	record.Set("expires", expires)
 
-	if firstName == "" {
-		log.Printf("Error: Empty firstName received")
-		return echo.NewHTTPError(http.StatusBadRequest, "firstName is required")
-	}
+	return dao.SaveRecord(record)
+}
 
-	if lastName == "" {
-		log.Printf("Error: Empty lastName received")
-		return echo.NewHTTPError(http.StatusBadRequest, "lastName is required")
-	}
+// getOnboardingDraft returns the user's unexpired onboarding draft.
+func getOnboardingDraft(dao *daos.Dao, userID string) (*models.Record, types.OnboardingDraft, error) {
+	var draft types.OnboardingDraft
 
-	if teamName == "" {
-		log.Printf("Error: Empty teamName received")
-		return echo.NewHTTPError(http.StatusBadRequest, "teamName is required")
+	record, err := dao.FindFirstRecordByFilter(
+		onboardingDraftsCollection,
+		"userID = {:userID} && expires > @now",
+		dbx.Params{"userID": userID},
+	)
+	if err != nil {
+		return nil, draft, err
 	}
 
-	// Convert teamID string to int
-	teamIDint, err := strconv.Atoi(teamID)
-	if err != nil {
-		log.Printf("Error converting teamID to int: %v", err)
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid team ID format")
+	draft.TeamID = record.GetInt("teamID")
+	draft.FirstName = record.GetString("firstName")
+	draft.LastName = record.GetString("lastName")
+	draft.TeamName = record.GetString("teamName")
+	if err := record.UnmarshalJSONField("leagues", &draft.Leagues); err != nil {
+		return nil, draft, fmt.Errorf("decode draft leagues: %w", err)
 	}
-	log.Printf("Converted teamID to int: %d", teamIDint)
 
+	return record, draft, nil
+}
+
+func SetTeamID(c echo.Context) error {
 	// Get auth record with error handling
 	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
 	if !ok || record == nil {
@@ -230,58 +199,113 @@ func SetTeamID(c echo.Context) error {
 
 	// Get PocketBase instance from context
 	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
-
-	fmt.Println(ok)
 	if !ok || pb == nil {
 		log.Printf("Error: PocketBase instance is nil or type assertion failed")
 		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection error")
 	}
 
-	// Check if teamID is already in use
-	existingRecord, err := pb.Dao().FindFirstRecordByData("users", "teamID", teamIDint)
-	if err != nil && err.Error() != "sql: no rows in result set" {
-		log.Printf("Error checking existing teamID: %v", err)
-		return fmt.Errorf("failed to check team id")
+	draftRecord, draft, err := getOnboardingDraft(pb.Dao(), record.Id)
+	if err != nil {
+		log.Printf("Error getting onboarding draft for user %s: %v", record.Id, err)
+		return echo.NewHTTPError(http.StatusBadRequest, "Your team check has expired, please check your team again")
+	}
+	teamIDint := draft.TeamID
+	log.Printf("Found onboarding draft for team: %d", teamIDint)
+
+	form, err := c.FormValues()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid form")
+	}
+	selectedLeagues := make(map[int]bool)
+	for _, value := range form["leagueIDs"] {
+		if leagueID, err := strconv.Atoi(value); err == nil {
+			selectedLeagues[leagueID] = true
+		}
 	}
-	if existingRecord != nil {
-		log.Printf("TeamID %d is already in use", teamIDint)
-		return echo.NewHTTPError(http.StatusInternalServerError, "team_id already in use")
+
+	// An invite bound to an FPL entry can only be used to link that entry
+	invite := inviteFromCookie(c, pb.Dao())
+	if invite != nil && invite.GetInt("entryID") != 0 && invite.GetInt("entryID") != teamIDint {
+		log.Printf("Invite %s is bound to entry %d, not %d", invite.Id, invite.GetInt("entryID"), teamIDint)
+		return echo.NewHTTPError(http.StatusBadRequest, "This invite is for a different FPL team")
 	}
 
-	// Find and update record
-	record, err = pb.Dao().FindRecordById("users", record.Id)
+	// Check the user controls the team before anything gets linked to it
+	entry, err := fetchFPLEntry(c.Request().Context(), teamIDint)
 	if err != nil {
-		log.Printf("Error finding user record: %v", err)
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to find user record")
-	}
-	log.Printf("Found user record in database: %s", record.Id)
-
-	// Update teamID
-	record.Set("teamID", teamIDint)
-	log.Printf("Setting teamID to: %d for user: %s", teamIDint, record.Id)
-	record.Set("firstName", firstName)
-	log.Printf("Setting firstName to: %s for user: %s", firstName, record.Id)
-	record.Set("lastName", lastName)
-	log.Printf("Setting lastName to: %s for user: %s", lastName, record.Id)
-	record.Set("teamName", teamName)
-	log.Printf("Setting teamName to: %s for user: %s", teamName, record.Id)
-	// record.Set("hasReverse", true)
-	// log.Printf("Setting hasReverse to: %t for user: %s", true, record.Id)  -- everyone starts with no uno
-
-	// Save changes
-	if err := pb.Dao().SaveRecord(record); err != nil {
-		log.Printf("Error saving record: %v", err)
+		log.Printf("Error fetching FPL entry %d: %v", teamIDint, err)
+		return lib.Render(c, http.StatusBadGateway, components.ErrorAlert("Couldn't reach FPL, try again later"))
+	}
+
+	// The claim check, the ownership check and the save share a transaction so
+	// two users can't link the same team, or spend the same override, at once
+	err = pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		existingRecord, err := txDao.FindFirstRecordByData("users", "teamID", teamIDint)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("check team id: %w", err)
+		}
+		if existingRecord != nil {
+			log.Printf("TeamID %d is already in use", teamIDint)
+			return echo.NewHTTPError(http.StatusInternalServerError, "team_id already in use")
+		}
+
+		teamName, err := verifyOwnership(txDao, record.Id, teamIDint, entry.Name)
+		if err != nil {
+			return err
+		}
+
+		// Find and update record
+		record, err = txDao.FindRecordById("users", record.Id)
+		if err != nil {
+			return fmt.Errorf("find user: %w", err)
+		}
+		log.Printf("Found user record in database: %s", record.Id)
+
+		// Update teamID
+		record.Set("teamID", teamIDint)
+		log.Printf("Setting teamID to: %d for user: %s", teamIDint, record.Id)
+		record.Set("firstName", draft.FirstName)
+		log.Printf("Setting firstName to: %s for user: %s", draft.FirstName, record.Id)
+		record.Set("lastName", draft.LastName)
+		log.Printf("Setting lastName to: %s for user: %s", draft.LastName, record.Id)
+		record.Set("teamName", teamName)
+		log.Printf("Setting teamName to: %s for user: %s", teamName, record.Id)
+		// record.Set("hasReverse", true)
+		// log.Printf("Setting hasReverse to: %t for user: %s", true, record.Id)  -- everyone starts with no uno
+
+		// Save changes
+		if err := txDao.SaveRecord(record); err != nil {
+			return fmt.Errorf("save user: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, errOwnershipNotProven) {
+		log.Printf("User %s hasn't proven ownership of team %d", record.Id, teamIDint)
+		return lib.Render(c, http.StatusBadRequest, components.ErrorAlert("We couldn't find the code in your team name yet, it can take a minute to update"))
+	}
+	if err != nil {
+		log.Printf("Error linking team %d: %v", teamIDint, err)
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save team ID")
 	}
 	log.Printf("Successfully updated teamID for user: %s", record.Id)
 
-	// Update userCustomLeagues with user ID
+	// Import the leagues the user picked, always including the one they were invited to
+	if invite != nil {
+		selectedLeagues[invite.GetInt("leagueID")] = true
+	}
 
 	collection, err := pb.Dao().FindCollectionByNameOrId("leagues")
 	if err != nil {
 		return err
 	}
-	for _, league := range userCustomLeagues {
+	for _, league := range draft.Leagues {
+		if !selectedLeagues[league.LeagueID] {
+			continue
+		}
 		league.UserID = record.Id
 		record := models.NewRecord(collection)
 		record.Set("leagueID", league.LeagueID)
@@ -299,106 +323,70 @@ func SetTeamID(c echo.Context) error {
 		log.Printf("Successfully created league record for league: %s", record.Id)
 	}
 
-	allGameweekHistory, err := getTeamGameweekHistory(c, teamIDint)
-	if err != nil {
-		log.Printf("Error fetching gameweek history: %v", err)
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch gameweek history")
+	if err := pb.Dao().DeleteRecord(draftRecord); err != nil {
+		log.Printf("Error deleting onboarding draft: %v", err)
 	}
 
-	// fmt.Println(allGameweekHistory)
+	if invite != nil {
+		code := invite.GetString("code")
+		err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+			return redeemInvite(txDao, code, record.Id, teamIDint)
+		})
+		if err != nil {
+			// The team is linked either way, the league can still be picked by hand
+			log.Printf("Error redeeming invite %s: %v", invite.Id, err)
+		}
+		clearInviteCookie(c)
+	}
 
-	err = writeGameweekHistory(pb, teamIDint, record.Id, allGameweekHistory)
+	// History imports in the background, the page polls for progress
+	job, err := lib.StartBackfill(pb, record.Id, teamIDint, nil)
 	if err != nil {
-		log.Printf("Error writing gameweek history: %v", err)
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to write gameweek history")
+		log.Printf("Error starting gameweek history backfill: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to import gameweek history")
 	}
-	return lib.HtmxRedirect(c, "/app/profile")
+
+	return lib.Render(c, http.StatusOK, components.BackfillProgress(toBackfillJob(job)))
 	// return c.JSON(http.StatusOK, map[string]string{
 	// 	"message": "Team ID updated successfully",
 	// })
 }
 
-func getTeamGameweekHistory(c echo.Context, teamID int) ([]types.GameweekHistory, error) {
-	const (
-		baseEndpoint = "https://fantasy.premierleague.com/api/entry/%d/event/%d/picks/"
-		httpTimeout  = 10 * time.Second
-	)
-
-	client := &http.Client{Timeout: httpTimeout}
-	var allHistory []types.GameweekHistory
-	gameweek := 1
-
-	for {
-		endpoint := fmt.Sprintf(baseEndpoint, teamID, gameweek)
-
-		req, err := http.NewRequestWithContext(c.Request().Context(), http.MethodGet, endpoint, nil)
-		if err != nil {
-			return nil, fmt.Errorf("creating request: %w", err)
-		}
+var errFPLEntryNotFound = errors.New("FPL team not found")
 
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("fetching gameweek %d: %w", gameweek, err)
-		}
-		defer resp.Body.Close()
+// fetchFPLEntry looks up a single FPL entry by team ID.
+func fetchFPLEntry(ctx context.Context, teamID int) (types.FPLUser, error) {
+	var entry types.FPLUser
 
-		// Break loop if we get a 404
-		if resp.StatusCode == http.StatusNotFound {
-			break
-		}
-
-		// Handle other error status codes
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("unexpected status code %d for gameweek %d", resp.StatusCode, gameweek)
-		}
-
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("reading response for gameweek %d: %w", gameweek, err)
-		}
-
-		var history types.GameweekHistory
-		if err := json.Unmarshal(body, &history); err != nil {
-			return nil, fmt.Errorf("parsing gameweek %d: %w", gameweek, err)
-		}
+	client := &http.Client{Timeout: 10 * time.Second}
+	endpoint := fmt.Sprintf("%s/entry/%d/", lib.FPLAPIBase, teamID)
 
-		allHistory = append(allHistory, history)
-		gameweek++
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return entry, fmt.Errorf("creating request: %w", err)
+	}
 
-		// Add small delay to avoid rate limiting
-		time.Sleep(100 * time.Millisecond)
+	resp, err := client.Do(req)
+	if err != nil {
+		return entry, fmt.Errorf("fetching entry %d: %w", teamID, err)
 	}
+	defer resp.Body.Close()
 
-	return allHistory, nil
-}
+	if resp.StatusCode == http.StatusNotFound {
+		return entry, errFPLEntryNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return entry, fmt.Errorf("unexpected status code %d for entry %d", resp.StatusCode, teamID)
+	}
 
-func writeGameweekHistory(pb *pocketbase.PocketBase, teamID int, authUserID string, history []types.GameweekHistory) error {
-	// Get collection
-	collection, err := pb.Dao().FindCollectionByNameOrId("results")
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("finding collection: %w", err)
+		return entry, fmt.Errorf("reading entry %d: %w", teamID, err)
 	}
 
-	// Save records
-	for _, h := range history {
-		record := models.NewRecord(collection)
-		record.Set("gameweek", h.GameweekHistrory.Gameweek)
-		record.Set("userID", authUserID)
-		record.Set("teamID", teamID)
-		record.Set("points", h.GameweekHistrory.Points)
-		record.Set("transfers", h.GameweekHistrory.Transfers)
-		record.Set("hits", h.GameweekHistrory.TransferCost/4)
-		record.Set("benchPoints", h.GameweekHistrory.BenchPoints)
-		record.Set("activeChip", h.ActiveChip)
-		for _, p := range h.Players {
-			positionField := fmt.Sprintf("pos_%d", p.Position)
-			record.Set(positionField, p.PlayerID)
-		}
-
-		if err := pb.Dao().SaveRecord(record); err != nil {
-			return fmt.Errorf("saving record: %w", err)
-		}
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return entry, fmt.Errorf("parsing entry %d: %w", teamID, err)
 	}
 
-	return nil
+	return entry, nil
 }
@@ -0,0 +1,508 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/cmcd97/bytesize/app/views"
+	"github.com/cmcd97/bytesize/lib"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+const (
+	leagueSettingsCollection        = "league_settings"
+	adminNominationsCollection      = "admin_nominations"
+	defaultAdminInactivityGameweeks = 3
+)
+
+// getLeagueSettings returns the settings record for an FPL league. Leagues
+// linked before settings existed only have adminUserID copied onto each
+// member's leagues row, so the first lookup migrates that into a settings
+// record. Returns sql.ErrNoRows if nobody has initialised the league yet.
+func getLeagueSettings(txDao *daos.Dao, leagueID int) (*models.Record, error) {
+	settings, err := txDao.FindFirstRecordByFilter(
+		leagueSettingsCollection,
+		"leagueID = {:leagueID}",
+		dbx.Params{"leagueID": leagueID},
+	)
+	if err == nil {
+		return settings, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("find league settings: %w", err)
+	}
+
+	legacyAdmin, err := txDao.FindFirstRecordByFilter(
+		leaguesCollection,
+		"adminUserID != 'temp' && adminUserID != '' && leagueID = {:leagueID}",
+		dbx.Params{"leagueID": leagueID},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return createLeagueSettings(txDao, leagueID, legacyAdmin.GetString("adminUserID"))
+}
+
+func createLeagueSettings(txDao *daos.Dao, leagueID int, ownerUserID string) (*models.Record, error) {
+	collection, err := txDao.FindCollectionByNameOrId(leagueSettingsCollection)
+	if err != nil {
+		return nil, fmt.Errorf("find collection: %w", err)
+	}
+
+	settings := models.NewRecord(collection)
+	settings.Set("leagueID", leagueID)
+	settings.Set("ownerUserID", ownerUserID)
+	settings.Set("adminUserIDs", []string{ownerUserID})
+	settings.Set("adminInactivityGameweeks", defaultAdminInactivityGameweeks)
+	settings.Set("lastAdminActiveGameweek", currentGameweek(txDao))
+	settings.Set("startGameweek", 1)
+	settings.Set("randomNominationEnabled", true)
+	settings.Set("randomNominationCount", defaultRandomNominationCount)
+
+	if err := txDao.SaveRecord(settings); err != nil {
+		return nil, fmt.Errorf("save league settings: %w", err)
+	}
+	log.Printf("Created league settings for league %d with owner %s", leagueID, ownerUserID)
+
+	return settings, nil
+}
+
+// currentGameweek is getMaxGameweek for callers that can live without one
+// (e.g. before the first gameweek has been aggregated).
+func currentGameweek(txDao *daos.Dao) int {
+	gameweek, err := getMaxGameweek(txDao)
+	if err != nil {
+		return 0
+	}
+	return gameweek
+}
+
+func isLeagueAdmin(settings *models.Record, userID string) bool {
+	return slices.Contains(settings.GetStringSlice("adminUserIDs"), userID)
+}
+
+func isLeagueMember(txDao *daos.Dao, leagueID int, userID string) bool {
+	member, err := txDao.FindFirstRecordByFilter(
+		leaguesCollection,
+		"leagueID = {:leagueID} && userID = {:userID} && hasLeft = false",
+		dbx.Params{"leagueID": leagueID, "userID": userID},
+	)
+	return err == nil && member != nil
+}
+
+func adminsInactive(txDao *daos.Dao, settings *models.Record) bool {
+	inactiveGameweeks := settings.GetInt("adminInactivityGameweeks")
+	if inactiveGameweeks <= 0 {
+		inactiveGameweeks = defaultAdminInactivityGameweeks
+	}
+	return currentGameweek(txDao)-settings.GetInt("lastAdminActiveGameweek") >= inactiveGameweeks
+}
+
+// recordAdminActivity marks the league's admins as active this gameweek and
+// drops any pending replacement nominations.
+func recordAdminActivity(txDao *daos.Dao, settings *models.Record) error {
+	settings.Set("lastAdminActiveGameweek", currentGameweek(txDao))
+	if err := txDao.SaveRecord(settings); err != nil {
+		return fmt.Errorf("save league settings: %w", err)
+	}
+
+	_, err := txDao.DB().
+		Delete(adminNominationsCollection, dbx.HashExp{"leagueID": settings.GetInt("leagueID")}).
+		Execute()
+	if err != nil {
+		return fmt.Errorf("clear admin nominations: %w", err)
+	}
+	return nil
+}
+
+// setLeagueOwner hands ownership to newOwnerID, making them an admin if they
+// weren't one already. The legacy adminUserID on every member's leagues row is
+// kept in step so older queries keep working.
+func setLeagueOwner(txDao *daos.Dao, settings *models.Record, newOwnerID string) error {
+	admins := settings.GetStringSlice("adminUserIDs")
+	if !slices.Contains(admins, newOwnerID) {
+		admins = append(admins, newOwnerID)
+	}
+
+	settings.Set("ownerUserID", newOwnerID)
+	settings.Set("adminUserIDs", admins)
+	if err := txDao.SaveRecord(settings); err != nil {
+		return fmt.Errorf("save league settings: %w", err)
+	}
+
+	_, err := txDao.DB().
+		Update(leaguesCollection,
+			dbx.Params{"adminUserID": newOwnerID},
+			dbx.HashExp{"leagueID": settings.GetInt("leagueID")}).
+		Execute()
+	if err != nil {
+		return fmt.Errorf("update league admin: %w", err)
+	}
+	return nil
+}
+
+func loadLeagueAdminPage(txDao *daos.Dao, record *models.Record) (types.LeagueAdminPage, error) {
+	page := types.LeagueAdminPage{ViewerID: record.Id}
+
+	defaultLeague, err := getDefaultLeague(txDao, record.Get("teamID"))
+	if err != nil {
+		return page, fmt.Errorf("default league not found: %w", err)
+	}
+	page.LeagueID = defaultLeague.GetInt("leagueID")
+	page.LeagueName = leagueDisplayName(txDao, defaultLeague)
+
+	settings, err := getLeagueSettings(txDao, page.LeagueID)
+	if err != nil {
+		return page, fmt.Errorf("league settings not found: %w", err)
+	}
+	admins := settings.GetStringSlice("adminUserIDs")
+	owner := settings.GetString("ownerUserID")
+
+	page.ViewerIsAdmin = slices.Contains(admins, record.Id)
+	page.ViewerIsOwner = owner == record.Id
+	page.AdminsInactive = adminsInactive(txDao, settings)
+	page.InactiveGameweeks = settings.GetInt("adminInactivityGameweeks")
+	if page.InactiveGameweeks <= 0 {
+		page.InactiveGameweeks = defaultAdminInactivityGameweeks
+	}
+
+	err = txDao.DB().
+		Select(
+			"concat(U.firstName, ' ', U.lastName) as userName",
+			"l.userID").
+		From("leagues l").
+		LeftJoin("users U", dbx.NewExp("l.userID = U.ID")).
+		Where(dbx.NewExp("leagueID= {:leagueID} AND l.hasLeft = FALSE", dbx.Params{"leagueID": page.LeagueID})).
+		OrderBy("userName asc").
+		All(&page.Members)
+	if err != nil {
+		return page, fmt.Errorf("fetch members: %w", err)
+	}
+
+	nominations, err := txDao.FindRecordsByFilter(
+		adminNominationsCollection,
+		"leagueID = {:leagueID}",
+		"",
+		0,
+		0,
+		dbx.Params{"leagueID": page.LeagueID},
+	)
+	if err != nil {
+		return page, fmt.Errorf("fetch admin nominations: %w", err)
+	}
+
+	votes := make(map[string]int)
+	for _, nomination := range nominations {
+		votes[nomination.GetString("nomineeUserID")]++
+		if nomination.GetString("nominatorUserID") == record.Id {
+			page.ViewerVote = nomination.GetString("nomineeUserID")
+		}
+	}
+
+	for i := range page.Members {
+		page.Members[i].IsOwner = page.Members[i].UserID == owner
+		page.Members[i].IsAdmin = slices.Contains(admins, page.Members[i].UserID)
+		page.Members[i].Votes = votes[page.Members[i].UserID]
+	}
+
+	synced, err := txDao.FindRecordsByFilter(
+		lib.LeagueMembersCollection,
+		"leagueID = {:leagueID} && (userID = '' || hasLeft = true)",
+		"entryName",
+		0,
+		0,
+		dbx.Params{"leagueID": page.LeagueID},
+	)
+	if err != nil {
+		return page, fmt.Errorf("fetch synced members: %w", err)
+	}
+
+	for _, member := range synced {
+		syncMember := types.LeagueSyncMember{
+			EntryID:    member.GetInt("entryID"),
+			EntryName:  member.GetString("entryName"),
+			PlayerName: member.GetString("playerName"),
+		}
+		if member.GetBool("hasLeft") {
+			page.Left = append(page.Left, syncMember)
+		} else {
+			syncMember.VouchedFor = vouchedUsername(txDao, syncMember.EntryID)
+			page.Unclaimed = append(page.Unclaimed, syncMember)
+		}
+		if lastSynced := member.GetDateTime("lastSyncedAt").Time(); lastSynced.After(page.LastSynced) {
+			page.LastSynced = lastSynced
+		}
+	}
+
+	return page, nil
+}
+
+func LeagueAdminsGet(c echo.Context) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	var page types.LeagueAdminPage
+	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		var err error
+		page, err = loadLeagueAdminPage(txDao, record)
+		return err
+	})
+	if err != nil {
+		log.Printf("Transaction failed: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	return lib.Render(c, http.StatusOK, views.LeagueAdmins(page))
+}
+
+type leagueAdminUpdate func(txDao *daos.Dao, settings *models.Record, actorID string, targetID string) error
+
+// updateLeagueAdmins runs update against the settings of the caller's default
+// league for the member named in the userID form value, then re-renders the
+// admins page.
+func updateLeagueAdmins(c echo.Context, update leagueAdminUpdate) error {
+	targetID := c.FormValue("userID")
+	if targetID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "userID is required")
+	}
+
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		defaultLeague, err := getDefaultLeague(txDao, record.Get("teamID"))
+		if err != nil {
+			return fmt.Errorf("default league not found: %w", err)
+		}
+		leagueID := defaultLeague.GetInt("leagueID")
+
+		settings, err := getLeagueSettings(txDao, leagueID)
+		if err != nil {
+			return fmt.Errorf("league settings not found: %w", err)
+		}
+
+		if !isLeagueMember(txDao, leagueID, targetID) {
+			return echo.NewHTTPError(http.StatusBadRequest, "That user is not a member of this league")
+		}
+
+		return update(txDao, settings, record.Id, targetID)
+	})
+
+	if err != nil {
+		log.Printf("Transaction failed: %v", err)
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	return LeagueAdminsGet(c)
+}
+
+// LeagueAdminPromote makes a member a co-admin. Any admin can promote.
+func LeagueAdminPromote(c echo.Context) error {
+	return updateLeagueAdmins(c, func(txDao *daos.Dao, settings *models.Record, actorID string, targetID string) error {
+		if !isLeagueAdmin(settings, actorID) {
+			return echo.NewHTTPError(http.StatusForbidden, "Only league admins can promote members")
+		}
+
+		admins := settings.GetStringSlice("adminUserIDs")
+		if !slices.Contains(admins, targetID) {
+			settings.Set("adminUserIDs", append(admins, targetID))
+		}
+		log.Printf("User %s promoted %s to admin of league %d", actorID, targetID, settings.GetInt("leagueID"))
+
+		return recordAdminActivity(txDao, settings)
+	})
+}
+
+// LeagueAdminDemote removes a co-admin. The owner can't be demoted; ownership
+// has to be transferred first.
+func LeagueAdminDemote(c echo.Context) error {
+	return updateLeagueAdmins(c, func(txDao *daos.Dao, settings *models.Record, actorID string, targetID string) error {
+		if !isLeagueAdmin(settings, actorID) {
+			return echo.NewHTTPError(http.StatusForbidden, "Only league admins can demote admins")
+		}
+		if settings.GetString("ownerUserID") == targetID {
+			return echo.NewHTTPError(http.StatusBadRequest, "Transfer ownership before demoting the league owner")
+		}
+
+		admins := slices.DeleteFunc(settings.GetStringSlice("adminUserIDs"), func(id string) bool {
+			return id == targetID
+		})
+		settings.Set("adminUserIDs", admins)
+		log.Printf("User %s demoted %s in league %d", actorID, targetID, settings.GetInt("leagueID"))
+
+		return recordAdminActivity(txDao, settings)
+	})
+}
+
+// LeagueOwnerTransfer hands ownership to another member. The previous owner
+// stays on as a co-admin.
+func LeagueOwnerTransfer(c echo.Context) error {
+	return updateLeagueAdmins(c, func(txDao *daos.Dao, settings *models.Record, actorID string, targetID string) error {
+		if settings.GetString("ownerUserID") != actorID {
+			return echo.NewHTTPError(http.StatusForbidden, "Only the league owner can transfer ownership")
+		}
+
+		if err := setLeagueOwner(txDao, settings, targetID); err != nil {
+			return err
+		}
+		log.Printf("User %s transferred ownership of league %d to %s", actorID, settings.GetInt("leagueID"), targetID)
+
+		return recordAdminActivity(txDao, settings)
+	})
+}
+
+// LeagueAdminNominate records a member's vote for a replacement owner once the
+// admins have been inactive for the league's configured number of gameweeks.
+// A nominee backed by more than half of the league takes over as the only
+// admin.
+func LeagueAdminNominate(c echo.Context) error {
+	return updateLeagueAdmins(c, func(txDao *daos.Dao, settings *models.Record, actorID string, targetID string) error {
+		leagueID := settings.GetInt("leagueID")
+
+		if !isLeagueMember(txDao, leagueID, actorID) {
+			return echo.NewHTTPError(http.StatusForbidden, "Only league members can nominate a replacement admin")
+		}
+		if !adminsInactive(txDao, settings) {
+			return echo.NewHTTPError(http.StatusBadRequest, "The league admins are still active")
+		}
+
+		nomination, err := txDao.FindFirstRecordByFilter(
+			adminNominationsCollection,
+			"leagueID = {:leagueID} && nominatorUserID = {:userID}",
+			dbx.Params{"leagueID": leagueID, "userID": actorID},
+		)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("find admin nomination: %w", err)
+		}
+		if nomination == nil {
+			collection, err := txDao.FindCollectionByNameOrId(adminNominationsCollection)
+			if err != nil {
+				return fmt.Errorf("find collection: %w", err)
+			}
+			nomination = models.NewRecord(collection)
+			nomination.Set("leagueID", leagueID)
+			nomination.Set("nominatorUserID", actorID)
+		}
+		nomination.Set("nomineeUserID", targetID)
+		nomination.Set("gameweek", currentGameweek(txDao))
+		if err := txDao.SaveRecord(nomination); err != nil {
+			return fmt.Errorf("save admin nomination: %w", err)
+		}
+
+		votes, err := txDao.FindRecordsByFilter(
+			adminNominationsCollection,
+			"leagueID = {:leagueID} && nomineeUserID = {:userID}",
+			"",
+			0,
+			0,
+			dbx.Params{"leagueID": leagueID, "userID": targetID},
+		)
+		if err != nil {
+			return fmt.Errorf("count admin nominations: %w", err)
+		}
+
+		members, err := getLeagueMembers(txDao, leagueID)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("User %s nominated %s as admin of league %d (%d of %d votes)", actorID, targetID, leagueID, len(votes), len(members))
+		if len(votes)*2 <= len(members) {
+			return nil
+		}
+
+		// the admins who went quiet hand over to the new owner entirely
+		inactiveAdmins := slices.DeleteFunc(settings.GetStringSlice("adminUserIDs"), func(id string) bool {
+			return id == targetID
+		})
+		settings.Set("adminUserIDs", []string{})
+		if err := setLeagueOwner(txDao, settings, targetID); err != nil {
+			return err
+		}
+		log.Printf("User %s elected owner of league %d, replacing admins %v", targetID, leagueID, inactiveAdmins)
+
+		return recordAdminActivity(txDao, settings)
+	})
+}
+
+// LeagueSyncPost lets an admin refresh the league's membership from FPL
+// without waiting for the scheduled sync.
+func LeagueSyncPost(c echo.Context) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	var leagueID int
+	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		defaultLeague, err := getDefaultLeague(txDao, record.Get("teamID"))
+		if err != nil {
+			return fmt.Errorf("default league not found: %w", err)
+		}
+		leagueID = defaultLeague.GetInt("leagueID")
+
+		settings, err := getLeagueSettings(txDao, leagueID)
+		if err != nil {
+			return fmt.Errorf("league settings not found: %w", err)
+		}
+		if !isLeagueAdmin(settings, record.Id) {
+			return echo.NewHTTPError(http.StatusForbidden, "Only league admins can sync the league")
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Transaction failed: %v", err)
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	if err := lib.SyncLeague(pb, leagueID); err != nil {
+		log.Printf("League sync failed for league %d: %v", leagueID, err)
+		return echo.NewHTTPError(http.StatusBadGateway, "Couldn't fetch the league from FPL, try again later")
+	}
+
+	return LeagueAdminsGet(c)
+}
@@ -0,0 +1,436 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/cmcd97/bytesize/app/views"
+	"github.com/cmcd97/bytesize/lib"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/security"
+	pbtypes "github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	leagueInvitesCollection = "league_invites"
+	inviteCookieName        = "league_invite"
+	inviteCookieExpiration  = 1 * time.Hour
+	inviteCodeLength        = 20
+	defaultInviteDays       = 7
+	maxInviteDays           = 30
+	maxInviteUses           = 50
+)
+
+var errInviteUnusable = errors.New("this invite link has expired or has already been used")
+
+// findUsableInvite returns the invite for code if it can still be redeemed.
+func findUsableInvite(txDao *daos.Dao, code string) (*models.Record, error) {
+	if code == "" {
+		return nil, errInviteUnusable
+	}
+
+	invite, err := txDao.FindFirstRecordByFilter(
+		leagueInvitesCollection,
+		"code = {:code} && revoked = false",
+		dbx.Params{"code": code},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errInviteUnusable
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find invite: %w", err)
+	}
+
+	if invite.GetDateTime("expires").Time().Before(time.Now()) ||
+		invite.GetInt("uses") >= invite.GetInt("maxUses") {
+		return nil, errInviteUnusable
+	}
+
+	return invite, nil
+}
+
+// inviteFromCookie returns the invite the visitor arrived with, or nil if they
+// didn't follow an invite link or it can no longer be used.
+func inviteFromCookie(c echo.Context, txDao *daos.Dao) *models.Record {
+	cookie, err := c.Cookie(inviteCookieName)
+	if err != nil {
+		return nil
+	}
+
+	invite, err := findUsableInvite(txDao, cookie.Value)
+	if err != nil {
+		log.Printf("Ignoring invite cookie: %v", err)
+		return nil
+	}
+	return invite
+}
+
+func clearInviteCookie(c echo.Context) {
+	c.SetCookie(&http.Cookie{
+		Name:     inviteCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// pendingInvite describes the invite the visitor arrived with for the setup
+// page, so it can name the league and pre-fill a bound FPL entry.
+func pendingInvite(c echo.Context) *types.PendingInvite {
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		return nil
+	}
+
+	invite := inviteFromCookie(c, pb.Dao())
+	if invite == nil {
+		return nil
+	}
+
+	pending := &types.PendingInvite{EntryID: invite.GetInt("entryID")}
+
+	league, err := pb.Dao().FindFirstRecordByFilter(
+		leaguesCollection,
+		"leagueID = {:leagueID}",
+		dbx.Params{"leagueID": invite.GetInt("leagueID")},
+	)
+	if err == nil {
+		pending.LeagueName = leagueDisplayName(pb.Dao(), league)
+	}
+
+	return pending
+}
+
+// redeemInvite makes the invited league the new user's default and counts
+// the use. Users who aren't in the FPL league don't use up the invite.
+func redeemInvite(txDao *daos.Dao, code string, userID string, teamID int) error {
+	invite, err := findUsableInvite(txDao, code)
+	if err != nil {
+		return err
+	}
+	leagueID := invite.GetInt("leagueID")
+
+	if entryID := invite.GetInt("entryID"); entryID != 0 && entryID != teamID {
+		return echo.NewHTTPError(http.StatusBadRequest, "This invite is for a different FPL team")
+	}
+
+	league, err := txDao.FindFirstRecordByFilter(
+		leaguesCollection,
+		"leagueID = {:leagueID} && userID = {:userID}",
+		dbx.Params{"leagueID": leagueID, "userID": userID},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("User %s followed an invite to league %d but isn't a member in FPL", userID, leagueID)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("find invited league: %w", err)
+	}
+
+	_, err = txDao.DB().
+		Update(leaguesCollection,
+			dbx.Params{"isDefault": false},
+			dbx.HashExp{"userID": userID}).
+		Execute()
+	if err != nil {
+		return fmt.Errorf("clear default league: %w", err)
+	}
+
+	league.Set("isDefault", true)
+	league.Set("isActive", true)
+	league.Set("isLinked", true)
+	if settings, err := getLeagueSettings(txDao, leagueID); err == nil {
+		league.Set("adminUserID", settings.GetString("ownerUserID"))
+	}
+	if err := txDao.SaveRecord(league); err != nil {
+		return fmt.Errorf("save invited league: %w", err)
+	}
+
+	_, err = txDao.DB().
+		Update(lib.LeagueMembersCollection,
+			dbx.Params{"userID": userID},
+			dbx.HashExp{"leagueID": leagueID, "entryID": teamID}).
+		Execute()
+	if err != nil {
+		return fmt.Errorf("claim league member: %w", err)
+	}
+
+	invite.Set("uses", invite.GetInt("uses")+1)
+	if err := txDao.SaveRecord(invite); err != nil {
+		return fmt.Errorf("save invite: %w", err)
+	}
+	log.Printf("User %s joined league %d with invite %s", userID, leagueID, invite.Id)
+
+	return nil
+}
+
+// InviteLanding is where invite links point. It remembers the invite in a
+// cookie and sends the visitor on to register, or to their profile if they're
+// already signed in but haven't linked a team yet.
+func InviteLanding(c echo.Context) error {
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	code := c.PathParam("code")
+	if _, err := findUsableInvite(pb.Dao(), code); err != nil {
+		log.Printf("Invite %q rejected: %v", code, err)
+		return echo.NewHTTPError(http.StatusNotFound, errInviteUnusable.Error())
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     inviteCookieName,
+		Value:    code,
+		Path:     "/",
+		Expires:  time.Now().Add(inviteCookieExpiration),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	if c.Get(apis.ContextAuthRecordKey) != nil {
+		return c.Redirect(http.StatusFound, "/app/profile")
+	}
+	return c.Redirect(http.StatusFound, "/auth/register")
+}
+
+// adminLeagueID returns the caller's default league if they're one of its
+// admins.
+func adminLeagueID(txDao *daos.Dao, record *models.Record) (int, error) {
+	defaultLeague, err := getDefaultLeague(txDao, record.Get("teamID"))
+	if err != nil {
+		return 0, fmt.Errorf("default league not found: %w", err)
+	}
+	leagueID := defaultLeague.GetInt("leagueID")
+
+	settings, err := getLeagueSettings(txDao, leagueID)
+	if err != nil {
+		return 0, fmt.Errorf("league settings not found: %w", err)
+	}
+	if !isLeagueAdmin(settings, record.Id) {
+		return 0, echo.NewHTTPError(http.StatusForbidden, "Only league admins can manage the league")
+	}
+
+	return leagueID, nil
+}
+
+func loadLeagueInvitesPage(c echo.Context, txDao *daos.Dao, leagueID int) (types.LeagueInvitesPage, error) {
+	var page types.LeagueInvitesPage
+
+	invites, err := txDao.FindRecordsByFilter(
+		leagueInvitesCollection,
+		"leagueID = {:leagueID} && revoked = false && expires > @now && uses < maxUses",
+		"-created",
+		0,
+		0,
+		dbx.Params{"leagueID": leagueID},
+	)
+	if err != nil {
+		return page, fmt.Errorf("fetch invites: %w", err)
+	}
+
+	unclaimed, err := txDao.FindRecordsByFilter(
+		lib.LeagueMembersCollection,
+		"leagueID = {:leagueID} && userID = '' && hasLeft = false",
+		"entryName",
+		0,
+		0,
+		dbx.Params{"leagueID": leagueID},
+	)
+	if err != nil {
+		return page, fmt.Errorf("fetch unclaimed members: %w", err)
+	}
+
+	entryNames := make(map[int]string, len(unclaimed))
+	for _, member := range unclaimed {
+		entry := types.LeagueSyncMember{
+			EntryID:    member.GetInt("entryID"),
+			EntryName:  member.GetString("entryName"),
+			PlayerName: member.GetString("playerName"),
+		}
+		entryNames[entry.EntryID] = entry.PlayerName
+		page.Unclaimed = append(page.Unclaimed, entry)
+	}
+
+	baseURL := c.Scheme() + "://" + c.Request().Host
+	for _, invite := range invites {
+		page.Invites = append(page.Invites, types.LeagueInvite{
+			ID:        invite.Id,
+			Link:      baseURL + "/invite/" + invite.GetString("code"),
+			EntryID:   invite.GetInt("entryID"),
+			EntryName: entryNames[invite.GetInt("entryID")],
+			Expires:   invite.GetDateTime("expires").Time(),
+			MaxUses:   invite.GetInt("maxUses"),
+			Uses:      invite.GetInt("uses"),
+		})
+	}
+
+	return page, nil
+}
+
+// LeagueInvitesGet renders the invite section of the league admins page. It's
+// empty for members who aren't admins.
+func LeagueInvitesGet(c echo.Context) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	var page types.LeagueInvitesPage
+	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		leagueID, err := adminLeagueID(txDao, record)
+		if err != nil {
+			return err
+		}
+		page, err = loadLeagueInvitesPage(c, txDao, leagueID)
+		return err
+	})
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) && httpErr.Code == http.StatusForbidden {
+		return c.NoContent(http.StatusOK)
+	}
+	if err != nil {
+		log.Printf("Transaction failed: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	return lib.Render(c, http.StatusOK, views.LeagueInvites(page))
+}
+
+type leagueInviteUpdate func(txDao *daos.Dao, leagueID int, actorID string) error
+
+// updateLeagueInvites runs update for an admin of the caller's default league
+// and re-renders the invite section.
+func updateLeagueInvites(c echo.Context, update leagueInviteUpdate) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		leagueID, err := adminLeagueID(txDao, record)
+		if err != nil {
+			return err
+		}
+		return update(txDao, leagueID, record.Id)
+	})
+
+	if err != nil {
+		log.Printf("Transaction failed: %v", err)
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	return LeagueInvitesGet(c)
+}
+
+// LeagueInviteCreate generates a new invite link. Links bound to an FPL entry
+// can only be used once, by that entry.
+func LeagueInviteCreate(c echo.Context) error {
+	entryID, _ := strconv.Atoi(c.FormValue("entryID"))
+
+	days := defaultInviteDays
+	if value := c.FormValue("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxInviteDays {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invites can last between 1 and %d days", maxInviteDays))
+		}
+		days = parsed
+	}
+
+	maxUses := 1
+	if value := c.FormValue("maxUses"); value != "" && entryID == 0 {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxInviteUses {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invites can be used between 1 and %d times", maxInviteUses))
+		}
+		maxUses = parsed
+	}
+
+	return updateLeagueInvites(c, func(txDao *daos.Dao, leagueID int, actorID string) error {
+		collection, err := txDao.FindCollectionByNameOrId(leagueInvitesCollection)
+		if err != nil {
+			return fmt.Errorf("find collection: %w", err)
+		}
+
+		expires, err := pbtypes.ParseDateTime(time.Now().AddDate(0, 0, days))
+		if err != nil {
+			return fmt.Errorf("invite expiry: %w", err)
+		}
+
+		invite := models.NewRecord(collection)
+		invite.Set("leagueID", leagueID)
+		invite.Set("code", security.RandomString(inviteCodeLength))
+		invite.Set("createdByUserID", actorID)
+		invite.Set("entryID", entryID)
+		invite.Set("expires", expires)
+		invite.Set("maxUses", maxUses)
+		invite.Set("uses", 0)
+		invite.Set("revoked", false)
+		if err := txDao.SaveRecord(invite); err != nil {
+			return fmt.Errorf("save invite: %w", err)
+		}
+		log.Printf("User %s created invite %s for league %d", actorID, invite.Id, leagueID)
+
+		return nil
+	})
+}
+
+func LeagueInviteRevoke(c echo.Context) error {
+	inviteID := c.FormValue("inviteID")
+	if inviteID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "inviteID is required")
+	}
+
+	return updateLeagueInvites(c, func(txDao *daos.Dao, leagueID int, actorID string) error {
+		invite, err := txDao.FindFirstRecordByFilter(
+			leagueInvitesCollection,
+			"id = {:id} && leagueID = {:leagueID}",
+			dbx.Params{"id": inviteID, "leagueID": leagueID},
+		)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Invite not found")
+		}
+
+		invite.Set("revoked", true)
+		if err := txDao.SaveRecord(invite); err != nil {
+			return fmt.Errorf("save invite: %w", err)
+		}
+		log.Printf("User %s revoked invite %s for league %d", actorID, invite.Id, leagueID)
+
+		return nil
+	})
+}
@@ -0,0 +1,490 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/cmcd97/bytesize/app/views"
+	"github.com/cmcd97/bytesize/lib"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	pbtypes "github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	leagueSettingsVersionsCollection = "league_settings_versions"
+	defaultRandomNominationCount     = 3
+	maxRandomNominationCount         = 10
+	maxLeagueDisplayNameLength       = 50
+	maxFineDescriptionLength         = 500
+	maxFineAmount                    = 100000
+	lastGameweek                     = 38
+	leagueSettingsHistoryLimit       = 20
+)
+
+// readLeagueSettings maps a settings record onto types.LeagueSettings, filling
+// in the defaults the app used before the settings were configurable.
+func readLeagueSettings(settings *models.Record, fallbackName string) types.LeagueSettings {
+	result := types.LeagueSettings{
+		DisplayName:               settings.GetString("displayName"),
+		StartGameweek:             settings.GetInt("startGameweek"),
+		RandomNominationEnabled:   settings.GetBool("randomNominationEnabled"),
+		RandomNominationCount:     settings.GetInt("randomNominationCount"),
+		FineDescription:           settings.GetString("fineDescription"),
+		CaptainCardsDoubled:       settings.GetBool("captainCardsDoubled"),
+		TripleCaptainCardsTripled: settings.GetBool("tripleCaptainCardsTripled"),
+		BenchBoostCardsAll:        settings.GetBool("benchBoostCardsAll"),
+		FinesEnabled:              settings.GetBool("finesEnabled"),
+		FineCurrency:              settings.GetString("fineCurrency"),
+		FineAmounts:               make(map[string]int),
+		FineEscalation:            settings.GetInt("fineEscalation"),
+		Version:                   settings.GetInt("version"),
+	}
+	if raw := settings.GetString("fineAmounts"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &result.FineAmounts); err != nil {
+			log.Printf("Failed to read the fine amounts of league %d: %v", settings.GetInt("leagueID"), err)
+		}
+	}
+	if result.DisplayName == "" {
+		result.DisplayName = fallbackName
+	}
+	if result.StartGameweek <= 0 {
+		result.StartGameweek = 1
+	}
+	if result.RandomNominationCount <= 0 {
+		result.RandomNominationCount = defaultRandomNominationCount
+	}
+	if result.FineCurrency == "" {
+		result.FineCurrency = lib.DefaultFineCurrency
+	}
+	return result
+}
+
+// leagueDisplayName returns the admin chosen name for a member's leagues row,
+// falling back to the name imported from FPL. Unlike getLeagueSettings it
+// never creates a settings record, so it's safe to call for unlinked leagues.
+func leagueDisplayName(txDao *daos.Dao, league *models.Record) string {
+	fallback := league.GetString("leagueName")
+
+	settings, err := txDao.FindFirstRecordByFilter(
+		leagueSettingsCollection,
+		"leagueID = {:leagueID}",
+		dbx.Params{"leagueID": league.GetInt("leagueID")},
+	)
+	if err != nil {
+		return fallback
+	}
+	return readLeagueSettings(settings, fallback).DisplayName
+}
+
+func loadLeagueSettingsPage(txDao *daos.Dao, record *models.Record) (types.LeagueSettingsPage, error) {
+	var page types.LeagueSettingsPage
+
+	defaultLeague, err := getDefaultLeague(txDao, record.Get("teamID"))
+	if err != nil {
+		return page, fmt.Errorf("default league not found: %w", err)
+	}
+	page.LeagueID = defaultLeague.GetInt("leagueID")
+
+	settings, err := getLeagueSettings(txDao, page.LeagueID)
+	if err != nil {
+		return page, fmt.Errorf("league settings not found: %w", err)
+	}
+	page.Settings = readLeagueSettings(settings, defaultLeague.GetString("leagueName"))
+	page.ViewerIsAdmin = isLeagueAdmin(settings, record.Id)
+	page.SeasonConfirmationPending = settings.GetBool("seasonConfirmationPending")
+	page.SeasonName = lib.SeasonName(lib.CurrentSeasonStartYear(txDao))
+
+	versions, err := txDao.FindRecordsByFilter(
+		leagueSettingsVersionsCollection,
+		"leagueID = {:leagueID}",
+		"-version",
+		leagueSettingsHistoryLimit,
+		0,
+		dbx.Params{"leagueID": page.LeagueID},
+	)
+	if err != nil {
+		return page, fmt.Errorf("fetch settings history: %w", err)
+	}
+
+	names := make(map[string]string)
+	for _, version := range versions {
+		userID := version.GetString("changedByUserID")
+		if _, ok := names[userID]; !ok {
+			names[userID] = "Unknown"
+			if user, err := txDao.FindRecordById("users", userID); err == nil {
+				names[userID] = user.GetString("firstName") + " " + user.GetString("lastName")
+			}
+		}
+
+		var changes []types.LeagueSettingsChange
+		if err := json.Unmarshal([]byte(version.GetString("changes")), &changes); err != nil {
+			log.Printf("Failed to decode settings version %s: %v", version.Id, err)
+		}
+
+		page.History = append(page.History, types.LeagueSettingsVersion{
+			Version:   version.GetInt("version"),
+			Gameweek:  version.GetInt("gameweek"),
+			ChangedBy: names[userID],
+			Changes:   changes,
+			Created:   version.Created.Time(),
+		})
+	}
+
+	return page, nil
+}
+
+func LeagueSettingsGet(c echo.Context) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	var page types.LeagueSettingsPage
+	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		var err error
+		page, err = loadLeagueSettingsPage(txDao, record)
+		return err
+	})
+	if err != nil {
+		log.Printf("Transaction failed: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	return lib.Render(c, http.StatusOK, views.LeagueSettings(page))
+}
+
+// parseLeagueSettingsForm validates the settings form. Fields are checked here
+// rather than by collection rules so the admin gets a readable message.
+func parseLeagueSettingsForm(c echo.Context) (types.LeagueSettings, error) {
+	form := types.LeagueSettings{
+		DisplayName:               strings.TrimSpace(c.FormValue("displayName")),
+		RandomNominationEnabled:   c.FormValue("randomNominationEnabled") == "on",
+		FineDescription:           strings.TrimSpace(c.FormValue("fineDescription")),
+		CaptainCardsDoubled:       c.FormValue("captainCardsDoubled") == "on",
+		TripleCaptainCardsTripled: c.FormValue("tripleCaptainCardsTripled") == "on",
+		BenchBoostCardsAll:        c.FormValue("benchBoostCardsAll") == "on",
+		FinesEnabled:              c.FormValue("finesEnabled") == "on",
+		FineCurrency:              strings.ToUpper(strings.TrimSpace(c.FormValue("fineCurrency"))),
+		FineAmounts:               make(map[string]int),
+	}
+
+	if form.DisplayName == "" || len(form.DisplayName) > maxLeagueDisplayNameLength {
+		return form, echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("League name must be between 1 and %d characters", maxLeagueDisplayNameLength))
+	}
+	if len(form.FineDescription) > maxFineDescriptionLength {
+		return form, echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("Fine description can't be longer than %d characters", maxFineDescriptionLength))
+	}
+
+	startGameweek, err := strconv.Atoi(c.FormValue("startGameweek"))
+	if err != nil || startGameweek < 1 || startGameweek > lastGameweek {
+		return form, echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("Start gameweek must be between 1 and %d", lastGameweek))
+	}
+	form.StartGameweek = startGameweek
+
+	count, err := strconv.Atoi(c.FormValue("randomNominationCount"))
+	if err != nil || count < 1 || count > maxRandomNominationCount {
+		return form, echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("Random nominations must be between 1 and %d", maxRandomNominationCount))
+	}
+	form.RandomNominationCount = count
+
+	if form.FineCurrency == "" {
+		form.FineCurrency = lib.DefaultFineCurrency
+	}
+	if !isCurrencyCode(form.FineCurrency) {
+		return form, echo.NewHTTPError(http.StatusBadRequest, "Currency must be a three letter code such as GBP or EUR")
+	}
+	for _, cardType := range lib.FineCardTypes {
+		amount, err := parseFineAmount(c.FormValue("fineAmount_" + cardType))
+		if err != nil {
+			return form, err
+		}
+		if amount > 0 {
+			form.FineAmounts[cardType] = amount
+		}
+	}
+	form.FineEscalation, err = parseFineAmount(c.FormValue("fineEscalation"))
+	if err != nil {
+		return form, err
+	}
+
+	return form, nil
+}
+
+// parseFineAmount reads an amount of money from a form, where blank means
+// nothing
+func parseFineAmount(value string) (int, error) {
+	if strings.TrimSpace(value) == "" {
+		return 0, nil
+	}
+	amount, err := lib.ParseMoney(value)
+	if err != nil || amount > maxFineAmount {
+		return 0, echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("Amounts must be between 0 and %s, with at most two decimal places", lib.FormatAmount(maxFineAmount)))
+	}
+	return amount, nil
+}
+
+func isCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, letter := range code {
+		if letter < 'A' || letter > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// diffLeagueSettings lists the fields that differ between two versions of the
+// settings, formatted for the history table.
+func diffLeagueSettings(before, after types.LeagueSettings) []types.LeagueSettingsChange {
+	var changes []types.LeagueSettingsChange
+	add := func(field, from, to string) {
+		if from != to {
+			changes = append(changes, types.LeagueSettingsChange{Field: field, From: from, To: to})
+		}
+	}
+
+	add("League name", before.DisplayName, after.DisplayName)
+	add("Start gameweek", strconv.Itoa(before.StartGameweek), strconv.Itoa(after.StartGameweek))
+	add("Random nomination allowed", strconv.FormatBool(before.RandomNominationEnabled), strconv.FormatBool(after.RandomNominationEnabled))
+	add("Random nominations drawn", strconv.Itoa(before.RandomNominationCount), strconv.Itoa(after.RandomNominationCount))
+	add("Fine description", before.FineDescription, after.FineDescription)
+	add("Captain cards doubled", strconv.FormatBool(before.CaptainCardsDoubled), strconv.FormatBool(after.CaptainCardsDoubled))
+	add("Triple Captain triples captain cards", strconv.FormatBool(before.TripleCaptainCardsTripled), strconv.FormatBool(after.TripleCaptainCardsTripled))
+	add("Bench Boost cards the whole squad", strconv.FormatBool(before.BenchBoostCardsAll), strconv.FormatBool(after.BenchBoostCardsAll))
+	add("Money fines", strconv.FormatBool(before.FinesEnabled), strconv.FormatBool(after.FinesEnabled))
+	add("Fine currency", before.FineCurrency, after.FineCurrency)
+	for _, cardType := range lib.FineCardTypes {
+		add("Fine for "+lib.ReplaceUnderscoresWithSpaces(cardType),
+			lib.FormatAmount(before.FineAmounts[cardType]), lib.FormatAmount(after.FineAmounts[cardType]))
+	}
+	add("Repeat offence surcharge", lib.FormatAmount(before.FineEscalation), lib.FormatAmount(after.FineEscalation))
+
+	return changes
+}
+
+// LeagueSettingsPost saves the settings form. Every change bumps the settings
+// version and is recorded alongside the gameweek it was made in, so members
+// can see what was changed mid-season.
+func LeagueSettingsPost(c echo.Context) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	form, err := parseLeagueSettingsForm(c)
+	if err != nil {
+		return err
+	}
+
+	err = pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		defaultLeague, err := getDefaultLeague(txDao, record.Get("teamID"))
+		if err != nil {
+			return fmt.Errorf("default league not found: %w", err)
+		}
+		leagueID := defaultLeague.GetInt("leagueID")
+
+		settings, err := getLeagueSettings(txDao, leagueID)
+		if err != nil {
+			return fmt.Errorf("league settings not found: %w", err)
+		}
+		if !isLeagueAdmin(settings, record.Id) {
+			return echo.NewHTTPError(http.StatusForbidden, "Only league admins can change league settings")
+		}
+
+		current := readLeagueSettings(settings, defaultLeague.GetString("leagueName"))
+		changes := diffLeagueSettings(current, form)
+		if len(changes) == 0 {
+			return nil
+		}
+
+		version := current.Version + 1
+		settings.Set("displayName", form.DisplayName)
+		settings.Set("startGameweek", form.StartGameweek)
+		settings.Set("randomNominationEnabled", form.RandomNominationEnabled)
+		settings.Set("randomNominationCount", form.RandomNominationCount)
+		settings.Set("fineDescription", form.FineDescription)
+		settings.Set("captainCardsDoubled", form.CaptainCardsDoubled)
+		settings.Set("tripleCaptainCardsTripled", form.TripleCaptainCardsTripled)
+		settings.Set("benchBoostCardsAll", form.BenchBoostCardsAll)
+		settings.Set("finesEnabled", form.FinesEnabled)
+		settings.Set("fineCurrency", form.FineCurrency)
+		settings.Set("fineAmounts", form.FineAmounts)
+		settings.Set("fineEscalation", form.FineEscalation)
+		// only cards handed out from now on are charged
+		if form.FinesEnabled && !current.FinesEnabled {
+			settings.Set("finesEnabledAt", pbtypes.NowDateTime())
+		}
+		settings.Set("version", version)
+
+		collection, err := txDao.FindCollectionByNameOrId(leagueSettingsVersionsCollection)
+		if err != nil {
+			return fmt.Errorf("find collection: %w", err)
+		}
+		entry := models.NewRecord(collection)
+		entry.Set("leagueID", leagueID)
+		entry.Set("version", version)
+		entry.Set("changedByUserID", record.Id)
+		entry.Set("gameweek", currentGameweek(txDao))
+		entry.Set("changes", changes)
+		if err := txDao.SaveRecord(entry); err != nil {
+			return fmt.Errorf("save settings version: %w", err)
+		}
+		log.Printf("User %s updated settings of league %d to version %d", record.Id, leagueID, version)
+
+		// recordAdminActivity saves the settings record
+		if err := recordAdminActivity(txDao, settings); err != nil {
+			return err
+		}
+		// only cards that haven't been charged yet pick up the new amounts,
+		// charges already on the ledger keep theirs
+		return lib.SyncFineCharges(txDao, settings)
+	})
+
+	if err != nil {
+		log.Printf("Transaction failed: %v", err)
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	return LeagueSettingsGet(c)
+}
+
+// LeagueSeasonConfirm confirms the league is carrying on into the current
+// season, after which it earns cards again. The league's rows are moved on to
+// the new season.
+func LeagueSeasonConfirm(c echo.Context) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		defaultLeague, err := getDefaultLeague(txDao, record.Get("teamID"))
+		if err != nil {
+			return fmt.Errorf("default league not found: %w", err)
+		}
+		leagueID := defaultLeague.GetInt("leagueID")
+
+		settings, err := getLeagueSettings(txDao, leagueID)
+		if err != nil {
+			return fmt.Errorf("league settings not found: %w", err)
+		}
+		if !isLeagueAdmin(settings, record.Id) {
+			return echo.NewHTTPError(http.StatusForbidden, "Only league admins can confirm the new season")
+		}
+		if !settings.GetBool("seasonConfirmationPending") {
+			return nil
+		}
+
+		seasonStartYear := lib.CurrentSeasonStartYear(txDao)
+		_, err = txDao.DB().
+			Update("leagues", dbx.Params{"seasonStartYear": seasonStartYear}, dbx.HashExp{"leagueID": leagueID}).
+			Execute()
+		if err != nil {
+			return fmt.Errorf("update league season: %w", err)
+		}
+
+		settings.Set("seasonConfirmationPending", false)
+		err = lib.WriteAuditLog(txDao, record.Id, "league_season_confirmed", "", map[string]any{
+			"leagueID": leagueID,
+			"season":   lib.SeasonName(seasonStartYear),
+		})
+		if err != nil {
+			return err
+		}
+		log.Printf("User %s confirmed league %d is continuing into %s", record.Id, leagueID, lib.SeasonName(seasonStartYear))
+
+		// recordAdminActivity saves the settings record
+		return recordAdminActivity(txDao, settings)
+	})
+
+	if err != nil {
+		log.Printf("Transaction failed: %v", err)
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	return LeagueSettingsGet(c)
+}
+
+// randomNominationSettings returns whether random nomination is allowed in a
+// league and how many members to draw. Leagues nobody has initialised keep the
+// original behaviour of three.
+func randomNominationSettings(txDao *daos.Dao, leagueID int) (bool, int, error) {
+	settings, err := getLeagueSettings(txDao, leagueID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, defaultRandomNominationCount, nil
+	}
+	if err != nil {
+		return false, 0, err
+	}
+
+	current := readLeagueSettings(settings, "")
+	return current.RandomNominationEnabled, current.RandomNominationCount, nil
+}
+
+// leagueFineDescription returns the fine wording the league's admins set, or
+// an empty string if they haven't.
+func leagueFineDescription(txDao *daos.Dao, leagueID int) string {
+	settings, err := getLeagueSettings(txDao, leagueID)
+	if err != nil {
+		return ""
+	}
+	return settings.GetString("fineDescription")
+}
+
+// leagueStartGameweek returns the first gameweek that counts towards a
+// league's OffsideFPL standings.
+func leagueStartGameweek(txDao *daos.Dao, leagueID int) int {
+	settings, err := getLeagueSettings(txDao, leagueID)
+	if err != nil {
+		return 1
+	}
+	return readLeagueSettings(settings, "").StartGameweek
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/cmcd97/bytesize/app/views"
+	"github.com/cmcd97/bytesize/lib"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+func loadLivePage(txDao *daos.Dao, record *models.Record) (types.LivePage, error) {
+	var page types.LivePage
+
+	defaultLeague, err := getDefaultLeague(txDao, record.Get("teamID"))
+	if err != nil {
+		return page, fmt.Errorf("default league not found: %w", err)
+	}
+	leagueID := defaultLeague.GetInt("leagueID")
+	page.LeagueName = leagueDisplayName(txDao, defaultLeague)
+
+	page.Gameweek = lib.LiveGameweek(txDao)
+	if page.Gameweek == 0 {
+		return page, nil
+	}
+
+	var managers []struct {
+		UserID    string `db:"userID"`
+		FirstName string `db:"firstName"`
+		LastName  string `db:"lastName"`
+		Confirmed bool   `db:"confirmed"`
+	}
+	err = txDao.DB().
+		Select(
+			"l.userID",
+			"u.firstName",
+			"u.lastName",
+			"EXISTS (SELECT 1 FROM results r WHERE r.userID = l.userID AND r.gameweek = {:gameweek}) as confirmed").
+		From("leagues l").
+		InnerJoin("users u", dbx.NewExp("u.id = l.userID")).
+		Where(dbx.NewExp("l.leagueID = {:leagueID} AND l.hasLeft = FALSE", dbx.Params{"leagueID": leagueID, "gameweek": page.Gameweek})).
+		OrderBy("u.firstName asc").
+		All(&managers)
+	if err != nil {
+		return page, fmt.Errorf("fetch managers: %w", err)
+	}
+
+	indexes := make(map[string]int)
+	for _, manager := range managers {
+		indexes[manager.UserID] = len(page.Managers)
+		page.Managers = append(page.Managers, types.LiveManager{
+			UserID:    manager.UserID,
+			Name:      lib.ManagerName(manager.FirstName, manager.LastName),
+			Confirmed: manager.Confirmed,
+		})
+	}
+	owner := func(userID string) string {
+		return managers[indexes[userID]].FirstName + "'s"
+	}
+
+	var provisional []struct {
+		UserID          string `db:"userID"`
+		Type            string `db:"type"`
+		PlayerID        int    `db:"playerID"`
+		FixtureID       int    `db:"fixtureID"`
+		FixtureFinished bool   `db:"fixtureFinished"`
+	}
+	err = txDao.DB().
+		Select("userID", "type", "playerID", "fixtureID", "fixtureFinished").
+		From(lib.ProvisionalCardsCollection).
+		Where(dbx.HashExp{"leagueID": leagueID, "gameweek": page.Gameweek}).
+		OrderBy("created asc").
+		All(&provisional)
+	if err != nil {
+		return page, fmt.Errorf("fetch provisional cards: %w", err)
+	}
+	for _, card := range provisional {
+		i, ok := indexes[card.UserID]
+		if !ok || page.Managers[i].Confirmed {
+			continue
+		}
+		page.Managers[i].Cards = append(page.Managers[i].Cards, types.LiveCard{
+			Type: card.Type,
+			Cause: lib.ExplainCard(txDao, owner(card.UserID), types.DatabaseCard{
+				Type:      card.Type,
+				Gameweek:  page.Gameweek,
+				PlayerID:  card.PlayerID,
+				FixtureID: card.FixtureID,
+			}),
+			Provisional:     true,
+			FixtureFinished: card.FixtureFinished,
+		})
+	}
+
+	var confirmed []managerHistoryCard
+	err = txDao.DB().
+		Select(
+			"c.gameweek",
+			"c.type",
+			"c.userID",
+			"c.playerID",
+			"c.fixtureID",
+			"COALESCE(nominator.firstName, '') as nominatorName").
+		From("cards c").
+		LeftJoin("users nominator", dbx.NewExp("nominator.id = c.nominatorUserID")).
+		Where(dbx.NewExp("c.leagueID = {:leagueID} AND c.gameweek = {:gameweek} AND c.voided = FALSE", dbx.Params{"leagueID": leagueID, "gameweek": page.Gameweek})).
+		OrderBy("c.created asc").
+		All(&confirmed)
+	if err != nil {
+		return page, fmt.Errorf("fetch cards: %w", err)
+	}
+	for _, card := range confirmed {
+		i, ok := indexes[card.UserID]
+		if !ok {
+			continue
+		}
+		page.Managers[i].Cards = append(page.Managers[i].Cards, types.LiveCard{
+			Type:  card.Type,
+			Cause: cardCause(txDao, owner(card.UserID), card),
+		})
+	}
+
+	return page, nil
+}
+
+// LiveGet shows the cards the managers of the viewer's default league are
+// picking up in the gameweek being played. Until a manager's gameweek is
+// finalised their cards are provisional, from the live match stats.
+func LiveGet(c echo.Context) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	var page types.LivePage
+	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		var err error
+		page, err = loadLivePage(txDao, record)
+		return err
+	})
+	if err != nil {
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		log.Printf("Transaction failed: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	return lib.Render(c, http.StatusOK, views.Live(page))
+}
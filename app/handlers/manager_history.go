@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/cmcd97/bytesize/app/views"
+	"github.com/cmcd97/bytesize/lib"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+type managerHistoryCard struct {
+	Gameweek        int    `db:"gameweek"`
+	Type            string `db:"type"`
+	UserID          string `db:"userID"`
+	NominatorUserID string `db:"nominatorUserID"`
+	Voided          bool   `db:"voided"`
+	VoidReason      string `db:"voidReason"`
+	PlayerID        int    `db:"playerID"`
+	FixtureID       int    `db:"fixtureID"`
+	UserName        string `db:"userName"`
+	NominatorName   string `db:"nominatorName"`
+}
+
+// cardCause explains what earned a card: who nominated or reversed it, or
+// which player's incident in which fixture.
+func cardCause(txDao *daos.Dao, owner string, card managerHistoryCard) string {
+	switch card.Type {
+	case "nomination":
+		return "Nominated by " + card.NominatorName
+	case "reverse":
+		return "Reversed back by " + card.NominatorName
+	}
+	return lib.ExplainCard(txDao, owner, types.DatabaseCard{
+		Type:      card.Type,
+		Gameweek:  card.Gameweek,
+		PlayerID:  card.PlayerID,
+		FixtureID: card.FixtureID,
+	})
+}
+
+func loadManagerHistoryPage(txDao *daos.Dao, record *models.Record, userID string) (types.ManagerHistoryPage, error) {
+	var page types.ManagerHistoryPage
+
+	defaultLeague, err := getDefaultLeague(txDao, record.Get("teamID"))
+	if err != nil {
+		return page, fmt.Errorf("default league not found: %w", err)
+	}
+	leagueID := defaultLeague.GetInt("leagueID")
+	page.LeagueName = leagueDisplayName(txDao, defaultLeague)
+
+	if !isLeagueMember(txDao, leagueID, userID) {
+		return page, echo.NewHTTPError(http.StatusNotFound, "That manager isn't in your league")
+	}
+	manager, err := txDao.FindRecordById("users", userID)
+	if err != nil {
+		return page, echo.NewHTTPError(http.StatusNotFound, "Manager not found")
+	}
+	page.Name = lib.ManagerName(manager.GetString("firstName"), manager.GetString("lastName"))
+	page.TeamName = manager.GetString("teamName")
+
+	startGameweek := leagueStartGameweek(txDao, leagueID)
+
+	var rows []struct {
+		Gameweek        int    `db:"gameweek"`
+		RawPoints       int    `db:"rawPoints"`
+		Hits            int    `db:"hits"`
+		ActiveChip      string `db:"activeChip"`
+		Points          int    `db:"points"`
+		TotalPoints     int    `db:"totalPoints"`
+		IsSuspendedNext bool   `db:"isSuspendedNext"`
+	}
+	err = txDao.DB().
+		Select(
+			"r.gameweek",
+			"r.points as rawPoints",
+			"r.hits",
+			"COALESCE(r.activeChip, '') as activeChip",
+			"COALESCE(ag.points, r.points) as points",
+			"COALESCE(ag.totalPoints, 0) as totalPoints",
+			"COALESCE(ag.isSuspendedNext, FALSE) as isSuspendedNext").
+		From("results r").
+		LeftJoin("aggregated_results ag", dbx.NewExp("ag.userID = r.userID AND ag.gameweek = r.gameweek")).
+		// the gameweek before the start is only read for its totals and suspension
+		Where(dbx.NewExp("r.userID = {:userID} AND r.gameweek >= {:startGW} - 1", dbx.Params{"userID": userID, "startGW": startGameweek})).
+		OrderBy("r.gameweek asc").
+		All(&rows)
+	if err != nil {
+		return page, fmt.Errorf("fetch results: %w", err)
+	}
+
+	startTotal := 0
+	suspendedBefore := false
+	indexes := make(map[int]int)
+	for _, row := range rows {
+		if row.Gameweek < startGameweek {
+			startTotal = row.TotalPoints
+			suspendedBefore = row.IsSuspendedNext
+			continue
+		}
+		indexes[row.Gameweek] = len(page.Gameweeks)
+		page.Gameweeks = append(page.Gameweeks, types.ManagerGameweek{
+			Gameweek:          row.Gameweek,
+			RawPoints:         row.RawPoints,
+			HitCost:           row.Hits * 4,
+			AdjustedPoints:    row.Points - row.Hits*4,
+			TotalPoints:       row.TotalPoints - startTotal,
+			ActiveChip:        row.ActiveChip,
+			ServingSuspension: suspendedBefore,
+			SuspendedNext:     row.IsSuspendedNext,
+		})
+		suspendedBefore = row.IsSuspendedNext
+	}
+
+	var cards []managerHistoryCard
+	err = txDao.DB().
+		Select(
+			"c.gameweek",
+			"c.type",
+			"c.userID",
+			"c.nominatorUserID",
+			"c.voided",
+			"c.voidReason",
+			"c.playerID",
+			"c.fixtureID",
+			"COALESCE(target.firstName, '') as userName",
+			"COALESCE(nominator.firstName, '') as nominatorName").
+		From("cards c").
+		LeftJoin("users target", dbx.NewExp("target.id = c.userID")).
+		LeftJoin("users nominator", dbx.NewExp("nominator.id = c.nominatorUserID")).
+		Where(dbx.NewExp("c.leagueID = {:leagueID} AND (c.userID = {:userID} OR c.nominatorUserID = {:userID})", dbx.Params{"leagueID": leagueID, "userID": userID})).
+		OrderBy("c.gameweek asc", "c.created asc").
+		All(&cards)
+	if err != nil {
+		return page, fmt.Errorf("fetch cards: %w", err)
+	}
+
+	for _, card := range cards {
+		i, ok := indexes[card.Gameweek]
+		if !ok {
+			continue
+		}
+		gameweek := &page.Gameweeks[i]
+
+		if card.UserID == userID {
+			gameweek.Cards = append(gameweek.Cards, types.ManagerCard{
+				Type:       card.Type,
+				Cause:      cardCause(txDao, manager.GetString("firstName")+"'s", card),
+				Voided:     card.Voided,
+				VoidReason: card.VoidReason,
+			})
+			if !card.Voided {
+				page.CardsReceived++
+				if card.Type == "nomination" {
+					page.NominationsReceived++
+				}
+			}
+			continue
+		}
+
+		if card.Voided {
+			continue
+		}
+		switch card.Type {
+		case "nomination":
+			gameweek.NominationsGiven = append(gameweek.NominationsGiven, card.UserName)
+			page.NominationsGiven++
+		case "reverse":
+			gameweek.ReversesLanded = append(gameweek.ReversesLanded, card.UserName)
+			page.ReversesLanded++
+		}
+	}
+
+	labels := make([]string, 0, len(page.Gameweeks))
+	totals := make([]int, 0, len(page.Gameweeks))
+	titles := make([]string, 0, len(page.Gameweeks))
+	for _, gameweek := range page.Gameweeks {
+		labels = append(labels, strconv.Itoa(gameweek.Gameweek))
+		totals = append(totals, gameweek.TotalPoints)
+		titles = append(titles, fmt.Sprintf("GW%d: %d pts", gameweek.Gameweek, gameweek.TotalPoints))
+	}
+	page.Chart = lib.LineChart(labels, totals, titles)
+
+	return page, nil
+}
+
+// ManagerHistoryGet shows every gameweek of a manager in the viewer's default
+// league: points before and after hits and suspensions, the cards they got
+// and why, and the nominations and reverses they handed out.
+func ManagerHistoryGet(c echo.Context) error {
+	userID := c.PathParam("userID")
+
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	var page types.ManagerHistoryPage
+	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		var err error
+		page, err = loadManagerHistoryPage(txDao, record, userID)
+		return err
+	})
+	if err != nil {
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		log.Printf("Transaction failed: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	return lib.Render(c, http.StatusOK, views.ManagerHistory(page))
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/cmcd97/bytesize/app/components"
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/cmcd97/bytesize/lib"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// NotificationsGet lists the signed in user's notifications they haven't
+// dismissed yet, newest first.
+func NotificationsGet(c echo.Context) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Failed to get auth record for request: %v", c.Request().RequestURI)
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthorized")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Error: PocketBase instance is nil or type assertion failed")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection error")
+	}
+
+	records, err := pb.Dao().FindRecordsByFilter(
+		lib.NotificationsCollection,
+		"userID = {:userID} && dismissed = false",
+		"-created",
+		0,
+		0,
+		dbx.Params{"userID": record.Id},
+	)
+	if err != nil {
+		log.Printf("Error finding notifications for user %s: %v", record.Id, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch notifications")
+	}
+
+	notifications := make([]types.Notification, 0, len(records))
+	for _, n := range records {
+		notifications = append(notifications, types.Notification{
+			ID:      n.Id,
+			Kind:    n.GetString("kind"),
+			Message: n.GetString("message"),
+			Created: n.Created.Time(),
+		})
+	}
+
+	return lib.Render(c, http.StatusOK, components.Notifications(notifications))
+}
+
+// NotificationDismiss hides one of the signed in user's notifications.
+func NotificationDismiss(c echo.Context) error {
+	notificationID := c.FormValue("notificationID")
+	if notificationID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "notificationID is required")
+	}
+
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Failed to get auth record for request: %v", c.Request().RequestURI)
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthorized")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Error: PocketBase instance is nil or type assertion failed")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection error")
+	}
+
+	notification, err := pb.Dao().FindFirstRecordByFilter(
+		lib.NotificationsCollection,
+		"id = {:id} && userID = {:userID}",
+		dbx.Params{"id": notificationID, "userID": record.Id},
+	)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Notification not found")
+	}
+
+	notification.Set("dismissed", true)
+	if err := pb.Dao().SaveRecord(notification); err != nil {
+		log.Printf("Error dismissing notification %s for user %s: %v", notificationID, record.Id, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to dismiss notification")
+	}
+
+	return NotificationsGet(c)
+}
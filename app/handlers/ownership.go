@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cmcd97/bytesize/lib"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/security"
+	pbtypes "github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	ownershipChallengesCollection = "ownership_challenges"
+	ownershipOverridesCollection  = "ownership_overrides"
+	// FPL team names are capped at 20 characters, so the code has to leave
+	// room for most of the user's real name
+	ownershipCodeLength          = 5
+	ownershipCodeAlphabet        = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	ownershipChallengeExpiration = time.Hour
+	ownershipOverrideExpiration  = 7 * 24 * time.Hour
+)
+
+var errOwnershipNotProven = errors.New("ownership code not found in team name")
+
+// ownershipCodePattern matches code in a team name in any case and with spaces
+// between its characters, as people tend to type it
+func ownershipCodePattern(code string) *regexp.Regexp {
+	characters := make([]string, 0, len(code))
+	for _, character := range code {
+		characters = append(characters, regexp.QuoteMeta(string(character)))
+	}
+	return regexp.MustCompile(`(?i)` + strings.Join(characters, `\s*`))
+}
+
+// ownershipCode returns the code userID has to put in teamID's FPL team name
+// to prove it's theirs, reusing an unexpired challenge so the code doesn't
+// change under them while they edit their team.
+func ownershipCode(dao *daos.Dao, userID string, teamID int) (string, error) {
+	challenge, err := dao.FindFirstRecordByFilter(
+		ownershipChallengesCollection,
+		"userID = {:userID} && teamID = {:teamID} && expires > @now",
+		dbx.Params{"userID": userID, "teamID": teamID},
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("find challenge: %w", err)
+	}
+	if challenge != nil {
+		return challenge.GetString("code"), nil
+	}
+
+	collection, err := dao.FindCollectionByNameOrId(ownershipChallengesCollection)
+	if err != nil {
+		return "", fmt.Errorf("find collection: %w", err)
+	}
+	expires, err := pbtypes.ParseDateTime(time.Now().Add(ownershipChallengeExpiration))
+	if err != nil {
+		return "", fmt.Errorf("challenge expiry: %w", err)
+	}
+
+	code := security.RandomStringWithAlphabet(ownershipCodeLength, ownershipCodeAlphabet)
+	challenge = models.NewRecord(collection)
+	challenge.Set("userID", userID)
+	challenge.Set("teamID", teamID)
+	challenge.Set("code", code)
+	challenge.Set("expires", expires)
+	if err := dao.SaveRecord(challenge); err != nil {
+		return "", fmt.Errorf("save challenge: %w", err)
+	}
+
+	return code, nil
+}
+
+// findOwnershipOverride returns the unused override a league admin granted
+// userID for teamID, or nil if there isn't one
+func findOwnershipOverride(dao *daos.Dao, teamID int, userID string) (*models.Record, error) {
+	override, err := dao.FindFirstRecordByFilter(
+		ownershipOverridesCollection,
+		"entryID = {:entryID} && userID = {:userID} && used = false && expires > @now",
+		dbx.Params{"entryID": teamID, "userID": userID},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return override, err
+}
+
+// hasOwnershipOverride reports whether a league admin has vouched for userID
+// linking teamID.
+func hasOwnershipOverride(dao *daos.Dao, teamID int, userID string) bool {
+	override, err := findOwnershipOverride(dao, teamID, userID)
+	if err != nil {
+		log.Printf("Error checking ownership override for team %d: %v", teamID, err)
+	}
+	return override != nil
+}
+
+// vouchedUsername returns the username of the user a league admin vouched for
+// to link teamID, or "" if nobody has an unused override for it
+func vouchedUsername(dao *daos.Dao, teamID int) string {
+	override, err := dao.FindFirstRecordByFilter(
+		ownershipOverridesCollection,
+		"entryID = {:entryID} && userID != '' && used = false && expires > @now",
+		dbx.Params{"entryID": teamID},
+	)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Error checking ownership override for team %d: %v", teamID, err)
+		}
+		return ""
+	}
+	user, err := dao.FindRecordById("users", override.GetString("userID"))
+	if err != nil {
+		log.Printf("Error finding user %s vouched for team %d: %v", override.GetString("userID"), teamID, err)
+		return ""
+	}
+	return user.Username()
+}
+
+// verifyOwnership checks that userID controls teamID, either because teamName
+// (freshly fetched from FPL) contains their challenge code or because a
+// league admin vouched for the team. It returns the team name with the code
+// taken back out so it can be stored as the user's real team name.
+func verifyOwnership(txDao *daos.Dao, userID string, teamID int, teamName string) (string, error) {
+	override, err := findOwnershipOverride(txDao, teamID, userID)
+	if err != nil {
+		return "", fmt.Errorf("find override: %w", err)
+	}
+	if override != nil {
+		override.Set("used", true)
+		override.Set("usedByUserID", userID)
+		if err := txDao.SaveRecord(override); err != nil {
+			return "", fmt.Errorf("save override: %w", err)
+		}
+		err := lib.WriteAuditLog(txDao, userID, "ownership_verified", userID, map[string]any{
+			"teamID":    teamID,
+			"method":    "admin_override",
+			"grantedBy": override.GetString("grantedByUserID"),
+		})
+		return teamName, err
+	}
+
+	challenge, err := txDao.FindFirstRecordByFilter(
+		ownershipChallengesCollection,
+		"userID = {:userID} && teamID = {:teamID} && expires > @now",
+		dbx.Params{"userID": userID, "teamID": teamID},
+	)
+	if err != nil {
+		return "", errOwnershipNotProven
+	}
+	pattern := ownershipCodePattern(challenge.GetString("code"))
+	if !pattern.MatchString(teamName) {
+		return "", errOwnershipNotProven
+	}
+
+	if err := txDao.DeleteRecord(challenge); err != nil {
+		return "", fmt.Errorf("delete challenge: %w", err)
+	}
+	err = lib.WriteAuditLog(txDao, userID, "ownership_verified", userID, map[string]any{
+		"teamID": teamID,
+		"method": "team_name_code",
+	})
+
+	return strings.Join(strings.Fields(pattern.ReplaceAllString(teamName, " ")), " "), err
+}
+
+// LeagueOwnershipVouch lets a league admin skip the team name check for an
+// unclaimed entry in their league, for members who can't or won't rename
+// their team. The override only lets the user the admin named link the entry,
+// so nobody else can claim it first.
+func LeagueOwnershipVouch(c echo.Context) error {
+	entryID, err := strconv.Atoi(c.FormValue("entryID"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "entryID is required")
+	}
+	username := strings.TrimSpace(c.FormValue("username"))
+	if username == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Enter the username the member signed up with")
+	}
+
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	err = pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		leagueID, err := adminLeagueID(txDao, record)
+		if err != nil {
+			return err
+		}
+
+		_, err = txDao.FindFirstRecordByFilter(
+			lib.LeagueMembersCollection,
+			"leagueID = {:leagueID} && entryID = {:entryID} && userID = '' && hasLeft = false",
+			dbx.Params{"leagueID": leagueID, "entryID": entryID},
+		)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "That team isn't an unclaimed member of this league")
+		}
+
+		user, err := txDao.FindAuthRecordByUsername("users", username)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("There's no user called %s", username))
+		}
+
+		override, err := findOwnershipOverride(txDao, entryID, user.Id)
+		if err != nil {
+			return fmt.Errorf("find override: %w", err)
+		}
+		if override == nil {
+			collection, err := txDao.FindCollectionByNameOrId(ownershipOverridesCollection)
+			if err != nil {
+				return fmt.Errorf("find collection: %w", err)
+			}
+			override = models.NewRecord(collection)
+			override.Set("entryID", entryID)
+			override.Set("leagueID", leagueID)
+			override.Set("userID", user.Id)
+			override.Set("used", false)
+		}
+		expires, err := pbtypes.ParseDateTime(time.Now().Add(ownershipOverrideExpiration))
+		if err != nil {
+			return fmt.Errorf("override expiry: %w", err)
+		}
+		override.Set("grantedByUserID", record.Id)
+		override.Set("expires", expires)
+		if err := txDao.SaveRecord(override); err != nil {
+			return fmt.Errorf("save override: %w", err)
+		}
+
+		err = lib.WriteAuditLog(txDao, record.Id, "ownership_override", user.Id, map[string]any{
+			"entryID":  entryID,
+			"leagueID": leagueID,
+		})
+		if err != nil {
+			return err
+		}
+
+		settings, err := getLeagueSettings(txDao, leagueID)
+		if err != nil {
+			return fmt.Errorf("league settings not found: %w", err)
+		}
+		return recordAdminActivity(txDao, settings)
+	})
+
+	if err != nil {
+		log.Printf("Transaction failed: %v", err)
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	return LeagueAdminsGet(c)
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/cmcd97/bytesize/lib"
+	_ "github.com/cmcd97/bytesize/migrations"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/migrate"
+)
+
+const testTeamID = 4242
+
+// fakeFPL stands in for FPL's entry endpoint, serving whatever the team is
+// currently called
+type fakeFPL struct {
+	mu       sync.Mutex
+	teamName string
+}
+
+func (f *fakeFPL) rename(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.teamName = name
+}
+
+func (f *fakeFPL) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/entry/4242/" {
+		http.NotFound(w, r)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	json.NewEncoder(w).Encode(types.FPLUser{PlayerFirstName: "Dana", PlayerLastName: "Demo", Name: f.teamName})
+}
+
+// newFakeFPL points lib.FPLAPIBase at a fake FPL server until the test ends
+func newFakeFPL(t *testing.T, teamName string) *fakeFPL {
+	t.Helper()
+
+	fake := &fakeFPL{teamName: teamName}
+	server := httptest.NewServer(fake)
+	original := lib.FPLAPIBase
+	lib.FPLAPIBase = server.URL
+	t.Cleanup(func() {
+		lib.FPLAPIBase = original
+		server.Close()
+	})
+	return fake
+}
+
+func newTestApp(t *testing.T) *pocketbase.PocketBase {
+	t.Helper()
+
+	pb := pocketbase.NewWithConfig(pocketbase.Config{DefaultDataDir: t.TempDir()})
+	if err := pb.Bootstrap(); err != nil {
+		t.Fatalf("bootstrap app: %v", err)
+	}
+	t.Cleanup(func() { pb.ResetBootstrapState() })
+
+	runner, err := migrate.NewRunner(pb.DB(), migrations.AppMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.Up(); err != nil {
+		t.Fatal(err)
+	}
+	return pb
+}
+
+// verifyFromFPL fetches the team from FPL and checks it the way linking a
+// team does
+func verifyFromFPL(pb *pocketbase.PocketBase, userID string) (string, error) {
+	entry, err := fetchFPLEntry(context.Background(), testTeamID)
+	if err != nil {
+		return "", err
+	}
+	var teamName string
+	err = pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		teamName, err = verifyOwnership(txDao, userID, testTeamID, entry.Name)
+		return err
+	})
+	return teamName, err
+}
+
+func TestOwnershipChallenge(t *testing.T) {
+	tests := []struct {
+		name     string
+		rename   func(code string) string
+		verified bool
+		want     string
+	}{
+		{"name unchanged", func(string) string { return "Dana's Destroyers" }, false, ""},
+		{"code added", func(code string) string { return "Dana's Destroyers " + code }, true, "Dana's Destroyers"},
+		{"code in lower case", func(code string) string { return strings.ToLower(code) + " Destroyers" }, true, "Destroyers"},
+		{"code spaced out", func(code string) string { return "Dana " + code[:2] + " " + strings.ToLower(code[2:]) + " FC" }, true, "Dana FC"},
+		{"code cut short", func(code string) string { return "Dana " + code[:4] }, false, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pb := newTestApp(t)
+			fpl := newFakeFPL(t, "Dana's Destroyers")
+			userID := "testuser123456"
+
+			code, err := ownershipCode(pb.Dao(), userID, testTeamID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			again, err := ownershipCode(pb.Dao(), userID, testTeamID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again != code {
+				t.Fatalf("challenge code changed from %q to %q", code, again)
+			}
+
+			fpl.rename(test.rename(code))
+			teamName, err := verifyFromFPL(pb, userID)
+			if !test.verified {
+				if !errors.Is(err, errOwnershipNotProven) {
+					t.Fatalf("verifyOwnership() error = %v, want errOwnershipNotProven", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifyOwnership() error = %v", err)
+			}
+			if teamName != test.want {
+				t.Errorf("verifyOwnership() team name = %q, want %q", teamName, test.want)
+			}
+
+			// the challenge is used up once the team is verified
+			if _, err := verifyFromFPL(pb, userID); !errors.Is(err, errOwnershipNotProven) {
+				t.Errorf("second verifyOwnership() error = %v, want errOwnershipNotProven", err)
+			}
+		})
+	}
+}
+
+func TestOwnershipOverride(t *testing.T) {
+	pb := newTestApp(t)
+	newFakeFPL(t, "Dana's Destroyers")
+	owner, rival := "owneruser12345", "rivaluser12345"
+
+	collection, err := pb.Dao().FindCollectionByNameOrId(ownershipOverridesCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	override := models.NewRecord(collection)
+	override.Set("entryID", testTeamID)
+	override.Set("userID", owner)
+	override.Set("used", false)
+	override.Set("expires", time.Now().Add(time.Hour))
+	if err := pb.Dao().SaveRecord(override); err != nil {
+		t.Fatal(err)
+	}
+
+	if hasOwnershipOverride(pb.Dao(), testTeamID, rival) {
+		t.Error("hasOwnershipOverride() = true for a user nobody vouched for")
+	}
+	if _, err := verifyFromFPL(pb, rival); !errors.Is(err, errOwnershipNotProven) {
+		t.Fatalf("verifyOwnership() for another user error = %v, want errOwnershipNotProven", err)
+	}
+
+	if !hasOwnershipOverride(pb.Dao(), testTeamID, owner) {
+		t.Error("hasOwnershipOverride() = false for the user the admin vouched for")
+	}
+	teamName, err := verifyFromFPL(pb, owner)
+	if err != nil {
+		t.Fatalf("verifyOwnership() error = %v", err)
+	}
+	if teamName != "Dana's Destroyers" {
+		t.Errorf("verifyOwnership() team name = %q, want %q", teamName, "Dana's Destroyers")
+	}
+
+	// the override is used up once the team is verified
+	if _, err := verifyFromFPL(pb, owner); !errors.Is(err, errOwnershipNotProven) {
+		t.Errorf("second verifyOwnership() error = %v, want errOwnershipNotProven", err)
+	}
+}
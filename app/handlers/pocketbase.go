@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -23,6 +24,7 @@ import (
 	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/daos"
 	"github.com/pocketbase/pocketbase/models"
+	pbtypes "github.com/pocketbase/pocketbase/tools/types"
 )
 
 const (
@@ -70,7 +72,7 @@ func UserLeaguesGet(c echo.Context) error {
 				UserID:      record.GetString("userID"),
 				AdminUserID: record.GetString("adminUserID"),
 				UserTeamID:  record.GetInt("teamID"),
-				LeagueName:  lib.ReplaceUnderscoresWithSpaces(record.GetString("leagueName")),
+				LeagueName:  leagueDisplayName(txDao, record),
 				IsLinked:    record.GetBool("isLinked"),
 				IsActive:    record.GetBool("isActive"),
 				IsDefault:   record.GetBool("isDefault"),
@@ -103,7 +105,7 @@ func SetDefaultLeague(c echo.Context) error {
 	log.Printf("Setting default league - Request started")
 
 	// Validate input
-	leagueID := c.QueryParam("leagueID")
+	leagueID := c.FormValue("leagueID")
 	if leagueID == "" {
 		log.Printf("Error: Missing leagueID parameter")
 		return echo.NewHTTPError(http.StatusBadRequest, errMissingLeagueID)
@@ -209,22 +211,16 @@ func updateDefaultLeague(c echo.Context, leagueID string) ([]types.UserLeagueSel
 
 		// Check for existing admin
 		fplLeagueID := league.GetInt("leagueID")
-		existingAdmin, err := txDao.FindFirstRecordByFilter(
-			"leagues",
-			"adminUserID != 'temp' && leagueID = {:leagueID}",
-			dbx.Params{"leagueID": fplLeagueID},
-		)
+		settings, err := getLeagueSettings(txDao, fplLeagueID)
 		if err != nil && !strings.Contains(err.Error(), "no rows") {
 			return fmt.Errorf("failed to check admin status: %w", err)
 		}
 
-		if existingAdmin != nil {
-			adminID := existingAdmin.GetString("adminUserID")
-			if adminID != "temp" {
-				hasAdmin = true
-				league.Set("adminUserID", adminID)
-				log.Printf("Setting admin ID %s for league %s", adminID, leagueID)
-			}
+		if settings != nil {
+			adminID := settings.GetString("ownerUserID")
+			hasAdmin = true
+			league.Set("adminUserID", adminID)
+			log.Printf("Setting admin ID %s for league %s", adminID, leagueID)
 		}
 
 		if err := txDao.SaveRecord(league); err != nil {
@@ -247,7 +243,7 @@ func updateDefaultLeague(c echo.Context, leagueID string) ([]types.UserLeagueSel
 				UserID:      record.GetString("userID"),
 				AdminUserID: record.GetString("adminUserID"),
 				UserTeamID:  record.GetInt("teamID"),
-				LeagueName:  lib.ReplaceUnderscoresWithSpaces(record.GetString("leagueName")),
+				LeagueName:  leagueDisplayName(txDao, record),
 				IsLinked:    record.GetBool("isLinked"),
 				IsActive:    record.GetBool("isActive"),
 				IsDefault:   record.GetBool("isDefault"),
@@ -297,6 +293,18 @@ func InitialiseLeague(c echo.Context) error {
 		}
 		log.Printf("Found league record with ID: %s", leagueID)
 
+		// Someone else may have linked the league since the prompt was shown
+		fplLeagueID := newLeagueRecord.GetInt("leagueID")
+		if _, err := getLeagueSettings(txDao, fplLeagueID); err == nil {
+			return echo.NewHTTPError(http.StatusConflict, "This league already has an admin")
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to check admin status: %w", err)
+		}
+
+		if _, err := createLeagueSettings(txDao, fplLeagueID, authUserID); err != nil {
+			return err
+		}
+
 		// Update league settings
 		newLeagueRecord.Set("isDefault", true)
 		newLeagueRecord.Set("isActive", true)
@@ -385,7 +393,7 @@ func getDefaultLeague(txDao *daos.Dao, teamID interface{}) (*models.Record, erro
 // getLeagueMembers retrieves all members of a league
 func getLeagueMembers(txDao *daos.Dao, leagueID int) ([]int, error) {
 	leagueRecords, err := txDao.FindRecordsByExpr(leaguesCollection,
-		dbx.NewExp("leagueID = {:leagueID}", dbx.Params{"leagueID": leagueID}))
+		dbx.NewExp("leagueID = {:leagueID} AND hasLeft = FALSE", dbx.Params{"leagueID": leagueID}))
 	if err != nil {
 		return nil, fmt.Errorf("find league members: %w", err)
 	}
@@ -451,7 +459,7 @@ func getFinished(c echo.Context, gameweek int) (bool, error) {
 		Timeout: 10 * time.Second,
 	}
 
-	endpoint := "https://fantasy.premierleague.com/api/bootstrap-static/"
+	endpoint := lib.FPLAPIBase + "/bootstrap-static/"
 	resp, err := client.Get(endpoint)
 	if err != nil {
 		log.Printf("[ERROR] API request failed: %v", err)
@@ -642,12 +650,14 @@ func UserCardsGet(c echo.Context) error {
 		}
 
 		err = txDao.DB().
-			Select("cards.*", "users.hasReverse as userHasReverse").
+			Select("cards.*", "users.hasReverse as userHasReverse", "COALESCE(results.activeChip, '') as activeChip").
 			From("cards").
 			Where(dbx.NewExp("cards.teamID = {:team_id} AND cards.leagueID = {:league_id}", dbx.Params{"team_id": teamID, "league_id": leagueID})).
-			AndWhere(dbx.NewExp("adminVerified = FALSE")).
+			AndWhere(dbx.NewExp("adminVerified = FALSE AND voided = FALSE")).
 			LeftJoin("users", dbx.NewExp("cards.userID = users.id")).
-			OrderBy("gameweek asc").
+			// the chip the manager played in the card's gameweek
+			LeftJoin("results", dbx.NewExp("results.userID = cards.userID AND results.gameweek = cards.gameweek")).
+			OrderBy("cards.gameweek asc").
 			All(&cards)
 
 		if err != nil {
@@ -739,6 +749,7 @@ func LeagueStandingsGet(c echo.Context) error {
 		}
 
 		gameweek = gameweekNum
+		startGameweek := leagueStartGameweek(txDao, leagueID)
 
 		interfaceTeamIDs := make([]interface{}, len(teamIDs))
 		for i, id := range teamIDs {
@@ -747,19 +758,24 @@ func LeagueStandingsGet(c echo.Context) error {
 
 		err = txDao.DB().
 			Select(
-				"ROW_NUMBER() OVER (ORDER BY ag.totalPoints desc) as position",
+				"ROW_NUMBER() OVER (ORDER BY ag.totalPoints - COALESCE(start.totalPoints, 0) desc) as position",
 				"u.firstName",
 				"u.lastName",
 				"u.teamName",
 				"ag.points as gameweekPoints",
-				"ag.totalPoints",
-				"(SELECT COUNT(*) FROM cards c2 WHERE c2.userID = ag.userID AND c2.adminVerified = FALSE) as cardCount",
-				"COALESCE((SELECT isSuspendedNext FROM aggregated_results WHERE userID = ag.userID AND gameweek = {:maxGW} - 1), FALSE) as isSuspended").
+				"ag.totalPoints - COALESCE(start.totalPoints, 0) as totalPoints",
+				"(SELECT COUNT(*) FROM cards c2 WHERE c2.userID = ag.userID AND c2.adminVerified = FALSE AND c2.voided = FALSE) as cardCount",
+				"COALESCE((SELECT isSuspendedNext FROM aggregated_results WHERE userID = ag.userID AND gameweek = {:maxGW} - 1), FALSE) as isSuspended",
+				"COALESCE(r.activeChip, '') as activeChip",
+				"ag.userID").
 			From("aggregated_results ag").
 			LeftJoin("users u", dbx.NewExp("ag.userID = u.id")).
+			LeftJoin("results r", dbx.NewExp("r.userID = ag.userID AND r.gameweek = ag.gameweek")).
+			// points scored before the league's start gameweek don't count
+			LeftJoin("aggregated_results start", dbx.NewExp("start.userID = ag.userID AND start.gameweek = {:startGW} - 1", dbx.Params{"startGW": startGameweek})).
 			Where(dbx.NewExp("ag.gameweek = {:maxGW}", dbx.Params{"maxGW": gameweekNum})).
 			AndWhere(dbx.In("ag.teamID", interfaceTeamIDs...)).
-			OrderBy("ag.totalPoints desc").
+			OrderBy("totalPoints desc").
 			All(&leagueRows)
 
 		if err != nil {
@@ -779,6 +795,16 @@ func LeagueStandingsGet(c echo.Context) error {
 	return lib.Render(c, http.StatusOK, components.LeagueTable(leagueRows, gameweek))
 }
 
+// eventCard reads what ExplainCard needs from a card earned from match stats
+func eventCard(card *models.Record) types.DatabaseCard {
+	return types.DatabaseCard{
+		Type:      card.GetString("type"),
+		Gameweek:  card.GetInt("gameweek"),
+		PlayerID:  card.GetInt("playerID"),
+		FixtureID: card.GetInt("fixtureID"),
+	}
+}
+
 func CardSubmitPreview(c echo.Context) error {
 	cardHash := c.FormValue("cardHash")
 
@@ -809,6 +835,7 @@ func CardSubmitPreview(c echo.Context) error {
 	nominatorTeamID := card.GetInt("nominatorTeamID")
 	cardGameweek := card.GetInt("gameweek")
 	cardType := card.Get("type")
+	fineDescription := leagueFineDescription(pb.Dao(), card.GetInt("leagueID"))
 
 	if nominatorTeamID != 0 {
 		nominator, err := pb.Dao().FindFirstRecordByFilter(
@@ -828,17 +855,12 @@ func CardSubmitPreview(c echo.Context) error {
 			msg = fmt.Sprintf("Reverse by %s in gameweek %d", nominator.GetString("firstName"), cardGameweek)
 		}
 
-		return lib.Render(c, http.StatusOK, components.SubmitPreview(msg, cardHash))
+		return lib.Render(c, http.StatusOK, components.SubmitPreview(msg, fineDescription, cardHash))
 	}
 
 	if nominatorTeamID == 0 {
-		var msg string
-		if cardType == "own_goals" {
-			msg = fmt.Sprintf("an own goal in gameweek %d", cardGameweek)
-		} else {
-			msg = fmt.Sprintf("a red card in gameweek %d", cardGameweek)
-		}
-		return lib.Render(c, http.StatusOK, components.SubmitPreview(msg, cardHash))
+		msg := lib.ExplainCard(pb.Dao(), "Your", eventCard(card))
+		return lib.Render(c, http.StatusOK, components.SubmitPreview(msg, fineDescription, cardHash))
 	}
 
 	return nil
@@ -993,6 +1015,12 @@ func ReverseCard(c echo.Context) error {
 	}
 	log.Printf("Card with hash %s successfully reversed", cardHash)
 
+	// the card is reversed either way, the ETL's next sync moves its charge
+	// over if this fails
+	if err := chargeLeagueFines(pb.Dao(), card.GetInt("leagueID")); err != nil {
+		log.Printf("Error charging fines for reversed card %s: %v", cardHash, err)
+	}
+
 	return lib.HtmxRedirect(c, "/app/profile")
 }
 
@@ -1030,8 +1058,9 @@ func AdminVerifications(c echo.Context) error {
 		leagueID := defaultLeague.GetInt("leagueID")
 		log.Printf("Found league ID: %v", leagueID)
 
-		// Check if the authenticated user is the admin of the league
-		if record.Id != defaultLeague.GetString("adminUserID") {
+		// Check if the authenticated user is an admin of the league
+		settings, err := getLeagueSettings(txDao, leagueID)
+		if err != nil || !isLeagueAdmin(settings, record.Id) {
 			log.Printf("User %s is not the admin of league %v", record.Id, leagueID)
 			return echo.NewHTTPError(http.StatusForbidden, "You are not authorized to view this page")
 		}
@@ -1043,7 +1072,7 @@ func AdminVerifications(c echo.Context) error {
 				"U.firstName as person").
 			From("cards C").
 			LeftJoin("users U", dbx.NewExp("C.userID = U.ID")).
-			Where(dbx.NewExp("leagueID= {:leagueID}", dbx.Params{"leagueID": leagueID})).
+			Where(dbx.NewExp("leagueID= {:leagueID} AND voided = FALSE", dbx.Params{"leagueID": leagueID})).
 			All(&cards)
 
 		if err != nil {
@@ -1051,6 +1080,18 @@ func AdminVerifications(c echo.Context) error {
 			return fmt.Errorf("fetch standings: %w", err)
 		}
 		log.Printf("League standings fetched successfully for leagueID: %v", leagueID)
+
+		for i, card := range cards {
+			if card.NominatorTeamID != 0 {
+				continue
+			}
+			cards[i].Description = lib.ExplainCard(txDao, card.Person+"'s", types.DatabaseCard{
+				Type:      card.Type,
+				Gameweek:  card.Gameweek,
+				PlayerID:  card.PlayerID,
+				FixtureID: card.FixtureID,
+			})
+		}
 		return nil
 	})
 
@@ -1116,12 +1157,12 @@ func ApprovalPreview(c echo.Context) error {
 	}
 
 	if nominatorTeamID == 0 {
-		var msg string
-		if cardType == "own_goals" {
-			msg = fmt.Sprintf("an own goal in gameweek %d", cardGameweek)
-		} else {
-			msg = fmt.Sprintf("a red card in gameweek %d", cardGameweek)
+		owner, err := pb.Dao().FindRecordById("users", card.GetString("userID"))
+		if err != nil {
+			log.Printf("query failed: %v", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
 		}
+		msg := lib.ExplainCard(pb.Dao(), owner.GetString("firstName")+"'s", eventCard(card))
 		return lib.Render(c, http.StatusOK, components.ApprovalPreview(msg, cardHash))
 	}
 
@@ -1157,13 +1198,25 @@ func ApproveCard(c echo.Context) error {
 	}
 	log.Printf("Found card with hash: %s", cardHash)
 
+	settings, err := getLeagueSettings(pb.Dao(), card.GetInt("leagueID"))
+	if err != nil || !isLeagueAdmin(settings, record.Id) {
+		log.Printf("User %s is not an admin of league %d", record.Id, card.GetInt("leagueID"))
+		return echo.NewHTTPError(http.StatusForbidden, "You are not authorized to approve this card")
+	}
+
 	card.Set("adminVerified", true)
+	card.Set("fineApprovedBy", record.Id)
+	card.Set("fineApprovedAt", pbtypes.NowDateTime())
 	if err := pb.Dao().SaveRecord(card); err != nil {
 		log.Printf("Error saving card with hash %s: %v", cardHash, err)
 		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to save card: %v", err))
 	}
 	log.Printf("Card with hash %s marked as admin verified", cardHash)
 
+	if err := recordAdminActivity(pb.Dao(), settings); err != nil {
+		log.Printf("Error recording admin activity for league %d: %v", card.GetInt("leagueID"), err)
+	}
+
 	// Redirect to home page after submitting
 	log.Println("Redirecting to /app/profile")
 	// return lib.HtmxRedirect(c, "/app/profile")
@@ -1211,7 +1264,7 @@ func SingleNominationGet(c echo.Context) error {
 				"l.teamID as userTeamID").
 			From("leagues l").
 			LeftJoin("users U", dbx.NewExp("l.userID = U.ID")).
-			Where(dbx.NewExp("leagueID= {:leagueID}", dbx.Params{"leagueID": leagueID})).
+			Where(dbx.NewExp("leagueID= {:leagueID} AND l.hasLeft = FALSE", dbx.Params{"leagueID": leagueID})).
 			OrderBy("userName asc").
 			All(&members)
 
@@ -1252,6 +1305,7 @@ func SingleNominationPost(c echo.Context) error {
 	selectedUserID := c.FormValue("selectedUser")
 	log.Printf("Selected user ID: %s", selectedUserID)
 
+	var leagueID int
 	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
 		teamID := record.GetInt("teamID")
 		nominatorUserID := record.GetString("id")
@@ -1262,7 +1316,7 @@ func SingleNominationPost(c echo.Context) error {
 		if err != nil {
 			return fmt.Errorf("default league not found: %w", err)
 		}
-		leagueID := defaultLeague.GetInt("leagueID")
+		leagueID = defaultLeague.GetInt("leagueID")
 		gameweekNum, err := getMaxGameweek(txDao)
 		if err != nil {
 			return err
@@ -1319,6 +1373,12 @@ func SingleNominationPost(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
 	}
 
+	// the nomination stands either way, the ETL's next sync charges it if
+	// this fails
+	if err := chargeLeagueFines(pb.Dao(), leagueID); err != nil {
+		log.Printf("Error charging fines for nomination in league %d: %v", leagueID, err)
+	}
+
 	return lib.HtmxRedirect(c, "/app/profile")
 }
 
@@ -1338,6 +1398,8 @@ func RandomNominationGet(c echo.Context) error {
 	log.Println("Database connection established")
 
 	var members []types.LeagueMembers
+	var enabled bool
+	var count int
 	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
 		teamID := record.Get("teamID")
 		if teamID == nil {
@@ -1356,6 +1418,14 @@ func RandomNominationGet(c echo.Context) error {
 		leagueID := defaultLeague.GetInt("leagueID")
 		log.Printf("Found league ID: %v", leagueID)
 
+		enabled, count, err = randomNominationSettings(txDao, leagueID)
+		if err != nil {
+			return fmt.Errorf("league settings: %w", err)
+		}
+		if !enabled {
+			return nil
+		}
+
 		err = txDao.DB().
 			Select(
 				"concat(U.firstName, ' ', U.lastName) as userName",
@@ -1364,7 +1434,7 @@ func RandomNominationGet(c echo.Context) error {
 				"l.teamID as userTeamID").
 			From("leagues l").
 			LeftJoin("users U", dbx.NewExp("l.userID = U.ID")).
-			Where(dbx.NewExp("leagueID= {:leagueID}", dbx.Params{"leagueID": leagueID})).
+			Where(dbx.NewExp("leagueID= {:leagueID} AND l.hasLeft = FALSE", dbx.Params{"leagueID": leagueID})).
 			OrderBy("userName asc").
 			All(&members)
 
@@ -1381,9 +1451,13 @@ func RandomNominationGet(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
 	}
 
+	if !enabled {
+		return lib.Render(c, http.StatusOK, components.NominationUnavailable("Your league admins have turned off random nominations."))
+	}
+
 	log.Println("Rendering dropdown")
 
-	finalMembers := getRandomMembers(c, members, 3)
+	finalMembers := getRandomMembers(c, members, count)
 
 	return lib.Render(c, http.StatusOK, components.RandomNominate(finalMembers))
 }
@@ -1398,7 +1472,7 @@ func getRandomMembers(c echo.Context, members []types.LeagueMembers, count int)
 	if cookie, err := c.Cookie(randomMembersCookie); err == nil {
 		decodedValue, _ := url.QueryUnescape(cookie.Value)
 		var cookieMembers []types.LeagueMembers
-		if err := json.Unmarshal([]byte(decodedValue), &cookieMembers); err == nil && len(cookieMembers) > 0 {
+		if err := json.Unmarshal([]byte(decodedValue), &cookieMembers); err == nil && len(cookieMembers) > 0 && len(cookieMembers) == min(count, len(members)) {
 			log.Printf("Using %d members from cookie", len(cookieMembers))
 			return cookieMembers
 		}
@@ -1456,12 +1530,7 @@ func RandomNominationPost(c echo.Context) error {
 	}
 	log.Println("Database connection established")
 
-	selectedUsers := []string{
-		c.FormValue("selectedUser0"),
-		c.FormValue("selectedUser1"),
-		c.FormValue("selectedUser2"),
-	}
-
+	var leagueID int
 	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
 		teamID := record.GetInt("teamID")
 		nominatorUserID := record.GetString("id")
@@ -1472,7 +1541,18 @@ func RandomNominationPost(c echo.Context) error {
 		if err != nil {
 			return fmt.Errorf("default league not found: %w", err)
 		}
-		leagueID := defaultLeague.GetInt("leagueID")
+		leagueID = defaultLeague.GetInt("leagueID")
+		enabled, count, err := randomNominationSettings(txDao, leagueID)
+		if err != nil {
+			return fmt.Errorf("league settings: %w", err)
+		}
+		if !enabled {
+			return echo.NewHTTPError(http.StatusForbidden, "Random nominations are turned off for this league")
+		}
+		selectedUsers := make([]string, count)
+		for i := range selectedUsers {
+			selectedUsers[i] = c.FormValue(fmt.Sprintf("selectedUser%d", i))
+		}
 		gameweekNum, err := getMaxGameweek(txDao)
 		if err != nil {
 			return err
@@ -1512,9 +1592,19 @@ func RandomNominationPost(c echo.Context) error {
 
 	if err != nil {
 		log.Printf("Transaction failed: %v", err)
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process nominations: %v", err))
 	}
 
+	// the nominations stand either way, the ETL's next sync charges them if
+	// this fails
+	if err := chargeLeagueFines(pb.Dao(), leagueID); err != nil {
+		log.Printf("Error charging fines for nominations in league %d: %v", leagueID, err)
+	}
+
 	return lib.HtmxRedirect(c, "/app/profile")
 }
 
@@ -1573,36 +1663,3 @@ func RunETL(c echo.Context) error {
 		"duration": duration.String(),
 	})
 }
-
-func ResetAllHasReverse(c echo.Context) error {
-	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
-	if !ok || pb == nil {
-		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
-		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
-	}
-	log.Println("Starting reset of hasReverse for all users...")
-	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
-		users, err := txDao.FindRecordsByExpr("users", dbx.NewExp("1=1"))
-		if err != nil {
-			log.Printf("Failed to fetch users: %v", err)
-			return err
-		}
-		log.Printf("Found %d users to update.", len(users))
-		for _, user := range users {
-			userID := user.GetString("id")
-			user.Set("hasReverse", false)
-			if err := txDao.SaveRecord(user); err != nil {
-				log.Printf("Failed to update user %s: %v", userID, err)
-				return err
-			}
-			log.Printf("Reset hasReverse for user %s", userID)
-		}
-		return nil
-	})
-	if err != nil {
-		log.Printf("Error resetting hasReverse for all users: %v", err)
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to reset hasReverse for all users")
-	}
-	log.Println("Successfully reset hasReverse for all users.")
-	return c.JSON(http.StatusOK, map[string]interface{}{"status": "success", "message": "All users' hasReverse reset to false."})
-}
@@ -36,15 +36,15 @@ func ProfileGet(c echo.Context) error {
 	case float64:
 		teamIDValue = int(v)
 	case nil:
-		return lib.Render(c, StatusOK, views.Setup())
+		return lib.Render(c, StatusOK, views.Setup(pendingInvite(c)))
 	default:
 		log.Printf("Unexpected teamID type: %T", v)
-		return lib.Render(c, StatusOK, views.Setup())
+		return lib.Render(c, StatusOK, views.Setup(pendingInvite(c)))
 	}
 
 	// Check for zero value
 	if teamIDValue == 0 {
-		return lib.Render(c, StatusOK, views.Setup())
+		return lib.Render(c, StatusOK, views.Setup(pendingInvite(c)))
 	}
 
 	return lib.Render(c, StatusOK, views.Profile(record))
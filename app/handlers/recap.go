@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/cmcd97/bytesize/app/views"
+	"github.com/cmcd97/bytesize/lib"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// requestedGameweekRecap reads the recap of the gameweek asked for from the
+// viewer's default league, the latest one when none is.
+func requestedGameweekRecap(c echo.Context) (types.GameweekRecap, []int, error) {
+	var recap types.GameweekRecap
+
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return recap, nil, echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return recap, nil, echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	gameweek := 0
+	if param := c.QueryParam("gameweek"); param != "" {
+		value, err := strconv.Atoi(param)
+		if err != nil || value < 1 {
+			return recap, nil, echo.NewHTTPError(http.StatusBadRequest, "gameweek must be a gameweek number")
+		}
+		gameweek = value
+	}
+
+	var gameweeks []int
+	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		defaultLeague, err := getDefaultLeague(txDao, record.Get("teamID"))
+		if err != nil {
+			return fmt.Errorf("default league not found: %w", err)
+		}
+		recap, gameweeks, err = lib.GetGameweekRecap(txDao, defaultLeague.GetInt("leagueID"), gameweek)
+		if err != nil {
+			return err
+		}
+		if recap.Gameweek != 0 {
+			// the league may have been renamed since
+			recap.LeagueName = leagueDisplayName(txDao, defaultLeague)
+		}
+		return nil
+	})
+	if err != nil {
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return recap, nil, httpErr
+		}
+		log.Printf("Transaction failed: %v", err)
+		return recap, nil, echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+	return recap, gameweeks, nil
+}
+
+// RecapGet shows the recap of a gameweek in the viewer's league, with its
+// Markdown to copy into the group chat and its image to share.
+func RecapGet(c echo.Context) error {
+	recap, gameweeks, err := requestedGameweekRecap(c)
+	if err != nil {
+		return err
+	}
+
+	page := types.GameweekRecapPage{Recap: recap, Gameweeks: gameweeks}
+	if recap.Gameweek != 0 {
+		page.Markdown = lib.RecapMarkdown(recap)
+	}
+	return lib.Render(c, http.StatusOK, views.GameweekRecap(page))
+}
+
+// RecapMarkdownGet returns the recap of a gameweek as Markdown
+func RecapMarkdownGet(c echo.Context) error {
+	recap, _, err := requestedGameweekRecap(c)
+	if err != nil {
+		return err
+	}
+	if recap.Gameweek == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "No recap for that gameweek")
+	}
+	return c.Blob(http.StatusOK, "text/markdown; charset=utf-8", []byte(lib.RecapMarkdown(recap)))
+}
+
+// RecapImageGet returns the recap of a gameweek drawn as a PNG card
+func RecapImageGet(c echo.Context) error {
+	recap, _, err := requestedGameweekRecap(c)
+	if err != nil {
+		return err
+	}
+	if recap.Gameweek == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "No recap for that gameweek")
+	}
+
+	image, err := lib.RecapPNG(recap)
+	if err != nil {
+		log.Printf("Failed to draw recap image: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to draw the recap image")
+	}
+	c.Response().Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"gw%d-recap.png\"", recap.Gameweek))
+	return c.Blob(http.StatusOK, "image/png", image)
+}
@@ -0,0 +1,544 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/cmcd97/bytesize/app/views"
+	"github.com/cmcd97/bytesize/lib"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+const (
+	teamRelinksCollection = "team_relinks"
+
+	relinkStatusPending   = "pending"
+	relinkStatusApplied   = "applied"
+	relinkStatusRejected  = "rejected"
+	relinkStatusCancelled = "cancelled"
+)
+
+func toTeamRelink(txDao *daos.Dao, relink *models.Record) types.TeamRelink {
+	result := types.TeamRelink{
+		ID:             relink.Id,
+		OldTeamID:      relink.GetInt("oldTeamID"),
+		NewTeamID:      relink.GetInt("newTeamID"),
+		NewTeamName:    relink.GetString("newTeamName"),
+		Status:         relink.GetString("status"),
+		PendingLeagues: len(relink.GetStringSlice("pendingLeagueIDs")),
+		Created:        relink.Created.Time(),
+	}
+	if user, err := txDao.FindRecordById("users", relink.GetString("userID")); err == nil {
+		result.UserName = user.GetString("firstName") + " " + user.GetString("lastName")
+	}
+	return result
+}
+
+// relinkApprovingLeagues lists the linked leagues whose admins have to approve
+// a re-link by userID. Leagues the user runs themselves don't need approval.
+func relinkApprovingLeagues(txDao *daos.Dao, userID string) ([]string, error) {
+	rows, err := txDao.FindRecordsByFilter(
+		leaguesCollection,
+		"userID = {:userID} && hasLeft = false",
+		"",
+		0,
+		0,
+		dbx.Params{"userID": userID},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("find leagues: %w", err)
+	}
+
+	var leagueIDs []string
+	for _, row := range rows {
+		leagueID := row.GetInt("leagueID")
+		settings, err := getLeagueSettings(txDao, leagueID)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("league settings: %w", err)
+		}
+		if isLeagueAdmin(settings, userID) {
+			continue
+		}
+		if id := strconv.Itoa(leagueID); !slices.Contains(leagueIDs, id) {
+			leagueIDs = append(leagueIDs, id)
+		}
+	}
+
+	return leagueIDs, nil
+}
+
+// applyTeamRelink moves everything stored against the user's old team ID over
+// to the new one. It runs inside the caller's transaction so a failure leaves
+// the old team fully intact.
+func applyTeamRelink(txDao *daos.Dao, relink *models.Record, actorID string) error {
+	userID := relink.GetString("userID")
+	oldTeamID := relink.GetInt("oldTeamID")
+	newTeamID := relink.GetInt("newTeamID")
+
+	// Someone may have signed up with the new team while this was pending
+	claimed, err := txDao.FindFirstRecordByFilter(
+		"users",
+		"teamID = {:teamID} && id != {:userID}",
+		dbx.Params{"teamID": newTeamID, "userID": userID},
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("check team id: %w", err)
+	}
+	if claimed != nil {
+		return echo.NewHTTPError(http.StatusConflict, "That FPL team has been linked by someone else")
+	}
+
+	updates := []struct {
+		table string
+		set   dbx.Params
+		where dbx.HashExp
+	}{
+		{"results", dbx.Params{"teamID": newTeamID}, dbx.HashExp{"userID": userID}},
+		{"aggregated_results", dbx.Params{"teamID": newTeamID}, dbx.HashExp{"userID": userID}},
+		{"cards", dbx.Params{"teamID": newTeamID}, dbx.HashExp{"userID": userID}},
+		{"cards", dbx.Params{"nominatorTeamID": newTeamID}, dbx.HashExp{"nominatorUserID": userID}},
+		{leaguesCollection, dbx.Params{"teamID": newTeamID}, dbx.HashExp{"userID": userID}},
+		// the next league sync claims the new entry
+		{lib.LeagueMembersCollection, dbx.Params{"userID": ""}, dbx.HashExp{"userID": userID}},
+	}
+	for _, update := range updates {
+		if _, err := txDao.DB().Update(update.table, update.set, update.where).Execute(); err != nil {
+			return fmt.Errorf("migrate %s: %w", update.table, err)
+		}
+	}
+
+	user, err := txDao.FindRecordById("users", userID)
+	if err != nil {
+		return fmt.Errorf("find user: %w", err)
+	}
+	user.Set("teamID", newTeamID)
+	user.Set("firstName", relink.GetString("firstName"))
+	user.Set("lastName", relink.GetString("lastName"))
+	user.Set("teamName", relink.GetString("newTeamName"))
+	if err := txDao.SaveRecord(user); err != nil {
+		return fmt.Errorf("save user: %w", err)
+	}
+
+	relink.Set("status", relinkStatusApplied)
+	if err := txDao.SaveRecord(relink); err != nil {
+		return fmt.Errorf("save relink: %w", err)
+	}
+	log.Printf("Re-linked user %s from team %d to %d", userID, oldTeamID, newTeamID)
+
+	return lib.WriteAuditLog(txDao, actorID, "team_relink_applied", userID, map[string]any{
+		"relinkID":  relink.Id,
+		"oldTeamID": oldTeamID,
+		"newTeamID": newTeamID,
+	})
+}
+
+func loadTeamPage(txDao *daos.Dao, record *models.Record) (types.TeamPage, error) {
+	page := types.TeamPage{
+		TeamID:   record.GetInt("teamID"),
+		TeamName: record.GetString("teamName"),
+	}
+
+	relinks, err := txDao.FindRecordsByFilter(
+		teamRelinksCollection,
+		"userID = {:userID}",
+		"-created",
+		10,
+		0,
+		dbx.Params{"userID": record.Id},
+	)
+	if err != nil {
+		return page, fmt.Errorf("fetch relinks: %w", err)
+	}
+
+	for _, relink := range relinks {
+		item := toTeamRelink(txDao, relink)
+		if item.Status == relinkStatusPending && page.Pending == nil {
+			page.Pending = &item
+			continue
+		}
+		page.History = append(page.History, item)
+	}
+
+	return page, nil
+}
+
+func TeamGet(c echo.Context) error {
+	return renderTeamPage(c, nil)
+}
+
+// renderTeamPage renders the team page, along with the ownership code to put
+// in the new team's name when a re-link is waiting on one.
+func renderTeamPage(c echo.Context, challenge *types.OwnershipChallenge) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	var page types.TeamPage
+	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		// re-read the user, a re-link may have been applied since the request started
+		user, err := txDao.FindRecordById("users", record.Id)
+		if err != nil {
+			return fmt.Errorf("find user: %w", err)
+		}
+		page, err = loadTeamPage(txDao, user)
+		return err
+	})
+	if err != nil {
+		log.Printf("Transaction failed: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	page.Challenge = challenge
+
+	return lib.Render(c, http.StatusOK, views.Team(page))
+}
+
+// TeamRelinkRequest asks to move the user's history over to another FPL team.
+// The new entry has to exist, be unclaimed and carry the user's ownership code
+// in its name. Admins of the user's linked leagues approve it, or it's applied
+// straight away if there aren't any.
+func TeamRelinkRequest(c echo.Context) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	newTeamID, err := strconv.Atoi(c.FormValue("teamID"))
+	if err != nil || newTeamID <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid team ID format")
+	}
+	if newTeamID == record.GetInt("teamID") {
+		return echo.NewHTTPError(http.StatusBadRequest, "That's already your team")
+	}
+
+	entry, err := fetchFPLEntry(c.Request().Context(), newTeamID)
+	if errors.Is(err, errFPLEntryNotFound) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Couldn't find that FPL team")
+	}
+	if err != nil {
+		log.Printf("Error fetching FPL entry %d: %v", newTeamID, err)
+		return echo.NewHTTPError(http.StatusBadGateway, "Couldn't reach FPL, try again later")
+	}
+
+	// The new team has to be proven the same way as when it was first linked
+	code := ""
+	if !hasOwnershipOverride(pb.Dao(), newTeamID, record.Id) {
+		code, err = ownershipCode(pb.Dao(), record.Id, newTeamID)
+		if err != nil {
+			log.Printf("Error creating ownership code: %v", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start re-link")
+		}
+	}
+
+	err = pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		existing, err := txDao.FindFirstRecordByFilter(
+			teamRelinksCollection,
+			"userID = {:userID} && status = {:status}",
+			dbx.Params{"userID": record.Id, "status": relinkStatusPending},
+		)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("find relink: %w", err)
+		}
+		if existing != nil {
+			return echo.NewHTTPError(http.StatusConflict, "You already have a re-link waiting for approval")
+		}
+
+		claimed, err := txDao.FindFirstRecordByData("users", "teamID", newTeamID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("check team id: %w", err)
+		}
+		if claimed != nil {
+			return echo.NewHTTPError(http.StatusConflict, "team_id already in use")
+		}
+
+		teamName, err := verifyOwnership(txDao, record.Id, newTeamID, entry.Name)
+		if err != nil {
+			return err
+		}
+
+		pendingLeagueIDs, err := relinkApprovingLeagues(txDao, record.Id)
+		if err != nil {
+			return err
+		}
+
+		collection, err := txDao.FindCollectionByNameOrId(teamRelinksCollection)
+		if err != nil {
+			return fmt.Errorf("find collection: %w", err)
+		}
+		relink := models.NewRecord(collection)
+		relink.Set("userID", record.Id)
+		relink.Set("oldTeamID", record.GetInt("teamID"))
+		relink.Set("newTeamID", newTeamID)
+		relink.Set("newTeamName", teamName)
+		relink.Set("firstName", entry.PlayerFirstName)
+		relink.Set("lastName", entry.PlayerLastName)
+		relink.Set("pendingLeagueIDs", pendingLeagueIDs)
+		relink.Set("status", relinkStatusPending)
+		if err := txDao.SaveRecord(relink); err != nil {
+			return fmt.Errorf("save relink: %w", err)
+		}
+
+		err = lib.WriteAuditLog(txDao, record.Id, "team_relink_requested", record.Id, map[string]any{
+			"relinkID":         relink.Id,
+			"oldTeamID":        record.GetInt("teamID"),
+			"newTeamID":        newTeamID,
+			"pendingLeagueIDs": pendingLeagueIDs,
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(pendingLeagueIDs) == 0 {
+			return applyTeamRelink(txDao, relink, record.Id)
+		}
+		return nil
+	})
+
+	if errors.Is(err, errOwnershipNotProven) {
+		return renderTeamPage(c, &types.OwnershipChallenge{
+			TeamID:   newTeamID,
+			TeamName: entry.Name,
+			Code:     code,
+		})
+	}
+	if err != nil {
+		log.Printf("Transaction failed: %v", err)
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	return TeamGet(c)
+}
+
+func TeamRelinkCancel(c echo.Context) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		relink, err := txDao.FindFirstRecordByFilter(
+			teamRelinksCollection,
+			"userID = {:userID} && status = {:status}",
+			dbx.Params{"userID": record.Id, "status": relinkStatusPending},
+		)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "No re-link to cancel")
+		}
+
+		relink.Set("status", relinkStatusCancelled)
+		if err := txDao.SaveRecord(relink); err != nil {
+			return fmt.Errorf("save relink: %w", err)
+		}
+		return lib.WriteAuditLog(txDao, record.Id, "team_relink_cancelled", record.Id, map[string]any{
+			"relinkID": relink.Id,
+		})
+	})
+
+	if err != nil {
+		log.Printf("Transaction failed: %v", err)
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	return TeamGet(c)
+}
+
+// LeagueRelinksGet renders the re-links waiting on the admins of the caller's
+// default league. It's empty for members who aren't admins.
+func LeagueRelinksGet(c echo.Context) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	var relinks []types.TeamRelink
+	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		leagueID, err := adminLeagueID(txDao, record)
+		if err != nil {
+			return err
+		}
+
+		pending, err := txDao.FindRecordsByFilter(
+			teamRelinksCollection,
+			"status = {:status} && pendingLeagueIDs ~ {:leagueID}",
+			"created",
+			0,
+			0,
+			dbx.Params{"status": relinkStatusPending, "leagueID": strconv.Itoa(leagueID)},
+		)
+		if err != nil {
+			return fmt.Errorf("fetch relinks: %w", err)
+		}
+
+		for _, relink := range pending {
+			// the ~ filter is a substring match, so confirm the exact league
+			if slices.Contains(relink.GetStringSlice("pendingLeagueIDs"), strconv.Itoa(leagueID)) {
+				relinks = append(relinks, toTeamRelink(txDao, relink))
+			}
+		}
+		return nil
+	})
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) && httpErr.Code == http.StatusForbidden {
+		return c.NoContent(http.StatusOK)
+	}
+	if err != nil {
+		log.Printf("Transaction failed: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	return lib.Render(c, http.StatusOK, views.LeagueRelinks(relinks))
+}
+
+type relinkDecision func(txDao *daos.Dao, relink *models.Record, leagueID string, actorID string) error
+
+// decideRelink runs decision for a pending re-link that's waiting on the
+// caller's default league, then re-renders the admin's list.
+func decideRelink(c echo.Context, decision relinkDecision) error {
+	relinkID := c.FormValue("relinkID")
+	if relinkID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "relinkID is required")
+	}
+
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		leagueID, err := adminLeagueID(txDao, record)
+		if err != nil {
+			return err
+		}
+
+		relink, err := txDao.FindRecordById(teamRelinksCollection, relinkID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Re-link not found")
+		}
+		if relink.GetString("status") != relinkStatusPending ||
+			!slices.Contains(relink.GetStringSlice("pendingLeagueIDs"), strconv.Itoa(leagueID)) {
+			return echo.NewHTTPError(http.StatusBadRequest, "This re-link isn't waiting on your league")
+		}
+
+		if err := decision(txDao, relink, strconv.Itoa(leagueID), record.Id); err != nil {
+			return err
+		}
+
+		settings, err := getLeagueSettings(txDao, leagueID)
+		if err != nil {
+			return fmt.Errorf("league settings not found: %w", err)
+		}
+		return recordAdminActivity(txDao, settings)
+	})
+
+	if err != nil {
+		log.Printf("Transaction failed: %v", err)
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	return LeagueRelinksGet(c)
+}
+
+// LeagueRelinkApprove signs a re-link off for the admin's league. Once every
+// league has approved it the re-link is applied.
+func LeagueRelinkApprove(c echo.Context) error {
+	return decideRelink(c, func(txDao *daos.Dao, relink *models.Record, leagueID string, actorID string) error {
+		pending := slices.DeleteFunc(relink.GetStringSlice("pendingLeagueIDs"), func(id string) bool {
+			return id == leagueID
+		})
+		relink.Set("pendingLeagueIDs", pending)
+		if err := txDao.SaveRecord(relink); err != nil {
+			return fmt.Errorf("save relink: %w", err)
+		}
+
+		err := lib.WriteAuditLog(txDao, actorID, "team_relink_approved", relink.GetString("userID"), map[string]any{
+			"relinkID": relink.Id,
+			"leagueID": leagueID,
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(pending) == 0 {
+			return applyTeamRelink(txDao, relink, actorID)
+		}
+		return nil
+	})
+}
+
+// LeagueRelinkReject turns a re-link down. One league saying no is enough.
+func LeagueRelinkReject(c echo.Context) error {
+	return decideRelink(c, func(txDao *daos.Dao, relink *models.Record, leagueID string, actorID string) error {
+		relink.Set("status", relinkStatusRejected)
+		if err := txDao.SaveRecord(relink); err != nil {
+			return fmt.Errorf("save relink: %w", err)
+		}
+
+		return lib.WriteAuditLog(txDao, actorID, "team_relink_rejected", relink.GetString("userID"), map[string]any{
+			"relinkID": relink.Id,
+			"leagueID": leagueID,
+		})
+	})
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/cmcd97/bytesize/app/views"
+	"github.com/cmcd97/bytesize/lib"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// leagueSeasonArchives returns every archived season of the viewer's default
+// league, newest first, along with the league's name.
+func leagueSeasonArchives(txDao *daos.Dao, record *models.Record) ([]types.SeasonArchive, string, error) {
+	defaultLeague, err := getDefaultLeague(txDao, record.Get("teamID"))
+	if err != nil {
+		return nil, "", fmt.Errorf("default league not found: %w", err)
+	}
+	leagueID := defaultLeague.GetInt("leagueID")
+
+	records, err := txDao.FindRecordsByFilter(
+		lib.SeasonArchivesCollection,
+		"leagueID = {:leagueID}",
+		"-seasonStartYear",
+		0,
+		0,
+		dbx.Params{"leagueID": leagueID},
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetch season archives: %w", err)
+	}
+
+	archives := make([]types.SeasonArchive, 0, len(records))
+	for _, archiveRecord := range records {
+		var archive types.SeasonArchive
+		if err := archiveRecord.UnmarshalJSONField("summary", &archive); err != nil {
+			return nil, "", fmt.Errorf("read the %s archive: %w", lib.SeasonName(archiveRecord.GetInt("seasonStartYear")), err)
+		}
+		archives = append(archives, archive)
+	}
+	return archives, leagueDisplayName(txDao, defaultLeague), nil
+}
+
+// SeasonArchiveGet shows how the viewer's league finished a past season, the
+// latest one unless another is picked.
+func SeasonArchiveGet(c echo.Context) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	selected := 0
+	if season := c.QueryParam("season"); season != "" {
+		year, err := strconv.Atoi(season)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "season must be the year it started")
+		}
+		selected = year
+	}
+
+	var page types.SeasonArchivePage
+	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		archives, leagueName, err := leagueSeasonArchives(txDao, record)
+		if err != nil {
+			return err
+		}
+		page.LeagueName = leagueName
+
+		if selected == 0 && len(archives) > 0 {
+			selected = archives[0].SeasonStartYear
+		}
+		for i, archive := range archives {
+			page.Seasons = append(page.Seasons, types.SeasonOption{
+				StartYear: archive.SeasonStartYear,
+				Name:      lib.SeasonName(archive.SeasonStartYear),
+				Selected:  archive.SeasonStartYear == selected,
+			})
+			if archive.SeasonStartYear == selected {
+				page.Archive = &archives[i]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Transaction failed: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	return lib.Render(c, http.StatusOK, views.SeasonArchive(page))
+}
+
+// HallOfFameGet adds up every archived season of the viewer's league, with
+// each season's champion and every manager's record across them.
+func HallOfFameGet(c echo.Context) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Authentication failed: record=%v, ok=%v", record, ok)
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authentication")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Database connection failed: pb=%v, ok=%v", pb, ok)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection unavailable")
+	}
+
+	var page types.HallOfFamePage
+	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		archives, leagueName, err := leagueSeasonArchives(txDao, record)
+		if err != nil {
+			return err
+		}
+		page.LeagueName = leagueName
+		page.Champions, page.Managers = hallOfFame(archives)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Transaction failed: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to process request: %v", err))
+	}
+
+	return lib.Render(c, http.StatusOK, views.HallOfFame(page))
+}
+
+// hallOfFame lists each season's champion, newest first, and every manager's
+// totals, most titles first. Managers are shown by the name they had in their
+// latest season.
+func hallOfFame(archives []types.SeasonArchive) ([]types.HallOfFameChampion, []types.HallOfFameRow) {
+	var champions []types.HallOfFameChampion
+	rows := make(map[string]*types.HallOfFameRow)
+	var order []string
+
+	for _, archive := range archives {
+		for _, standing := range archive.Standings {
+			if standing.Position == 1 {
+				champions = append(champions, types.HallOfFameChampion{
+					Season:      lib.SeasonName(archive.SeasonStartYear),
+					Name:        lib.ManagerName(standing.FirstName, standing.LastName),
+					TeamName:    standing.TeamName,
+					TotalPoints: standing.TotalPoints,
+				})
+			}
+
+			row, ok := rows[standing.UserID]
+			if !ok {
+				row = &types.HallOfFameRow{
+					Name:       lib.ManagerName(standing.FirstName, standing.LastName),
+					BestFinish: standing.Position,
+				}
+				rows[standing.UserID] = row
+				order = append(order, standing.UserID)
+			}
+			row.Seasons++
+			if standing.Position == 1 {
+				row.Titles++
+			}
+			row.BestFinish = min(row.BestFinish, standing.Position)
+			row.Cards += standing.Cards
+			row.SuspensionsServed += standing.SuspensionsServed
+			row.GameweekWins += standing.GameweekWins
+			row.ReversesLanded += standing.ReversesLanded
+		}
+	}
+
+	managers := make([]types.HallOfFameRow, 0, len(order))
+	for _, userID := range order {
+		managers = append(managers, *rows[userID])
+	}
+	sort.SliceStable(managers, func(i, j int) bool {
+		if managers[i].Titles != managers[j].Titles {
+			return managers[i].Titles > managers[j].Titles
+		}
+		if managers[i].BestFinish != managers[j].BestFinish {
+			return managers[i].BestFinish < managers[j].BestFinish
+		}
+		return managers[i].GameweekWins > managers[j].GameweekWins
+	})
+	return champions, managers
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/cmcd97/bytesize/app/views"
+	"github.com/cmcd97/bytesize/lib"
+	"github.com/cmcd97/bytesize/middleware"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// SessionsGet lists the signed in user's active sessions, most recently used first.
+func SessionsGet(c echo.Context) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Failed to get auth record for request: %v", c.Request().RequestURI)
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthorized")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Error: PocketBase instance is nil or type assertion failed")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection error")
+	}
+
+	currentSessionID, _ := c.Get(middleware.SessionContextKey).(string)
+
+	sessionRecords, err := pb.Dao().FindRecordsByFilter(
+		middleware.SessionsCollection,
+		"userID = {:userID} && revoked = false && expires > @now",
+		"-lastSeen",
+		0,
+		0,
+		dbx.Params{"userID": record.Id},
+	)
+	if err != nil {
+		log.Printf("Error finding sessions for user %s: %v", record.Id, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch sessions")
+	}
+
+	sessions := make([]types.Session, 0, len(sessionRecords))
+	for _, s := range sessionRecords {
+		sessions = append(sessions, types.Session{
+			ID:        s.Id,
+			Device:    lib.DescribeUserAgent(s.GetString("userAgent")),
+			IPAddress: s.GetString("ipAddress"),
+			LastSeen:  s.GetDateTime("lastSeen").Time(),
+			Created:   s.Created.Time(),
+			IsCurrent: s.Id == currentSessionID,
+		})
+	}
+
+	return lib.Render(c, http.StatusOK, views.Sessions(sessions))
+}
+
+// SessionRevoke signs out a single session. Signing out the current session
+// also clears the auth cookie and sends the user back to the login page.
+func SessionRevoke(c echo.Context) error {
+	sessionID := c.FormValue("sessionID")
+	if sessionID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "sessionID is required")
+	}
+
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Failed to get auth record for request: %v", c.Request().RequestURI)
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthorized")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Error: PocketBase instance is nil or type assertion failed")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection error")
+	}
+
+	if err := lib.RevokeSession(pb.Dao(), record.Id, sessionID); err != nil {
+		log.Printf("Error revoking session %s for user %s: %v", sessionID, record.Id, err)
+		return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("Failed to sign out session: %v", err))
+	}
+	log.Printf("Revoked session %s for user %s", sessionID, record.Id)
+
+	if currentSessionID, _ := c.Get(middleware.SessionContextKey).(string); currentSessionID == sessionID {
+		lib.ClearAuthCookie(c)
+		return lib.HtmxRedirect(c, "/auth/login")
+	}
+
+	return SessionsGet(c)
+}
+
+// SessionsRevokeAll signs the user out on every device, including this one.
+func SessionsRevokeAll(c echo.Context) error {
+	record, ok := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+	if !ok || record == nil {
+		log.Printf("Failed to get auth record for request: %v", c.Request().RequestURI)
+		return echo.NewHTTPError(http.StatusUnauthorized, "unauthorized")
+	}
+
+	pb, ok := c.Get("pb").(*pocketbase.PocketBase)
+	if !ok || pb == nil {
+		log.Printf("Error: PocketBase instance is nil or type assertion failed")
+		return echo.NewHTTPError(http.StatusInternalServerError, "Database connection error")
+	}
+
+	if err := lib.RevokeAllSessions(pb.Dao(), record); err != nil {
+		log.Printf("Error revoking all sessions for user %s: %v", record.Id, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to sign out everywhere")
+	}
+	log.Printf("Revoked all sessions for user %s", record.Id)
+
+	lib.ClearAuthCookie(c)
+	return lib.HtmxRedirect(c, "/auth/login")
+}
@@ -11,9 +11,8 @@ import (
 func InitAppRoutes(e *core.ServeEvent, pb *pocketbase.PocketBase) {
 	// Public API endpoints
 	apiGroup := e.Router.Group("/api")
-	apiGroup.GET("/run_etl", handlers.RunETL)
-	apiGroup.GET("/reset_reverse", handlers.ResetAllHasReverse)
-	appGroup := e.Router.Group("/app", middleware.LoadAuthContextFromCookie(pb), middleware.AuthGuard)
+	apiGroup.POST("/run_etl", handlers.RunETL)
+	appGroup := e.Router.Group("/app", middleware.LoadAuthContextFromCookie(pb), middleware.AuthGuard, middleware.CSRF)
 
 	appGroup.GET("", func(c echo.Context) error {
 		return c.Redirect(303, "/app/profile")
@@ -21,8 +20,10 @@ func InitAppRoutes(e *core.ServeEvent, pb *pocketbase.PocketBase) {
 	appGroup.GET("/profile", handlers.ProfileGet)
 	appGroup.GET("/fpl_team_id", handlers.FetchFplTeam)
 	appGroup.POST("/set_team_id", handlers.SetTeamID)
+	appGroup.GET("/backfill", handlers.BackfillStatus)
+	appGroup.POST("/backfill/retry", handlers.BackfillRetry)
 	appGroup.GET("/user_league_selection", handlers.UserLeaguesGet)
-	appGroup.GET("/set_default_league", handlers.SetDefaultLeague)
+	appGroup.POST("/set_default_league", handlers.SetDefaultLeague)
 	appGroup.GET("/check_default", handlers.CheckDefaultLeague)
 	appGroup.POST("/intialise_league", handlers.InitialiseLeague)
 	appGroup.GET("/check_for_league", handlers.CheckForLeague)
@@ -31,6 +32,9 @@ func InitAppRoutes(e *core.ServeEvent, pb *pocketbase.PocketBase) {
 	appGroup.GET("/gamweek_winner", handlers.GameweekWinnerGet)
 	appGroup.GET("/admin_verifications", handlers.AdminVerifications)
 	appGroup.GET("/user_cards", handlers.UserCardsGet)
+	appGroup.GET("/discipline", handlers.DisciplineGet)
+	appGroup.GET("/notifications", handlers.NotificationsGet)
+	appGroup.POST("/notifications/dismiss", handlers.NotificationDismiss)
 	appGroup.GET("/league_standings", handlers.LeagueStandingsGet)
 	appGroup.POST("/submit_preview", handlers.CardSubmitPreview)
 	appGroup.POST("/submit", handlers.SubmitCard)
@@ -43,6 +47,41 @@ func InitAppRoutes(e *core.ServeEvent, pb *pocketbase.PocketBase) {
 	appGroup.POST("/random_nominate_submit", handlers.RandomNominationPost)
 	appGroup.POST("/reverse_preview", handlers.CardReversePreview)
 	appGroup.POST("/reverse", handlers.ReverseCard)
+	appGroup.GET("/league/admins", handlers.LeagueAdminsGet)
+	appGroup.POST("/league/admins/promote", handlers.LeagueAdminPromote)
+	appGroup.POST("/league/admins/demote", handlers.LeagueAdminDemote)
+	appGroup.POST("/league/admins/transfer", handlers.LeagueOwnerTransfer)
+	appGroup.POST("/league/admins/nominate", handlers.LeagueAdminNominate)
+	appGroup.POST("/league/admins/sync", handlers.LeagueSyncPost)
+	appGroup.POST("/league/admins/vouch", handlers.LeagueOwnershipVouch)
+	appGroup.GET("/league/invites", handlers.LeagueInvitesGet)
+	appGroup.POST("/league/invites", handlers.LeagueInviteCreate)
+	appGroup.POST("/league/invites/revoke", handlers.LeagueInviteRevoke)
+	appGroup.GET("/league/relinks", handlers.LeagueRelinksGet)
+	appGroup.POST("/league/relinks/approve", handlers.LeagueRelinkApprove)
+	appGroup.POST("/league/relinks/reject", handlers.LeagueRelinkReject)
+	appGroup.GET("/team", handlers.TeamGet)
+	appGroup.POST("/team/relink", handlers.TeamRelinkRequest)
+	appGroup.POST("/team/relink/cancel", handlers.TeamRelinkCancel)
+	appGroup.GET("/league/settings", handlers.LeagueSettingsGet)
+	appGroup.POST("/league/settings", handlers.LeagueSettingsPost)
+	appGroup.POST("/league/season/confirm", handlers.LeagueSeasonConfirm)
+	appGroup.GET("/league/archive", handlers.SeasonArchiveGet)
+	appGroup.GET("/league/hall_of_fame", handlers.HallOfFameGet)
+	appGroup.GET("/league/export", handlers.LeagueExportGet)
+	appGroup.GET("/manager/:userID", handlers.ManagerHistoryGet)
+	appGroup.GET("/live", handlers.LiveGet)
+	appGroup.GET("/league/recap", handlers.RecapGet)
+	appGroup.GET("/league/recap.md", handlers.RecapMarkdownGet)
+	appGroup.GET("/league/recap.png", handlers.RecapImageGet)
+	appGroup.GET("/league/fines", handlers.FineLedgerGet)
+	appGroup.POST("/league/fines", handlers.FineEntryPost)
+	appGroup.POST("/league/fines/settle", handlers.FineSettlementPost)
+	appGroup.GET("/league/fines/report", handlers.FineReportGet)
+	appGroup.GET("/sessions", handlers.SessionsGet)
+	appGroup.POST("/sessions/revoke", handlers.SessionRevoke)
+	appGroup.POST("/sessions/revoke_all", handlers.SessionsRevokeAll)
+	e.Router.GET("/invite/:code", handlers.InviteLanding, middleware.LoadAuthContextFromCookie(pb), middleware.CSRF)
 	e.Router.GET("/", func(c echo.Context) error {
 		return c.Redirect(303, "/app/profile")
 	})
@@ -22,6 +22,14 @@ type FPLUserLeague struct {
 	IsActive        bool
 }
 
+type OnboardingDraft struct {
+	TeamID    int
+	FirstName string
+	LastName  string
+	TeamName  string
+	Leagues   []FPLUserLeague
+}
+
 type ClassicLeague struct {
 	LeagueID   int    `json:"id"`
 	Name       string `json:"name"`
@@ -83,12 +91,20 @@ func (df DatabasePlayer) ToAPIPlayers() Player {
 
 // FPLResponse represents the full API response
 type FPLResponse struct {
-	Events   []Event  `json:"events"`
-	Elements []Player `json:"elements"`
+	Events   []Event   `json:"events"`
+	Elements []Player  `json:"elements"`
+	Teams    []FPLTeam `json:"teams"`
+}
+
+type FPLTeam struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	ShortName string `json:"short_name"`
 }
 
 type FixtureStats struct {
 	Gameweek  int            `json:"event"`
+	Started   bool           `json:"started"`
 	Finished  bool           `json:"finished"`
 	FixtureID int            `json:"id"`
 	Stats     []StatCategory `json:"stats"` // Fix: directly use []StatCategory
@@ -100,6 +116,7 @@ type DatabaseFixtureStats struct {
 	Gameweek   int    `db:"gameweek"`
 	PlayerID   int    `db:"playerID"`
 	EventType  string `db:"eventType"`
+	Side       string `db:"side"`
 	EventValue int    `db:"eventValue"`
 }
 
@@ -135,6 +152,7 @@ func (f *FixtureStats) UnmarshalJSON(data []byte) error {
 	// Create a temporary struct to hold the raw data
 	type TempFixture struct {
 		Gameweek  int            `json:"event"`
+		Started   bool           `json:"started"`
 		Finished  bool           `json:"finished"`
 		FixtureID int            `json:"id"`
 		Stats     []StatCategory `json:"stats"`
@@ -147,6 +165,7 @@ func (f *FixtureStats) UnmarshalJSON(data []byte) error {
 
 	// Copy the basic fields
 	f.Gameweek = temp.Gameweek
+	f.Started = temp.Started
 	f.Finished = temp.Finished
 	f.FixtureID = temp.FixtureID
 
@@ -206,6 +225,7 @@ type GameweekHistory struct {
 	GameweekHistrory GameweekResults     `json:"entry_history"`
 	ActiveChip       string              `json:"active_chip"`
 	Players          []GameweekSelection `json:"picks"`
+	AutomaticSubs    []AutomaticSub      `json:"automatic_subs"`
 }
 
 type GameweekResults struct {
@@ -217,8 +237,17 @@ type GameweekResults struct {
 }
 
 type GameweekSelection struct {
-	PlayerID int `json:"element"`
-	Position int `json:"position"`
+	PlayerID      int  `json:"element"`
+	Position      int  `json:"position"`
+	Multiplier    int  `json:"multiplier"`
+	IsCaptain     bool `json:"is_captain"`
+	IsViceCaptain bool `json:"is_vice_captain"`
+}
+
+// AutomaticSub is a bench player FPL brought on for a starter who didn't play
+type AutomaticSub struct {
+	ElementIn  int `json:"element_in"`
+	ElementOut int `json:"element_out"`
 }
 
 type CardHistory struct {
@@ -251,6 +280,27 @@ type FixtureUpdateStatus struct {
 	Leagues string          `json:"leagues"`
 }
 
+// FPLLive is the event/{id}/live/ response: what each player has done so far
+// in a gameweek, fixture by fixture
+type FPLLive struct {
+	Elements []FPLLiveElement `json:"elements"`
+}
+
+type FPLLiveElement struct {
+	PlayerID int              `json:"id"`
+	Explain  []FPLLiveFixture `json:"explain"`
+}
+
+type FPLLiveFixture struct {
+	FixtureID int           `json:"fixture"`
+	Stats     []FPLLiveStat `json:"stats"`
+}
+
+type FPLLiveStat struct {
+	Identifier StatIdentifier `json:"identifier"`
+	Value      int            `json:"value"`
+}
+
 type DatabaseUsers struct {
 	UserID string `db:"id"`
 	TeamID int    `db:"teamID"`
@@ -280,6 +330,11 @@ type DatabaseResults struct {
 	Pos13       int    `db:"pos_13"`
 	Pos14       int    `db:"pos_14"`
 	Pos15       int    `db:"pos_15"`
+	Captain     int    `db:"captain"`
+	ViceCaptain int    `db:"viceCaptain"`
+	// JSON encoded, so results can still be compared with ==
+	Multipliers   string `db:"multipliers"`
+	AutomaticSubs string `db:"automaticSubs"`
 }
 
 type DatabaseCard struct {
@@ -293,6 +348,10 @@ type DatabaseCard struct {
 	Type            string `db:"type"`
 	LeagueID        int    `db:"leagueID"`
 	CardHash        string `db:"cardHash"`
+	EventHash       string `db:"eventHash"`
+	PlayerID        int    `db:"playerID"`
+	FixtureID       int    `db:"fixtureID"`
+	Voided          bool   `db:"voided"`
 }
 
 type TableCard struct {
@@ -307,6 +366,7 @@ type TableCard struct {
 	LeagueID        int    `db:"leagueID"`
 	CardHash        string `db:"cardHash"`
 	UserHasReverse  bool   `db:"userHasReverse"`
+	ActiveChip      string `db:"activeChip"`
 }
 
 type DatabaseLeague struct {
@@ -315,6 +375,8 @@ type DatabaseLeague struct {
 }
 
 type DatabaseEvent struct {
+	EventHash  string `db:"eventHash"`
+	FixtureID  int    `db:"fixtureID"`
 	Gameweek   int    `db:"gameweek"`
 	PlayerID   int    `db:"playerID"`
 	EventType  string `db:"eventType"`
@@ -354,6 +416,8 @@ type LeagueStandingRow struct {
 	TotalPoints    int    `db:"totalPoints"`
 	CardCount      int    `db:"cardCount"`
 	IsSuspended    bool   `db:"isSuspended"`
+	ActiveChip     string `db:"activeChip"`
+	UserID         string `db:"userID"`
 }
 
 type CardApprovals struct {
@@ -368,6 +432,9 @@ type CardApprovals struct {
 	LeagueID        int    `db:"leagueID"`
 	CardHash        string `db:"cardHash"`
 	Person          string `db:"person"`
+	PlayerID        int    `db:"playerID"`
+	FixtureID       int    `db:"fixtureID"`
+	Description     string
 }
 
 type LeagueMembers struct {
@@ -399,3 +466,590 @@ type StatusEvent struct {
 	Finished    bool `json:"finished"`
 	DataChecked bool `json:"data_checked"`
 }
+
+type Session struct {
+	ID        string
+	Device    string
+	IPAddress string
+	LastSeen  time.Time
+	Created   time.Time
+	IsCurrent bool
+}
+
+type Notification struct {
+	ID      string
+	Kind    string
+	Message string
+	Created time.Time
+}
+
+type LeagueAdminMember struct {
+	UserID   string `db:"userID"`
+	UserName string `db:"userName"`
+	IsOwner  bool
+	IsAdmin  bool
+	Votes    int
+}
+
+type LeagueSyncMember struct {
+	EntryID    int
+	EntryName  string
+	PlayerName string
+	// the username a league admin vouched for linking the entry, if any
+	VouchedFor string
+}
+
+type LeagueAdminPage struct {
+	LeagueID          int
+	LeagueName        string
+	Members           []LeagueAdminMember
+	Unclaimed         []LeagueSyncMember
+	Left              []LeagueSyncMember
+	LastSynced        time.Time
+	ViewerID          string
+	ViewerIsAdmin     bool
+	ViewerIsOwner     bool
+	AdminsInactive    bool
+	InactiveGameweeks int
+	ViewerVote        string
+}
+
+type LeagueSettings struct {
+	DisplayName               string
+	StartGameweek             int
+	RandomNominationEnabled   bool
+	RandomNominationCount     int
+	FineDescription           string
+	CaptainCardsDoubled       bool
+	TripleCaptainCardsTripled bool
+	BenchBoostCardsAll        bool
+	FinesEnabled              bool
+	FineCurrency              string
+	// FineAmounts is the fine for each card type, in the currency's minor units
+	FineAmounts    map[string]int
+	FineEscalation int
+	Version        int
+}
+
+type LeagueSettingsChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+type LeagueSettingsVersion struct {
+	Version   int
+	Gameweek  int
+	ChangedBy string
+	Changes   []LeagueSettingsChange
+	Created   time.Time
+}
+
+type LeagueSettingsPage struct {
+	LeagueID      int
+	Settings      LeagueSettings
+	History       []LeagueSettingsVersion
+	ViewerIsAdmin bool
+	// Set after a season rollover until an admin confirms the league is
+	// carrying on
+	SeasonConfirmationPending bool
+	SeasonName                string
+}
+
+type FPLLeagueStandingsResponse struct {
+	League    FPLLeagueInfo    `json:"league"`
+	Standings FPLStandingsPage `json:"standings"`
+}
+
+type FPLLeagueInfo struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Created string `json:"created"`
+}
+
+type FPLStandingsPage struct {
+	HasNext bool               `json:"has_next"`
+	Page    int                `json:"page"`
+	Results []FPLStandingEntry `json:"results"`
+}
+
+type FPLStandingEntry struct {
+	Entry      int    `json:"entry"`
+	EntryName  string `json:"entry_name"`
+	PlayerName string `json:"player_name"`
+	Rank       int    `json:"rank"`
+	Total      int    `json:"total"`
+}
+
+type LeagueInvite struct {
+	ID        string
+	Link      string
+	EntryID   int
+	EntryName string
+	Expires   time.Time
+	MaxUses   int
+	Uses      int
+}
+
+type LeagueInvitesPage struct {
+	Invites   []LeagueInvite
+	Unclaimed []LeagueSyncMember
+}
+
+type PendingInvite struct {
+	LeagueName string
+	EntryID    int
+}
+
+type TeamRelink struct {
+	ID             string
+	UserName       string
+	OldTeamID      int
+	NewTeamID      int
+	NewTeamName    string
+	Status         string
+	PendingLeagues int
+	Created        time.Time
+}
+
+type OwnershipChallenge struct {
+	TeamID   int
+	TeamName string
+	Code     string
+}
+
+type TeamPage struct {
+	TeamID    int
+	TeamName  string
+	Pending   *TeamRelink
+	History   []TeamRelink
+	Challenge *OwnershipChallenge
+}
+
+type FPLEntryHistory struct {
+	Current []struct {
+		Event int `json:"event"`
+	} `json:"current"`
+}
+
+type BackfillJob struct {
+	Status          string
+	Total           int
+	Completed       int
+	FailedGameweeks []int
+}
+
+// SeasonArchive is how a league's season finished, snapshotted at rollover
+type SeasonArchive struct {
+	SeasonStartYear int                 `json:"seasonStartYear"`
+	LeagueID        int                 `json:"leagueID"`
+	LeagueName      string              `json:"leagueName"`
+	LastGameweek    int                 `json:"lastGameweek"`
+	Standings       []ArchivedStanding  `json:"standings"`
+	CardsByType     []ArchivedCardCount `json:"cardsByType"`
+	Awards          []ArchivedAward     `json:"awards"`
+}
+
+type ArchivedStanding struct {
+	Position            int    `db:"position" json:"position"`
+	UserID              string `db:"userID" json:"userID"`
+	FirstName           string `db:"firstName" json:"firstName"`
+	LastName            string `db:"lastName" json:"lastName"`
+	TeamName            string `db:"teamName" json:"teamName"`
+	TotalPoints         int    `db:"totalPoints" json:"totalPoints"`
+	Cards               int    `json:"cards"`
+	SuspensionsServed   int    `json:"suspensionsServed"`
+	GameweekWins        int    `json:"gameweekWins"`
+	NominationsReceived int    `json:"nominationsReceived"`
+	ReversesLanded      int    `json:"reversesLanded"`
+}
+
+type ArchivedCardCount struct {
+	Type  string `json:"type"`
+	Count int    `json:"count"`
+}
+
+// ArchivedAward goes to every manager tied on the highest count
+type ArchivedAward struct {
+	Title  string   `json:"title"`
+	Names  []string `json:"names"`
+	Count  int      `json:"count"`
+	Suffix string   `json:"suffix"`
+}
+
+type SeasonArchivePage struct {
+	LeagueName string
+	Seasons    []SeasonOption
+	Archive    *SeasonArchive
+}
+
+type SeasonOption struct {
+	StartYear int
+	Name      string
+	Selected  bool
+}
+
+// HallOfFameRow is a manager's record across every archived season of a league
+type HallOfFameRow struct {
+	Name              string
+	Seasons           int
+	Titles            int
+	BestFinish        int
+	Cards             int
+	SuspensionsServed int
+	GameweekWins      int
+	ReversesLanded    int
+}
+
+type HallOfFameChampion struct {
+	Season      string
+	Name        string
+	TeamName    string
+	TotalPoints int
+}
+
+type HallOfFamePage struct {
+	LeagueName string
+	Champions  []HallOfFameChampion
+	Managers   []HallOfFameRow
+}
+
+type ManagerHistoryPage struct {
+	LeagueName          string
+	Name                string
+	TeamName            string
+	Gameweeks           []ManagerGameweek
+	Chart               LineChart
+	CardsReceived       int
+	NominationsGiven    int
+	NominationsReceived int
+	ReversesLanded      int
+}
+
+// ManagerGameweek is one gameweek of a manager's history. AdjustedPoints are
+// after hits and any suspension, TotalPoints are counted from the league's
+// start gameweek.
+type ManagerGameweek struct {
+	Gameweek          int
+	RawPoints         int
+	HitCost           int
+	AdjustedPoints    int
+	TotalPoints       int
+	ActiveChip        string
+	ServingSuspension bool
+	SuspendedNext     bool
+	Cards             []ManagerCard
+	NominationsGiven  []string
+	ReversesLanded    []string
+}
+
+type ManagerCard struct {
+	Type       string
+	Cause      string
+	Voided     bool
+	VoidReason string
+}
+
+// LineChart is a line chart laid out for an inline SVG
+type LineChart struct {
+	Width     int
+	Height    int
+	PlotLeft  float64
+	PlotRight float64
+	Line      string
+	Dots      []ChartDot
+	Gridlines []ChartGridline
+	XLabels   []ChartLabel
+}
+
+type ChartDot struct {
+	X     float64
+	Y     float64
+	Title string
+}
+
+type ChartGridline struct {
+	Y     float64
+	Label string
+}
+
+type ChartLabel struct {
+	X     float64
+	Label string
+}
+
+// DisciplinaryStatus is how close a manager is to a suspension.
+// SuspensionGameweek is the gameweek they'll sit out, or 0 if their open
+// cards don't reach the threshold. FinesToClear is how many of the open
+// cards need a fine approved to stay under it.
+type DisciplinaryStatus struct {
+	Threshold          int
+	OpenCards          []DisciplinaryCard
+	SuspensionGameweek int
+	CardsToSuspension  int
+	FinesToClear       int
+	Forecast           CardForecast
+}
+
+// DisciplinaryCard is one open card as it counts towards a suspension. Cards
+// of the same type in the same gameweek count once, across every league.
+type DisciplinaryCard struct {
+	Gameweek         int
+	Type             string
+	Causes           []string
+	AwaitingApproval bool
+}
+
+// CardForecast is the cards a manager's current XI would earn from the
+// fixtures of a gameweek that haven't been turned into cards yet.
+// SuspensionGameweek is the gameweek they'd sit out if they were issued.
+type CardForecast struct {
+	Gameweek           int
+	Issued             bool
+	Unavailable        bool
+	Cards              []ForecastCard
+	SuspensionGameweek int
+}
+
+type ForecastCard struct {
+	Type     string
+	Cause    string
+	Finished bool
+}
+
+// LivePage is the cards a league's managers are picking up in the gameweek
+// being played. Provisional cards come from the live match stats and are
+// replaced by the real ones once the gameweek is finalised.
+type LivePage struct {
+	LeagueName string
+	Gameweek   int
+	Managers   []LiveManager
+}
+
+type LiveManager struct {
+	UserID    string
+	Name      string
+	Confirmed bool
+	Cards     []LiveCard
+}
+
+type LiveCard struct {
+	Type            string
+	Cause           string
+	Provisional     bool
+	FixtureFinished bool
+}
+
+// GameweekRecap is how a gameweek went in a league, stored as the summary of
+// a gameweek_recaps row. Nominations and reverses are the ones handed out
+// after the previous gameweek, which is when the winner gets to nominate.
+type GameweekRecap struct {
+	LeagueID            int               `json:"leagueID"`
+	LeagueName          string            `json:"leagueName"`
+	Gameweek            int               `json:"gameweek"`
+	Winner              RecapScore        `json:"winner"`
+	RunnerUp            RecapScore        `json:"runnerUp"`
+	LowestScorer        RecapScore        `json:"lowestScorer"`
+	Cards               []RecapCard       `json:"cards"`
+	SuspensionsStarting []string          `json:"suspensionsStarting"`
+	SuspensionsEnding   []string          `json:"suspensionsEnding"`
+	Nominations         []RecapNomination `json:"nominations"`
+	Movers              []RecapMover      `json:"movers"`
+}
+
+type RecapScore struct {
+	Name     string `json:"name"`
+	TeamName string `json:"teamName"`
+	Points   int    `json:"points"`
+}
+
+type RecapCard struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Cause string `json:"cause"`
+}
+
+// RecapNomination is a nomination From gave To, or a card From reversed back
+// onto To
+type RecapNomination struct {
+	Type string `json:"type"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// RecapMover is a manager whose place in the standings changed, by Change
+// places, up when it's positive
+type RecapMover struct {
+	Name     string `json:"name"`
+	Position int    `json:"position"`
+	Change   int    `json:"change"`
+}
+
+type GameweekRecapPage struct {
+	Recap     GameweekRecap
+	Gameweeks []int
+	Markdown  string
+}
+
+// League exports. An export is a list of one of the Export* rows below, as
+// CSV with a header row or as a JSON array of objects. The json tags are the
+// column names in both formats, and exports are read by admins' spreadsheets:
+// add columns at the end, never rename or reorder them. Manager names are as
+// they are now, not when the row was made.
+
+// ExportCard is a card, in the "cards" export. Columns:
+//
+//	gameweek, leagueID, cardHash, userID, teamID, manager, type, status,
+//	nominatorUserID, nominator, created
+//
+// status is one of open, awaiting_approval (fine submitted), fine_approved,
+// cleared (served a suspension, or its fine was approved before approvals
+// were recorded) or voided. nominatorUserID and nominator are empty for cards
+// earned from match stats.
+type ExportCard struct {
+	Gameweek        int    `db:"gameweek" json:"gameweek"`
+	LeagueID        int    `db:"leagueID" json:"leagueID"`
+	CardHash        string `db:"cardHash" json:"cardHash"`
+	UserID          string `db:"userID" json:"userID"`
+	TeamID          int    `db:"teamID" json:"teamID"`
+	Manager         string `db:"manager" json:"manager"`
+	Type            string `db:"type" json:"type"`
+	Status          string `db:"status" json:"status"`
+	NominatorUserID string `db:"nominatorUserID" json:"nominatorUserID"`
+	Nominator       string `db:"nominator" json:"nominator"`
+	Created         string `db:"created" json:"created"`
+}
+
+// ExportStanding is a manager's row of the standings after a gameweek, in the
+// "standings" export. Columns:
+//
+//	gameweek, userID, teamID, manager, position, leaguePoints, points,
+//	totalPoints, isSuspendedNext
+//
+// points is the gameweek's score before hits, or 0 if the manager was
+// suspended for it. totalPoints is the season total so far after hits, with
+// hits*4 taken off every gameweek, and leaguePoints is the part of it scored
+// since the league's start gameweek, which position ranks by. Both can differ
+// from the totals FPL shows.
+type ExportStanding struct {
+	Gameweek        int    `db:"gameweek" json:"gameweek"`
+	UserID          string `db:"userID" json:"userID"`
+	TeamID          int    `db:"teamID" json:"teamID"`
+	Manager         string `db:"manager" json:"manager"`
+	Position        int    `db:"position" json:"position"`
+	LeaguePoints    int    `db:"leaguePoints" json:"leaguePoints"`
+	Points          int    `db:"points" json:"points"`
+	TotalPoints     int    `db:"totalPoints" json:"totalPoints"`
+	IsSuspendedNext bool   `db:"isSuspendedNext" json:"isSuspendedNext"`
+}
+
+// ExportFine is a fine submitted to clear a card, in the "fines" export.
+// Columns:
+//
+//	gameweek, cardHash, userID, manager, type, status, approvedByUserID,
+//	approvedBy, approvedAt
+//
+// status is awaiting_approval or approved, and gameweek is the card's.
+type ExportFine struct {
+	Gameweek         int    `db:"gameweek" json:"gameweek"`
+	CardHash         string `db:"cardHash" json:"cardHash"`
+	UserID           string `db:"userID" json:"userID"`
+	Manager          string `db:"manager" json:"manager"`
+	Type             string `db:"type" json:"type"`
+	Status           string `db:"status" json:"status"`
+	ApprovedByUserID string `db:"approvedByUserID" json:"approvedByUserID"`
+	ApprovedBy       string `db:"approvedBy" json:"approvedBy"`
+	ApprovedAt       string `db:"approvedAt" json:"approvedAt"`
+}
+
+// ExportNomination is a nomination or reverse, in the "nominations" export.
+// Columns:
+//
+//	gameweek, type, cardHash, fromUserID, from, toUserID, to, voided, created
+//
+// type is nomination or reverse. from handed the card to to, so for a reverse
+// from is the manager who reversed it.
+type ExportNomination struct {
+	Gameweek   int    `db:"gameweek" json:"gameweek"`
+	Type       string `db:"type" json:"type"`
+	CardHash   string `db:"cardHash" json:"cardHash"`
+	FromUserID string `db:"fromUserID" json:"fromUserID"`
+	From       string `db:"from" json:"from"`
+	ToUserID   string `db:"toUserID" json:"toUserID"`
+	To         string `db:"to" json:"to"`
+	Voided     bool   `db:"voided" json:"voided"`
+	Created    string `db:"created" json:"created"`
+}
+
+// FineBalance is what a member has been charged, paid and had waived in a
+// season, in the league currency's minor units. Owed is what's left to pay,
+// and negative when they've paid more than they were charged.
+type FineBalance struct {
+	UserID  string
+	Name    string
+	Cards   int
+	Charged int
+	Paid    int
+	Waived  int
+	Owed    int
+}
+
+// FineLedgerEntry is one line of a league's ledger: a charge for a card, or a
+// payment or waiver an admin recorded. Cancelled charges are for cards that
+// were voided or reversed onto someone else.
+type FineLedgerEntry struct {
+	Kind        string
+	Name        string
+	Description string
+	Amount      int
+	Gameweek    int
+	Cancelled   bool
+	Settled     bool
+	Created     time.Time
+}
+
+// FineSettlement is a pot of payments an admin marked as paid out
+type FineSettlement struct {
+	Amount    int
+	Currency  string
+	Payments  int
+	Note      string
+	SettledBy string
+	Created   time.Time
+}
+
+// FineTypeTotal is what a season's cards of one type were charged
+type FineTypeTotal struct {
+	Type    string
+	Cards   int
+	Charged int
+}
+
+// FineLedgerPage is a league's ledger for the current season
+type FineLedgerPage struct {
+	LeagueName    string
+	SeasonName    string
+	Currency      string
+	Enabled       bool
+	ViewerIsAdmin bool
+	Balances      []FineBalance
+	Totals        FineBalance
+	// Pot is the payments not yet paid out in a settlement
+	Pot         int
+	Entries     []FineLedgerEntry
+	Settlements []FineSettlement
+}
+
+// FineReportPage totals a season of a league's fines
+type FineReportPage struct {
+	LeagueName  string
+	SeasonName  string
+	Currency    string
+	Seasons     []SeasonOption
+	Balances    []FineBalance
+	Totals      FineBalance
+	ByType      []FineTypeTotal
+	Settled     int
+	Unsettled   int
+	Settlements []FineSettlement
+}
@@ -1,7 +1,7 @@
 package auth
 
 import (
-	"net/http"
+	"log"
 
 	"github.com/a-h/templ"
 	"github.com/cmcd97/bytesize/lib"
@@ -11,6 +11,7 @@ import (
 	"github.com/labstack/echo/v5"
 	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/models"
 )
 
 type LoginFormValue struct {
@@ -64,14 +65,15 @@ func RegisterLoginRoutes(e *core.ServeEvent, group echo.Group) {
 	})
 
 	group.POST("/logout", func(c echo.Context) error {
-		c.SetCookie(&http.Cookie{
-			Name:     middleware.AuthCookieName,
-			Value:    "",
-			Path:     "/",
-			Secure:   true,
-			HttpOnly: true,
-			MaxAge:   -1,
-		})
+		record, _ := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+		sessionID, _ := c.Get(middleware.SessionContextKey).(string)
+		if record != nil && sessionID != "" {
+			if err := lib.RevokeSession(e.App.Dao(), record.Id, sessionID); err != nil {
+				log.Printf("Failed to revoke session %s: %v", sessionID, err)
+			}
+		}
+
+		lib.ClearAuthCookie(c)
 
 		return lib.HtmxRedirect(c, "/auth/login")
 	})
@@ -0,0 +1,32 @@
+package lib
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+const AuditLogsCollection = "audit_logs"
+
+// WriteAuditLog records a change made to a user's account or to data the ETL
+// derived for them. details is stored as JSON so each action can carry
+// whatever it needs to explain itself later. Changes the ETL makes on its own
+// have no actor.
+func WriteAuditLog(txDao *daos.Dao, actorUserID string, action string, subjectUserID string, details any) error {
+	collection, err := txDao.FindCollectionByNameOrId(AuditLogsCollection)
+	if err != nil {
+		return fmt.Errorf("find collection: %w", err)
+	}
+
+	entry := models.NewRecord(collection)
+	entry.Set("actorUserID", actorUserID)
+	entry.Set("action", action)
+	entry.Set("subjectUserID", subjectUserID)
+	entry.Set("details", details)
+
+	if err := txDao.SaveRecord(entry); err != nil {
+		return fmt.Errorf("save audit log: %w", err)
+	}
+	return nil
+}
@@ -2,14 +2,18 @@ package lib
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/cmcd97/bytesize/middleware"
 	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
 	"github.com/pocketbase/pocketbase/models"
 	"github.com/pocketbase/pocketbase/tokens"
+	"github.com/pocketbase/pocketbase/tools/types"
 )
 
 type Users struct {
@@ -63,12 +67,10 @@ func Register(e *core.ServeEvent, c echo.Context, username string, password stri
 func setAuthToken(app core.App, c echo.Context, user *models.Record, stayLoggedIn string) error {
 	s, tokenErr := tokens.NewRecordAuthToken(app, user)
 	if tokenErr != nil {
-		fmt.Println("Error generating auth token:", tokenErr)
+		log.Printf("Error generating auth token for %s: %v", user.Id, tokenErr)
 		return fmt.Errorf("Login failed")
 	}
 
-	fmt.Printf("Setting auth token - stayLoggedIn: '%s'\n", stayLoggedIn)
-
 	// Default to session cookie
 	maxAge := 0                               // Changed from -1 to 0 for session cookie
 	expires := time.Now().Add(24 * time.Hour) // Set default expiry
@@ -78,7 +80,10 @@ func setAuthToken(app core.App, c echo.Context, user *models.Record, stayLoggedI
 		expires = time.Now().Add(time.Hour * 24 * 90)
 	}
 
-	fmt.Printf("Cookie settings - MaxAge: %d, Expires: %v\n", maxAge, expires)
+	if err := createSession(app, c, user, s, expires); err != nil {
+		log.Printf("Error creating session for %s: %v", user.Id, err)
+		return fmt.Errorf("Login failed")
+	}
 
 	c.SetCookie(&http.Cookie{
 		Name:     middleware.AuthCookieName,
@@ -92,3 +97,82 @@ func setAuthToken(app core.App, c echo.Context, user *models.Record, stayLoggedI
 
 	return nil
 }
+
+func createSession(app core.App, c echo.Context, user *models.Record, token string, expires time.Time) error {
+	collection, err := app.Dao().FindCollectionByNameOrId(middleware.SessionsCollection)
+	if err != nil {
+		return err
+	}
+
+	expiresAt, err := types.ParseDateTime(expires)
+	if err != nil {
+		return err
+	}
+
+	session := models.NewRecord(collection)
+	session.Set("userID", user.Id)
+	session.Set("tokenID", middleware.SessionTokenID(token))
+	session.Set("userAgent", c.Request().UserAgent())
+	session.Set("ipAddress", c.RealIP())
+	session.Set("lastSeen", types.NowDateTime())
+	session.Set("expires", expiresAt)
+	session.Set("revoked", false)
+
+	return app.Dao().SaveRecord(session)
+}
+
+// RevokeSession signs out a single session belonging to userID.
+func RevokeSession(dao *daos.Dao, userID string, sessionID string) error {
+	session, err := dao.FindFirstRecordByFilter(
+		middleware.SessionsCollection,
+		"id = {:id} && userID = {:userID}",
+		dbx.Params{"id": sessionID, "userID": userID},
+	)
+	if err != nil {
+		return fmt.Errorf("session not found")
+	}
+
+	session.Set("revoked", true)
+	return dao.SaveRecord(session)
+}
+
+// RevokeAllSessions signs out every session of the user and rotates their
+// token key so any auth token issued before now stops validating.
+func RevokeAllSessions(dao *daos.Dao, user *models.Record) error {
+	return dao.RunInTransaction(func(txDao *daos.Dao) error {
+		sessions, err := txDao.FindRecordsByFilter(
+			middleware.SessionsCollection,
+			"userID = {:userID} && revoked = false",
+			"",
+			0,
+			0,
+			dbx.Params{"userID": user.Id},
+		)
+		if err != nil {
+			return fmt.Errorf("find sessions: %w", err)
+		}
+
+		for _, session := range sessions {
+			session.Set("revoked", true)
+			if err := txDao.SaveRecord(session); err != nil {
+				return fmt.Errorf("revoke session %s: %w", session.Id, err)
+			}
+		}
+
+		if err := user.RefreshTokenKey(); err != nil {
+			return fmt.Errorf("refresh token key: %w", err)
+		}
+		return txDao.SaveRecord(user)
+	})
+}
+
+func ClearAuthCookie(c echo.Context) {
+	c.SetCookie(&http.Cookie{
+		Name:     middleware.AuthCookieName,
+		Value:    "",
+		Path:     "/",
+		Secure:   true,
+		HttpOnly: true,
+		MaxAge:   -1,
+	})
+}
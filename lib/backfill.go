@@ -0,0 +1,250 @@
+package lib
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+const (
+	BackfillJobsCollection = "backfill_jobs"
+	BackfillRunning        = "running"
+	BackfillDone           = "done"
+	BackfillFailed         = "failed"
+
+	backfillWorkerCount     = 4
+	backfillRequestInterval = 100 * time.Millisecond
+	backfillTimeout         = 5 * time.Minute
+)
+
+type backfillResult struct {
+	gameweek int
+	history  types.GameweekHistory
+	err      error
+}
+
+// StartBackfill imports teamID's gameweek history for userID in the
+// background and returns the job record tracking it. With no gameweeks given
+// it asks FPL which ones the team has played. A user only ever has one
+// running job; asking again returns it.
+func StartBackfill(pb *pocketbase.PocketBase, userID string, teamID int, gameweeks []int) (*models.Record, error) {
+	running, err := pb.Dao().FindFirstRecordByFilter(
+		BackfillJobsCollection,
+		"userID = {:userID} && status = {:status}",
+		dbx.Params{"userID": userID, "status": BackfillRunning},
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("error finding running backfill: %w", err)
+	}
+	if running != nil {
+		if !BackfillStale(running) {
+			return running, nil
+		}
+		running.Set("status", BackfillFailed)
+		if err := pb.Dao().SaveRecord(running); err != nil {
+			return nil, fmt.Errorf("error saving stale backfill: %w", err)
+		}
+	}
+
+	collection, err := pb.Dao().FindCollectionByNameOrId(BackfillJobsCollection)
+	if err != nil {
+		return nil, fmt.Errorf("error finding collection: %w", err)
+	}
+
+	job := models.NewRecord(collection)
+	job.Set("userID", userID)
+	job.Set("teamID", teamID)
+	job.Set("status", BackfillRunning)
+	job.Set("total", len(gameweeks))
+	job.Set("completed", 0)
+	job.Set("failedGameweeks", []int{})
+	if err := pb.Dao().SaveRecord(job); err != nil {
+		return nil, fmt.Errorf("error saving backfill job: %w", err)
+	}
+
+	go runBackfill(pb, job.CleanCopy(), gameweeks)
+
+	return job, nil
+}
+
+// BackfillStale reports whether a job still marked as running has outlived
+// its timeout, which happens if the server restarted part way through.
+func BackfillStale(job *models.Record) bool {
+	return job.GetString("status") == BackfillRunning &&
+		time.Since(job.Created.Time()) > backfillTimeout+time.Minute
+}
+
+func runBackfill(pb *pocketbase.PocketBase, job *models.Record, gameweeks []int) {
+	ctx, cancel := context.WithTimeout(context.Background(), backfillTimeout)
+	defer cancel()
+
+	userID := job.GetString("userID")
+	teamID := job.GetInt("teamID")
+	log.Printf("[Backfill] Starting backfill for team %d", teamID)
+
+	if len(gameweeks) == 0 {
+		played, err := fetchPlayedGameweeks(ctx, teamID)
+		if err != nil {
+			log.Printf("[Backfill] Error fetching history for team %d: %v", teamID, err)
+			job.Set("status", BackfillFailed)
+			if err := pb.Dao().SaveRecord(job); err != nil {
+				log.Printf("[Backfill] Error saving backfill job: %v", err)
+			}
+			return
+		}
+		gameweeks = played
+		job.Set("total", len(gameweeks))
+		if err := pb.Dao().SaveRecord(job); err != nil {
+			log.Printf("[Backfill] Error saving backfill job: %v", err)
+		}
+	}
+
+	// Workers share one ticker so the whole job stays under the rate limit
+	limiter := time.NewTicker(backfillRequestInterval)
+	defer limiter.Stop()
+
+	jobs := make(chan int, len(gameweeks))
+	results := make(chan backfillResult, len(gameweeks))
+
+	var wg sync.WaitGroup
+	for w := 0; w < backfillWorkerCount; w++ {
+		wg.Add(1)
+		go backfillWorker(ctx, teamID, limiter.C, jobs, results, &wg)
+	}
+
+	for _, gameweek := range gameweeks {
+		jobs <- gameweek
+	}
+	close(jobs)
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Results are written from here rather than the workers so SQLite only
+	// sees one writer. Upserting means a retry never duplicates a week.
+	completed := 0
+	failed := []int{}
+	for result := range results {
+		err := result.err
+		if err == nil {
+			row := resultRow(flattenAPIResults(result.history, teamID, userID))
+			err = UpsertRows(pb.Dao(), "results", resultConflictColumns, resultUpdateColumns, []dbx.Params{row})
+		}
+		if err != nil {
+			log.Printf("[Backfill] Error importing gameweek %d for team %d: %v", result.gameweek, teamID, err)
+			failed = append(failed, result.gameweek)
+		} else {
+			completed++
+		}
+
+		job.Set("completed", completed)
+		job.Set("failedGameweeks", failed)
+		if err := pb.Dao().SaveRecord(job); err != nil {
+			log.Printf("[Backfill] Error saving backfill progress: %v", err)
+		}
+	}
+
+	job.Set("status", BackfillDone)
+	if err := pb.Dao().SaveRecord(job); err != nil {
+		log.Printf("[Backfill] Error saving backfill job: %v", err)
+	}
+	log.Printf("[Backfill] Imported %d of %d gameweeks for team %d", completed, len(gameweeks), teamID)
+}
+
+func backfillWorker(
+	ctx context.Context,
+	teamID int,
+	limiter <-chan time.Time,
+	jobs <-chan int,
+	results chan<- backfillResult,
+	wg *sync.WaitGroup,
+) {
+	defer wg.Done()
+
+	for gameweek := range jobs {
+		select {
+		case <-limiter:
+		case <-ctx.Done():
+			results <- backfillResult{gameweek: gameweek, err: ctx.Err()}
+			continue
+		}
+
+		history, err := fetchGameweekPicks(ctx, teamID, gameweek)
+		results <- backfillResult{gameweek: gameweek, history: history, err: err}
+	}
+}
+
+// fetchPlayedGameweeks returns the gameweeks teamID has a score for, which
+// skips the weeks before a late signup joined FPL.
+func fetchPlayedGameweeks(ctx context.Context, teamID int) ([]int, error) {
+	endpoint := fmt.Sprintf("%s/entry/%d/history/", FPLAPIBase, teamID)
+
+	var history types.FPLEntryHistory
+	if err := fetchFPLJSON(ctx, endpoint, &history); err != nil {
+		return nil, err
+	}
+
+	gameweeks := make([]int, 0, len(history.Current))
+	for _, event := range history.Current {
+		gameweeks = append(gameweeks, event.Event)
+	}
+	return gameweeks, nil
+}
+
+func fetchGameweekPicks(ctx context.Context, teamID int, gameweek int) (types.GameweekHistory, error) {
+	endpoint := fmt.Sprintf("%s/entry/%d/event/%d/picks/", FPLAPIBase, teamID, gameweek)
+
+	var history types.GameweekHistory
+	if err := fetchFPLJSON(ctx, endpoint, &history); err != nil {
+		return history, err
+	}
+
+	// flattenAPIResults indexes all fifteen picks
+	if len(history.Players) < 15 {
+		return history, fmt.Errorf("expected 15 picks, got %d", len(history.Players))
+	}
+	return history, nil
+}
+
+func fetchFPLJSON(ctx context.Context, endpoint string, target any) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetching %s: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d for %s", resp.StatusCode, endpoint)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", endpoint, err)
+	}
+
+	if err := json.Unmarshal(body, target); err != nil {
+		return fmt.Errorf("parsing %s: %w", endpoint, err)
+	}
+	return nil
+}
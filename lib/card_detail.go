@@ -0,0 +1,74 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+)
+
+// What a player did to earn each type of card
+var cardIncidents = map[types.StatIdentifier]string{
+	types.OwnGoals:        "scored an own goal",
+	types.PenaltiesMissed: "missed a penalty",
+	types.RedCards:        "was sent off",
+}
+
+type cardContext struct {
+	PlayerName string `db:"playerName"`
+	PlayerTeam string `db:"playerTeam"`
+	HomeTeam   string `db:"homeTeam"`
+	AwayTeam   string `db:"awayTeam"`
+}
+
+// ExplainCard says what earned a card generated from match stats, e.g. "Your
+// player Saliba (ARS) scored an own goal in ARS v CHE, GW7". owner starts the
+// sentence, "Your" or a manager's name with an 's. Whatever isn't known, such
+// as the player of a card from before cards kept them, is left out.
+func ExplainCard(dao *daos.Dao, owner string, card types.DatabaseCard) string {
+	return fmt.Sprintf("%s, GW%d", cardIncident(dao, owner, card), card.Gameweek)
+}
+
+// cardIncident is ExplainCard without the gameweek
+func cardIncident(dao *daos.Dao, owner string, card types.DatabaseCard) string {
+	var details cardContext
+	if card.PlayerID != 0 || card.FixtureID != 0 {
+		season := CurrentSeasonStartYear(dao)
+		err := dao.DB().NewQuery(`
+SELECT
+    COALESCE((SELECT playerName FROM {{players}} WHERE playerID = {:playerID} AND seasonStartYear = {:season}), '') as playerName,
+    COALESCE((SELECT t.shortName FROM {{players}} p JOIN {{teams}} t ON t.teamID = p.playerTeamID AND t.seasonStartYear = p.seasonStartYear
+        WHERE p.playerID = {:playerID} AND p.seasonStartYear = {:season}), '') as playerTeam,
+    COALESCE((SELECT t.shortName FROM {{fixtures}} f JOIN {{teams}} t ON t.teamID = f.homeTeamID AND t.seasonStartYear = {:season}
+        WHERE f.fixtureID = {:fixtureID}), '') as homeTeam,
+    COALESCE((SELECT t.shortName FROM {{fixtures}} f JOIN {{teams}} t ON t.teamID = f.awayTeamID AND t.seasonStartYear = {:season}
+        WHERE f.fixtureID = {:fixtureID}), '') as awayTeam`).
+			Bind(dbx.Params{"playerID": card.PlayerID, "fixtureID": card.FixtureID, "season": season}).
+			One(&details)
+		if err != nil {
+			details = cardContext{}
+		}
+	}
+
+	var sentence strings.Builder
+	sentence.WriteString(owner + " player")
+	if details.PlayerName != "" {
+		sentence.WriteString(" " + details.PlayerName)
+		if details.PlayerTeam != "" {
+			sentence.WriteString(" (" + details.PlayerTeam + ")")
+		}
+	}
+
+	incident, ok := cardIncidents[types.StatIdentifier(card.Type)]
+	if !ok {
+		incident = "earned a card for " + ReplaceUnderscoresWithSpaces(card.Type)
+	}
+	sentence.WriteString(" " + incident)
+
+	if details.HomeTeam != "" && details.AwayTeam != "" {
+		sentence.WriteString(" in " + details.HomeTeam + " v " + details.AwayTeam)
+	}
+	return sentence.String()
+}
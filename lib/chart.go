@@ -0,0 +1,85 @@
+package lib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cmcd97/bytesize/app/types"
+)
+
+const (
+	chartWidth  = 320
+	chartHeight = 160
+
+	// room for the axis labels
+	chartPadLeft   = 32.0
+	chartPadRight  = 8.0
+	chartPadTop    = 8.0
+	chartPadBottom = 20.0
+
+	chartGridlines = 4
+)
+
+// LineChart lays out a line through values, one per label, so a view can
+// draw it as an inline SVG without any JavaScript. The y axis always includes
+// zero, and the x labels are thinned out to about ten so they don't overlap.
+func LineChart(labels []string, values []int, titles []string) types.LineChart {
+	chart := types.LineChart{
+		Width:     chartWidth,
+		Height:    chartHeight,
+		PlotLeft:  chartPadLeft,
+		PlotRight: chartWidth - chartPadRight,
+	}
+	if len(values) == 0 {
+		return chart
+	}
+
+	low, high := 0, 0
+	for _, value := range values {
+		low = min(low, value)
+		high = max(high, value)
+	}
+	if high == low {
+		high = low + 1
+	}
+
+	plotWidth := chartWidth - chartPadLeft - chartPadRight
+	plotHeight := chartHeight - chartPadTop - chartPadBottom
+	x := func(i int) float64 {
+		if len(values) == 1 {
+			return chartPadLeft + plotWidth/2
+		}
+		return chartPadLeft + plotWidth*float64(i)/float64(len(values)-1)
+	}
+	y := func(value float64) float64 {
+		return chartPadTop + plotHeight*(1-(value-float64(low))/float64(high-low))
+	}
+
+	points := make([]string, 0, len(values))
+	for i, value := range values {
+		dot := types.ChartDot{X: x(i), Y: y(float64(value))}
+		if i < len(titles) {
+			dot.Title = titles[i]
+		}
+		chart.Dots = append(chart.Dots, dot)
+		points = append(points, fmt.Sprintf("%.1f,%.1f", dot.X, dot.Y))
+	}
+	chart.Line = strings.Join(points, " ")
+
+	for i := 0; i <= chartGridlines; i++ {
+		value := float64(low) + float64(high-low)*float64(i)/chartGridlines
+		chart.Gridlines = append(chart.Gridlines, types.ChartGridline{
+			Y:     y(value),
+			Label: strconv.Itoa(int(value)),
+		})
+	}
+
+	step := (len(labels) + 9) / 10
+	for i, label := range labels {
+		if i%max(step, 1) == 0 || i == len(labels)-1 {
+			chart.XLabels = append(chart.XLabels, types.ChartLabel{X: x(i), Label: label})
+		}
+	}
+	return chart
+}
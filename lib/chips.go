@@ -0,0 +1,26 @@
+package lib
+
+// The chips FPL reports in a result's active_chip. A Free Hit needs no
+// handling of its own, the picks endpoint already returns the Free Hit squad.
+const (
+	ChipBenchBoost    = "bboost"
+	ChipTripleCaptain = "3xc"
+	ChipFreeHit       = "freehit"
+	ChipWildcard      = "wildcard"
+)
+
+var chipLabels = map[string]string{
+	ChipBenchBoost:    "BB",
+	ChipTripleCaptain: "TC",
+	ChipFreeHit:       "FH",
+	ChipWildcard:      "WC",
+}
+
+// ChipLabel returns the short name FPL players know a chip by. Chips FPL adds
+// later are shown as they come from the API.
+func ChipLabel(chip string) string {
+	if label, ok := chipLabels[chip]; ok {
+		return label
+	}
+	return chip
+}
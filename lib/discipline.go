@@ -0,0 +1,195 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+)
+
+type openCard struct {
+	Gameweek      int    `db:"gameweek"`
+	Type          string `db:"type"`
+	IsCompleted   bool   `db:"isCompleted"`
+	PlayerID      int    `db:"playerID"`
+	FixtureID     int    `db:"fixtureID"`
+	NominatorName string `db:"nominatorName"`
+}
+
+type cardGroupKey struct {
+	gameweek int
+	cardType string
+}
+
+// GetDisciplinaryStatus lists userID's open cards the way
+// updateResultsAggregated counts them, the gameweek they'd get them
+// suspended for and how many fines would need approving to avoid it. It also
+// forecasts the cards teamID's current XI would pick up from the gameweek in
+// play. FPL being unreachable only leaves the forecast out.
+func GetDisciplinaryStatus(ctx context.Context, dao *daos.Dao, userID string, teamID int) (types.DisciplinaryStatus, error) {
+	status := types.DisciplinaryStatus{Threshold: suspensionCardThreshold}
+
+	var cards []openCard
+	err := dao.DB().
+		Select(
+			"c.gameweek",
+			"c.type",
+			"c.isCompleted",
+			"c.playerID",
+			"c.fixtureID",
+			"COALESCE(nominator.firstName, '') as nominatorName").
+		From("cards c").
+		LeftJoin("users nominator", dbx.NewExp("nominator.id = c.nominatorUserID")).
+		Where(dbx.NewExp("c.userID = {:userID} AND c.adminVerified = FALSE AND c.voided = FALSE", dbx.Params{"userID": userID})).
+		OrderBy("c.gameweek asc", "c.created asc").
+		All(&cards)
+	if err != nil {
+		return status, fmt.Errorf("fetch open cards: %w", err)
+	}
+
+	// every league gets its own copy of a card, and two cards of the same type
+	// in one gameweek only count once
+	indexes := make(map[cardGroupKey]int)
+	copies := make(map[cardGroupKey]int)
+	seenCauses := make(map[cardGroupKey]map[string]bool)
+	maxGameweek := 0
+	for _, card := range cards {
+		key := cardGroupKey{card.Gameweek, card.Type}
+		i, ok := indexes[key]
+		if !ok {
+			i = len(status.OpenCards)
+			indexes[key] = i
+			seenCauses[key] = make(map[string]bool)
+			status.OpenCards = append(status.OpenCards, types.DisciplinaryCard{
+				Gameweek:         card.Gameweek,
+				Type:             card.Type,
+				AwaitingApproval: true,
+			})
+		}
+		group := &status.OpenCards[i]
+		copies[key]++
+		group.AwaitingApproval = group.AwaitingApproval && card.IsCompleted
+
+		cause := openCardCause(dao, card)
+		if !seenCauses[key][cause] {
+			seenCauses[key][cause] = true
+			group.Causes = append(group.Causes, cause)
+		}
+		maxGameweek = max(maxGameweek, card.Gameweek)
+	}
+
+	if isSuspendable(len(status.OpenCards), len(cards)) {
+		status.SuspensionGameweek = maxGameweek + 1
+
+		// clearing the cards with the most copies first takes the fewest fines
+		counts := make([]int, 0, len(copies))
+		for _, count := range copies {
+			counts = append(counts, count)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(counts)))
+		distinct, raw := len(counts), len(cards)
+		for _, count := range counts {
+			if !isSuspendable(distinct, raw) {
+				break
+			}
+			distinct--
+			raw -= count
+			status.FinesToClear++
+		}
+	} else {
+		status.CardsToSuspension = suspensionCardThreshold - len(status.OpenCards)
+	}
+
+	status.Forecast = forecastCards(ctx, dao, userID, teamID)
+	if status.SuspensionGameweek == 0 && len(status.Forecast.Cards) > 0 {
+		distinct := len(status.OpenCards)
+		forecastTypes := make(map[string]bool)
+		for _, card := range status.Forecast.Cards {
+			_, open := indexes[cardGroupKey{status.Forecast.Gameweek, card.Type}]
+			if !open && !forecastTypes[card.Type] {
+				forecastTypes[card.Type] = true
+				distinct++
+			}
+		}
+		if isSuspendable(distinct, len(cards)+len(status.Forecast.Cards)) {
+			status.Forecast.SuspensionGameweek = status.Forecast.Gameweek + 1
+		}
+	}
+
+	return status, nil
+}
+
+func isSuspendable(distinctCards, rawCards int) bool {
+	return distinctCards >= suspensionCardThreshold || rawCards >= suspensionRawCardThreshold
+}
+
+func openCardCause(dao *daos.Dao, card openCard) string {
+	switch card.Type {
+	case "nomination":
+		return "Nominated by " + card.NominatorName
+	case "reverse":
+		return "Reversed back by " + card.NominatorName
+	}
+	return cardIncident(dao, "Your", types.DatabaseCard{
+		Type:      card.Type,
+		PlayerID:  card.PlayerID,
+		FixtureID: card.FixtureID,
+	})
+}
+
+// forecastCards counts the incidents of the XI teamID fielded in the live
+// gameweek, one card each, from fixtures that have kicked off. Captain and
+// bench rules differ between leagues, so they're left out. Once the
+// gameweek's results are in its cards are real, and there's nothing to
+// forecast.
+func forecastCards(ctx context.Context, dao *daos.Dao, userID string, teamID int) types.CardForecast {
+	forecast := types.CardForecast{Gameweek: LiveGameweek(dao)}
+	if forecast.Gameweek == 0 {
+		return forecast
+	}
+
+	if _, err := dao.FindFirstRecordByFilter(
+		"results",
+		"userID = {:userID} && gameweek = {:gameweek}",
+		dbx.Params{"userID": userID, "gameweek": forecast.Gameweek},
+	); err == nil {
+		forecast.Issued = true
+		return forecast
+	}
+
+	result, err := cachedLivePicks(ctx, teamID, userID, forecast.Gameweek)
+	if err != nil {
+		log.Printf("[Discipline] Error fetching picks of team %d for gameweek %d: %v", teamID, forecast.Gameweek, err)
+		forecast.Unavailable = true
+		return forecast
+	}
+	eventsMap, finished, err := fetchLiveEvents(ctx, dao, forecast.Gameweek)
+	if err != nil {
+		log.Printf("[Discipline] Error fetching live stats for gameweek %d: %v", forecast.Gameweek, err)
+		forecast.Unavailable = true
+		return forecast
+	}
+
+	starters, _ := effectiveStarters(result)
+	for _, playerID := range starters {
+		for _, event := range eventsMap[playerID] {
+			cause := cardIncident(dao, "Your", types.DatabaseCard{
+				Type:      event.EventType,
+				PlayerID:  event.PlayerID,
+				FixtureID: event.FixtureID,
+			})
+			for range event.EventValue {
+				forecast.Cards = append(forecast.Cards, types.ForecastCard{
+					Type:     event.EventType,
+					Cause:    cause,
+					Finished: finished[event.FixtureID],
+				})
+			}
+		}
+	}
+	return forecast
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -73,7 +74,7 @@ func hourlyDataCheck(pb *pocketbase.PocketBase, c *cron.Cron) error {
 		Timeout: 10 * time.Second,
 	}
 
-	endpoint := "https://fantasy.premierleague.com/api/event-status/"
+	endpoint := FPLAPIBase + "/event-status/"
 	resp, err := client.Get(endpoint)
 	if err != nil {
 		log.Printf("[HourlyDataCheck] API request failed: %v", err)
@@ -105,7 +106,7 @@ func hourlyDataCheck(pb *pocketbase.PocketBase, c *cron.Cron) error {
 
 	if response.Leagues == "Updated" {
 		gameweek := response.Status[0].Event
-		err := checkForEventsUpdate(pb)
+		_, err := checkForEventsUpdate(pb)
 		if err != nil {
 			log.Printf("[HourlyDataCheck] Failed to update events: %v", err)
 			return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to update events: %v", err))
@@ -126,6 +127,16 @@ func hourlyDataCheck(pb *pocketbase.PocketBase, c *cron.Cron) error {
 			log.Printf("[HourlyDataCheck] Failed to update results aggregated: %v", err)
 			return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to update results aggregated: %v", err))
 		}
+		// the real cards replace the provisional ones from the live stats
+		if err := clearConfirmedProvisionalCards(pb.Dao()); err != nil {
+			log.Printf("[HourlyDataCheck] Failed to clear provisional cards: %v", err)
+		}
+		if err := GenerateRecaps(pb, gameweek); err != nil {
+			log.Printf("[HourlyDataCheck] Failed to build gameweek recaps: %v", err)
+		}
+		if err := SyncFineLedgers(pb); err != nil {
+			log.Printf("[HourlyDataCheck] Failed to update fine ledgers: %v", err)
+		}
 
 		// Stop cron job
 		c.Remove("Hourly ETL")
@@ -142,7 +153,7 @@ func ManualDataCheck(pb *pocketbase.PocketBase) error {
 		Timeout: 10 * time.Second,
 	}
 
-	endpoint := "https://fantasy.premierleague.com/api/event-status/"
+	endpoint := FPLAPIBase + "/event-status/"
 	resp, err := client.Get(endpoint)
 	if err != nil {
 		log.Printf("[HourlyDataCheck] API request failed: %v", err)
@@ -174,7 +185,7 @@ func ManualDataCheck(pb *pocketbase.PocketBase) error {
 
 	if response.Leagues == "Updated" {
 		gameweek := response.Status[0].Event
-		err := checkForEventsUpdate(pb)
+		_, err := checkForEventsUpdate(pb)
 		if err != nil {
 			log.Printf("[HourlyDataCheck] Failed to update events: %v", err)
 			return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to update events: %v", err))
@@ -195,6 +206,16 @@ func ManualDataCheck(pb *pocketbase.PocketBase) error {
 			log.Printf("[HourlyDataCheck] Failed to update results aggregated: %v", err)
 			return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to update results aggregated: %v", err))
 		}
+		// the real cards replace the provisional ones from the live stats
+		if err := clearConfirmedProvisionalCards(pb.Dao()); err != nil {
+			log.Printf("[HourlyDataCheck] Failed to clear provisional cards: %v", err)
+		}
+		if err := GenerateRecaps(pb, gameweek); err != nil {
+			log.Printf("[HourlyDataCheck] Failed to build gameweek recaps: %v", err)
+		}
+		if err := SyncFineLedgers(pb); err != nil {
+			log.Printf("[HourlyDataCheck] Failed to update fine ledgers: %v", err)
+		}
 
 		return nil
 	}
@@ -225,24 +246,8 @@ func getTodayMidnight() time.Time {
 func CheckForFixtureUpdates(e *core.ServeEvent, pb *pocketbase.PocketBase) error {
 	log.Println("[FixtureUpdate] Starting fixture update check")
 
-	// Fetch existing fixtures from DB
-	var existingFixtures []types.DatabaseFixtures
-	err := pb.Dao().DB().
-		NewQuery("SELECT * FROM fixtures").
-		All(&existingFixtures)
-
-	if err != nil {
-		return fmt.Errorf("failed to get existing fixtures: %w", err)
-	}
-
-	// Create map of existing fixtures for easy lookup
-	fixturesMap := make(map[int]types.DatabaseFixtures)
-	for _, f := range existingFixtures {
-		fixturesMap[f.FixtureID] = f
-	}
-
 	// Fetch latest fixtures from API
-	endpoint := "https://fantasy.premierleague.com/api/fixtures/"
+	endpoint := FPLAPIBase + "/fixtures/"
 	resp, err := http.Get(endpoint)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to fetch fixtures: %v", err))
@@ -259,54 +264,8 @@ func CheckForFixtureUpdates(e *core.ServeEvent, pb *pocketbase.PocketBase) error
 		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to parse fixture data: %v", err))
 	}
 
-	err = pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
-		collection, err := txDao.FindCollectionByNameOrId("fixtures")
-		if err != nil {
-			return fmt.Errorf("error finding collection: %w", err)
-		}
-
-		for _, apiFixture := range apiFixtures {
-			existingFixture, exists := fixturesMap[apiFixture.FixtureID]
-
-			if !exists {
-				// New fixture - insert
-				record := models.NewRecord(collection)
-				record.Set("fixtureID", apiFixture.FixtureID)
-				record.Set("gameweek", apiFixture.Gameweek)
-				record.Set("kickoff", apiFixture.Kickoff)
-				record.Set("homeTeamID", apiFixture.HomeTeamID)
-				record.Set("awayTeamID", apiFixture.AwayTeamID)
-
-				if err := txDao.SaveRecord(record); err != nil {
-					return fmt.Errorf("error saving new fixture: %w", err)
-				}
-				log.Printf("[FixtureUpdate] Added new fixture ID: %d", apiFixture.FixtureID)
-				continue
-			}
-
-			// Check if fixture needs updating
-			if existingFixture.ToAPIFixtures() != apiFixture {
-				record, err := txDao.FindFirstRecordByData("fixtures", "fixtureID", existingFixture.FixtureID)
-				if err != nil {
-					return fmt.Errorf("error finding existing fixture: %w", err)
-				}
-
-				record.Set("gameweek", apiFixture.Gameweek)
-				record.Set("kickoff", apiFixture.Kickoff)
-				record.Set("homeTeamID", apiFixture.HomeTeamID)
-				record.Set("awayTeamID", apiFixture.AwayTeamID)
-
-				if err := txDao.SaveRecord(record); err != nil {
-					return fmt.Errorf("error updating fixture: %w", err)
-				}
-				log.Printf("[FixtureUpdate] Updated fixture ID: %d", apiFixture.FixtureID)
-			}
-		}
-		return nil
-	})
-
-	if err != nil {
-		return fmt.Errorf("transaction failed: %w", err)
+	if err := UpsertRows(pb.Dao(), "fixtures", fixtureConflictColumns, fixtureUpdateColumns, fixtureRows(apiFixtures)); err != nil {
+		return fmt.Errorf("error saving fixtures: %w", err)
 	}
 
 	log.Println("[FixtureUpdate] Fixture update check completed")
@@ -316,25 +275,8 @@ func CheckForFixtureUpdates(e *core.ServeEvent, pb *pocketbase.PocketBase) error
 func CheckForPlayerUpdates(e *core.ServeEvent, pb *pocketbase.PocketBase) error {
 	log.Println("[PlayerUpdate] Starting player update check")
 
-	// Fetch existing players from DB
-	var existingPlayers []types.DatabasePlayer
-	err := pb.Dao().DB().
-		NewQuery("SELECT * FROM players").
-		All(&existingPlayers)
-
-	if err != nil {
-		fmt.Println(err)
-		return fmt.Errorf("failed to get existing players: %w", err)
-	}
-
-	// Create map of existing players for easy lookup
-	playersMap := make(map[int]types.DatabasePlayer)
-	for _, p := range existingPlayers {
-		playersMap[p.PlayerID] = p
-	}
-
 	// Fetch latest players from API
-	endpoint := "https://fantasy.premierleague.com/api/bootstrap-static/"
+	endpoint := FPLAPIBase + "/bootstrap-static/"
 	resp, err := http.Get(endpoint)
 	if err != nil {
 		fmt.Println(err)
@@ -353,176 +295,50 @@ func CheckForPlayerUpdates(e *core.ServeEvent, pb *pocketbase.PocketBase) error
 		fmt.Println(err)
 		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to parse player data: %v", err))
 	}
-	apiPlayers := apiResponseFull.Elements
-
-	err = pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
-		collection, err := txDao.FindCollectionByNameOrId("players")
-		if err != nil {
-			fmt.Println(err)
-			return fmt.Errorf("error finding collection: %w", err)
-		}
-
-		for _, apiPlayer := range apiPlayers {
-			existingPlayer, exists := playersMap[apiPlayer.ID]
-
-			if !exists {
-				// New player - insert
-				record := models.NewRecord(collection)
-				record.Set("playerID", apiPlayer.ID)
-				record.Set("playerTeamID", apiPlayer.Team)
-				record.Set("playerName", apiPlayer.WebName)
-
-				if err := txDao.SaveRecord(record); err != nil {
-					fmt.Println(err)
-					return fmt.Errorf("error saving new player: %w", err)
-				}
-				log.Printf("[PlayerUpdate] Added new player ID: %d", apiPlayer.ID)
-				continue
-			}
-
-			// Check if player needs updating
-			if existingPlayer.ToAPIPlayers() != apiPlayer {
-				record, err := txDao.FindFirstRecordByData("players", "playerID", existingPlayer.PlayerID)
-				if err != nil {
-					fmt.Println(err)
-					return fmt.Errorf("error finding existing player: %w", err)
-				}
-
-				record.Set("playerTeamID", apiPlayer.Team)
-				record.Set("playerName", apiPlayer.WebName)
-
-				if err := txDao.SaveRecord(record); err != nil {
-					fmt.Println(err)
-					return fmt.Errorf("error updating player: %w", err)
-				}
-				log.Printf("[PlayerUpdate] Updated player ID: %d", apiPlayer.ID)
-			}
-		}
-		return nil
-	})
-
+	currentYear, err := seasonStartYear(&apiResponseFull)
 	if err != nil {
-		return fmt.Errorf("transaction failed: %w", err)
+		return err
+	}
+
+	if err := UpsertRows(pb.Dao(), "players", playerConflictColumns, playerUpdateColumns, playerRows(apiResponseFull.Elements, currentYear)); err != nil {
+		return fmt.Errorf("error saving players: %w", err)
 	}
 
 	log.Println("[PlayerUpdate] Player update check completed")
 	return nil
 }
 
-func checkForEventsUpdate(pb *pocketbase.PocketBase) error {
+// checkForEventsUpdate imports the latest fixture stats and returns how many
+// existing events FPL revised.
+func checkForEventsUpdate(pb *pocketbase.PocketBase) (int, error) {
 	log.Println("[EventUpdate] Starting event update check")
 
-	// Fetch existing players from DB
-	var existingEvents []types.DatabaseFixtureStats
-	err := pb.Dao().DB().
-		NewQuery("SELECT * FROM events").
-		All(&existingEvents)
-
-	if err != nil {
-		fmt.Println(err)
-		return fmt.Errorf("failed to get existing eventa: %w", err)
-	}
-
-	// Create map of existing players for easy lookup
-	eventsMap := make(map[string]types.DatabaseFixtureStats)
-	for _, e := range existingEvents {
-		eventsMap[e.EventHash] = e
-	}
-
 	// Fetch latest players from API
-	endpoint := "https://fantasy.premierleague.com/api/fixtures/"
+	endpoint := FPLAPIBase + "/fixtures/"
 	resp, err := http.Get(endpoint)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to fetch events: %v", err))
+		return 0, echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to fetch events: %v", err))
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to read response: %v", err))
+		return 0, echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to read response: %v", err))
 	}
 
 	var apiEvents []types.FixtureStats
 	if err := json.Unmarshal(body, &apiEvents); err != nil {
 		fmt.Print(err)
-		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to parse team data: %v", err))
+		return 0, echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to parse team data: %v", err))
 	}
 
-	err = pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
-		collection, err := txDao.FindCollectionByNameOrId("events")
-		if err != nil {
-			fmt.Println(err)
-			return fmt.Errorf("error finding collection: %w", err)
-		}
-
-		for _, apiEvent := range apiEvents {
-			if !apiEvent.Finished {
-				continue
-			}
-
-			for _, stat := range apiEvent.Stats {
-
-				// Process home team stats
-				for _, home := range stat.Home {
-					apiEventHash := CreateEventHash(apiEvent.FixtureID, apiEvent.Gameweek, string(stat.Identifier))
-					_, exists := eventsMap[apiEventHash]
-
-					if !exists {
-						// New player - insert
-						record := models.NewRecord(collection)
-						record.Set("eventHash", apiEventHash)
-						record.Set("fixtureID", apiEvent.FixtureID)
-						record.Set("gameweek", apiEvent.Gameweek)
-						record.Set("playerID", home.Element)
-						record.Set("eventType", string(stat.Identifier))
-						record.Set("eventValue", home.Value)
-
-						if err := txDao.SaveRecord(record); err != nil {
-							fmt.Println(err)
-							return fmt.Errorf("error saving new event: %w", err)
-						}
-						log.Printf("[EventUpdate] Added new event ID: %s", apiEventHash)
-						continue
-
-					}
-				}
-
-				// Process away team stats
-				for _, away := range stat.Away {
-					apiEventHash := CreateEventHash(apiEvent.FixtureID, apiEvent.Gameweek, string(stat.Identifier))
-					_, exists := eventsMap[apiEventHash]
-
-					if !exists {
-						// New player - insert
-						record := models.NewRecord(collection)
-						record.Set("eventHash", apiEventHash)
-						record.Set("fixtureID", apiEvent.FixtureID)
-						record.Set("gameweek", apiEvent.Gameweek)
-						record.Set("playerID", away.Element)
-						record.Set("eventType", string(stat.Identifier))
-						record.Set("eventValue", away.Value)
-
-						if err := txDao.SaveRecord(record); err != nil {
-							fmt.Println(err)
-							return fmt.Errorf("error saving new event: %w", err)
-						}
-						log.Printf("[EventUpdate] Added new event ID: %s", apiEventHash)
-						continue
-
-					}
-				}
-			}
-
-		}
-		return nil
-	})
-
+	revisions, err := syncFixtureEvents(pb, apiEvents)
 	if err != nil {
-		return fmt.Errorf("transaction failed: %w", err)
+		return 0, fmt.Errorf("error saving events: %w", err)
 	}
 
-	log.Println("[EventUpdate] Player update check completed")
-	return nil
+	log.Printf("[EventUpdate] Event update check completed, %d events revised", revisions)
+	return revisions, nil
 }
 
 func updateGameweekResults(pb *pocketbase.PocketBase, gameweek int) error {
@@ -554,7 +370,11 @@ func updateGameweekResults(pb *pocketbase.PocketBase, gameweek int) error {
 		pos_12,
 		pos_13,
 		pos_14,
-		pos_15
+		pos_15,
+		captain,
+		viceCaptain,
+		COALESCE(multipliers, '[]') AS multipliers,
+		COALESCE(automaticSubs, '[]') AS automaticSubs
 		FROM results`).
 		All(&existingResults)
 
@@ -593,110 +413,76 @@ func updateGameweekResults(pb *pocketbase.PocketBase, gameweek int) error {
 		latestResults = append(latestResults, result)
 	}
 
-	err = pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
-		collection, err := txDao.FindCollectionByNameOrId("results")
-		if err != nil {
-			fmt.Println(err)
-			return fmt.Errorf("error finding collection: %w", err)
-		}
-
-		for _, latestResult := range latestResults {
-			mapKey := fmt.Sprintf("%d_%s", latestResult.Gameweek, latestResult.UserID)
-			existingResult, exists := resultsMap[mapKey]
-
-			if !exists {
-				// New result - insert
-				record := models.NewRecord(collection)
-				record.Set("gameweek", latestResult.Gameweek)
-				record.Set("userID", latestResult.UserID)
-				record.Set("teamID", latestResult.TeamID)
-				record.Set("points", latestResult.Points)
-				record.Set("transfers", latestResult.Transfers)
-				record.Set("hits", latestResult.Hits/4)
-				record.Set("benchPoints", latestResult.BenchPoints)
-				record.Set("activeChip", latestResult.ActiveChip)
-				record.Set("pos_1", latestResult.Pos1)
-				record.Set("pos_2", latestResult.Pos2)
-				record.Set("pos_3", latestResult.Pos3)
-				record.Set("pos_4", latestResult.Pos4)
-				record.Set("pos_5", latestResult.Pos5)
-				record.Set("pos_6", latestResult.Pos6)
-				record.Set("pos_7", latestResult.Pos7)
-				record.Set("pos_8", latestResult.Pos8)
-				record.Set("pos_9", latestResult.Pos9)
-				record.Set("pos_10", latestResult.Pos10)
-				record.Set("pos_11", latestResult.Pos11)
-				record.Set("pos_12", latestResult.Pos12)
-				record.Set("pos_13", latestResult.Pos13)
-				record.Set("pos_14", latestResult.Pos14)
-				record.Set("pos_15", latestResult.Pos15)
-
-				if err := txDao.SaveRecord(record); err != nil {
-					fmt.Println(err)
-					return fmt.Errorf("error saving new result: %w", err)
-				}
-				log.Printf("[ResultsUpdate] Added new result for user %s gameweek %d",
-					latestResult.UserID, latestResult.Gameweek)
-				continue
-			}
+	var changedRows []dbx.Params
+	for _, latestResult := range latestResults {
+		mapKey := fmt.Sprintf("%d_%s", latestResult.Gameweek, latestResult.UserID)
+		existingResult, exists := resultsMap[mapKey]
 
-			// Check if result needs updating by comparing structs
-			if existingResult != latestResult {
-				record, err := txDao.FindFirstRecordByFilter("results", "gameweek = '{:gameweek}' && userID = {:userID}", dbx.Params{"gameweek": gameweek, "userID": latestResult.UserID})
-				if err != nil {
-					fmt.Println(err)
-					return fmt.Errorf("error finding existing result: %w", err)
-				}
-
-				record.Set("gameweek", latestResult.Gameweek)
-				record.Set("userID", latestResult.UserID)
-				record.Set("teamID", latestResult.TeamID)
-				record.Set("points", latestResult.Points)
-				record.Set("transfers", latestResult.Transfers)
-				record.Set("hits", latestResult.Hits/4)
-				record.Set("benchPoints", latestResult.BenchPoints)
-				record.Set("activeChip", latestResult.ActiveChip)
-				record.Set("pos_1", latestResult.Pos1)
-				record.Set("pos_2", latestResult.Pos2)
-				record.Set("pos_3", latestResult.Pos3)
-				record.Set("pos_4", latestResult.Pos4)
-				record.Set("pos_5", latestResult.Pos5)
-				record.Set("pos_6", latestResult.Pos6)
-				record.Set("pos_7", latestResult.Pos7)
-				record.Set("pos_8", latestResult.Pos8)
-				record.Set("pos_9", latestResult.Pos9)
-				record.Set("pos_10", latestResult.Pos10)
-				record.Set("pos_11", latestResult.Pos11)
-				record.Set("pos_12", latestResult.Pos12)
-				record.Set("pos_13", latestResult.Pos13)
-				record.Set("pos_14", latestResult.Pos14)
-				record.Set("pos_15", latestResult.Pos15)
-
-				if err := txDao.SaveRecord(record); err != nil {
-					fmt.Println(err)
-					return fmt.Errorf("error updating result: %w", err)
-				}
-				log.Printf("[ResultsUpdate] Updated result for user %s gameweek %d",
-					latestResult.UserID, latestResult.Gameweek)
-			}
+		// Check if result needs updating by comparing structs
+		if exists && existingResult == latestResult {
+			continue
 		}
-		return nil
-	})
+		changedRows = append(changedRows, resultRow(latestResult))
+		log.Printf("[ResultsUpdate] Saving result for user %s gameweek %d",
+			latestResult.UserID, latestResult.Gameweek)
+	}
 
-	if err != nil {
-		return fmt.Errorf("transaction failed: %w", err)
+	if err := UpsertRows(pb.Dao(), "results", resultConflictColumns, resultUpdateColumns, changedRows); err != nil {
+		return fmt.Errorf("error saving results: %w", err)
 	}
 
 	log.Println("[ResultsUpdate] Results update completed")
 	return nil
 }
 
-func getTeamGameweekResult(teamID, gameweek int, userID string) (types.DatabaseResults, error) {
+var (
+	resultConflictColumns = []string{"gameweek", "userID", "teamID"}
+	resultUpdateColumns   = []string{
+		"points", "transfers", "hits", "benchPoints", "activeChip",
+		"pos_1", "pos_2", "pos_3", "pos_4", "pos_5", "pos_6", "pos_7", "pos_8",
+		"pos_9", "pos_10", "pos_11", "pos_12", "pos_13", "pos_14", "pos_15",
+		"captain", "viceCaptain", "multipliers", "automaticSubs",
+	}
+)
 
-	baseEndpoint := "https://fantasy.premierleague.com/api/entry/%d/event/%d/picks/"
+// resultRow converts a result into a results row. Hits comes from FPL as the
+// points cost, so it's stored as the number of transfers it paid for.
+func resultRow(result types.DatabaseResults) dbx.Params {
+	return dbx.Params{
+		"gameweek":      result.Gameweek,
+		"userID":        result.UserID,
+		"teamID":        result.TeamID,
+		"points":        result.Points,
+		"transfers":     result.Transfers,
+		"hits":          result.Hits / 4,
+		"benchPoints":   result.BenchPoints,
+		"activeChip":    result.ActiveChip,
+		"pos_1":         result.Pos1,
+		"pos_2":         result.Pos2,
+		"pos_3":         result.Pos3,
+		"pos_4":         result.Pos4,
+		"pos_5":         result.Pos5,
+		"pos_6":         result.Pos6,
+		"pos_7":         result.Pos7,
+		"pos_8":         result.Pos8,
+		"pos_9":         result.Pos9,
+		"pos_10":        result.Pos10,
+		"pos_11":        result.Pos11,
+		"pos_12":        result.Pos12,
+		"pos_13":        result.Pos13,
+		"pos_14":        result.Pos14,
+		"pos_15":        result.Pos15,
+		"captain":       result.Captain,
+		"viceCaptain":   result.ViceCaptain,
+		"multipliers":   result.Multipliers,
+		"automaticSubs": result.AutomaticSubs,
+	}
+}
+
+func getTeamGameweekResult(teamID, gameweek int, userID string) (types.DatabaseResults, error) {
 
 	// Fetch latest results from API
-	endpoint := fmt.Sprintf(baseEndpoint, teamID, gameweek)
+	endpoint := fmt.Sprintf("%s/entry/%d/event/%d/picks/", FPLAPIBase, teamID, gameweek)
 	resp, err := http.Get(endpoint)
 	if err != nil {
 		return types.DatabaseResults{}, echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to fetch events: %v", err))
@@ -717,32 +503,49 @@ func getTeamGameweekResult(teamID, gameweek int, userID string) (types.DatabaseR
 	return flattenAPIResults(result, teamID, userID), nil
 }
 
+// flattenAPIResults converts a picks response into a results row. Picks come
+// ordered by position, starters first.
 func flattenAPIResults(results types.GameweekHistory, teamID int, userID string) types.DatabaseResults {
-	return types.DatabaseResults{
-		Gameweek:    results.GameweekHistrory.Gameweek,
-		UserID:      userID,
-		TeamID:      teamID,
-		Points:      results.GameweekHistrory.Points,
-		Transfers:   results.GameweekHistrory.Transfers,
-		Hits:        results.GameweekHistrory.TransferCost,
-		BenchPoints: results.GameweekHistrory.BenchPoints,
-		ActiveChip:  results.ActiveChip,
-		Pos1:        results.Players[0].PlayerID,
-		Pos2:        results.Players[1].PlayerID,
-		Pos3:        results.Players[2].PlayerID,
-		Pos4:        results.Players[3].PlayerID,
-		Pos5:        results.Players[4].PlayerID,
-		Pos6:        results.Players[5].PlayerID,
-		Pos7:        results.Players[6].PlayerID,
-		Pos8:        results.Players[7].PlayerID,
-		Pos9:        results.Players[8].PlayerID,
-		Pos10:       results.Players[9].PlayerID,
-		Pos11:       results.Players[10].PlayerID,
-		Pos12:       results.Players[11].PlayerID,
-		Pos13:       results.Players[12].PlayerID,
-		Pos14:       results.Players[13].PlayerID,
-		Pos15:       results.Players[14].PlayerID,
+	result := types.DatabaseResults{
+		Gameweek:      results.GameweekHistrory.Gameweek,
+		UserID:        userID,
+		TeamID:        teamID,
+		Points:        results.GameweekHistrory.Points,
+		Transfers:     results.GameweekHistrory.Transfers,
+		Hits:          results.GameweekHistrory.TransferCost,
+		BenchPoints:   results.GameweekHistrory.BenchPoints,
+		ActiveChip:    results.ActiveChip,
+		Pos1:          results.Players[0].PlayerID,
+		Pos2:          results.Players[1].PlayerID,
+		Pos3:          results.Players[2].PlayerID,
+		Pos4:          results.Players[3].PlayerID,
+		Pos5:          results.Players[4].PlayerID,
+		Pos6:          results.Players[5].PlayerID,
+		Pos7:          results.Players[6].PlayerID,
+		Pos8:          results.Players[7].PlayerID,
+		Pos9:          results.Players[8].PlayerID,
+		Pos10:         results.Players[9].PlayerID,
+		Pos11:         results.Players[10].PlayerID,
+		Pos12:         results.Players[11].PlayerID,
+		Pos13:         results.Players[12].PlayerID,
+		Pos14:         results.Players[13].PlayerID,
+		Pos15:         results.Players[14].PlayerID,
+		AutomaticSubs: encodePicksJSON(results.AutomaticSubs),
+	}
+
+	multipliers := make([]int, 0, len(results.Players))
+	for _, pick := range results.Players {
+		multipliers = append(multipliers, pick.Multiplier)
+		if pick.IsCaptain {
+			result.Captain = pick.PlayerID
+		}
+		if pick.IsViceCaptain {
+			result.ViceCaptain = pick.PlayerID
+		}
 	}
+	result.Multipliers = encodePicksJSON(multipliers)
+
+	return result
 }
 
 func updateCards(pb *pocketbase.PocketBase) error {
@@ -763,6 +566,12 @@ func updateCards(pb *pocketbase.PocketBase) error {
 	}
 	log.Printf("[CardsUpdate] Fetched league data for %d users", len(leagueMap))
 
+	leagueRules, err := fetchLeagueCardRules(pb)
+	if err != nil {
+		log.Printf("[CardsUpdate] Error fetching league card rules: %v", err)
+		return err
+	}
+
 	resultsMap, err := fetchResults(pb)
 	if err != nil {
 		log.Printf("[CardsUpdate] Error fetching results: %v", err)
@@ -777,6 +586,15 @@ func updateCards(pb *pocketbase.PocketBase) error {
 	}
 	log.Printf("[CardsUpdate] Fetched events for %d players", len(eventsMap))
 
+	// A gameweek's cards are only voided once its events are in, so one that
+	// hasn't been imported yet doesn't lose every card it has
+	importedGameweeks := make(map[int]bool)
+	for _, events := range eventsMap {
+		for _, event := range events {
+			importedGameweeks[event.Gameweek] = true
+		}
+	}
+
 	// Setup concurrent processing
 	workerCount := 5 // Adjust based on your needs
 	userIDs := make([]string, 0, len(resultsMap))
@@ -794,7 +612,7 @@ func updateCards(pb *pocketbase.PocketBase) error {
 	for w := 0; w < workerCount; w++ {
 		wg.Add(1)
 		log.Printf("[CardsUpdate] Starting worker %d", w+1)
-		go worker(pb, jobs, results, &wg, cardsMap, leagueMap, resultsMap, eventsMap)
+		go worker(pb, jobs, results, &wg, cardsMap, leagueMap, leagueRules, importedGameweeks, resultsMap, eventsMap)
 	}
 
 	// Send jobs
@@ -836,13 +654,15 @@ func worker(
 	wg *sync.WaitGroup,
 	cardsMap map[string][]types.DatabaseCard,
 	leagueMap map[string][]int,
+	leagueRules map[int]leagueCardRules,
+	importedGameweeks map[int]bool,
 	resultsMap map[string][]types.DatabaseResults,
 	eventsMap map[int][]types.DatabaseEvent,
 ) {
 	defer wg.Done()
 
 	for userID := range jobs {
-		err := processUser(pb, userID, cardsMap, leagueMap, resultsMap, eventsMap)
+		err := processUser(pb, userID, cardsMap, leagueMap, leagueRules, importedGameweeks, resultsMap, eventsMap)
 		results <- err
 	}
 }
@@ -852,6 +672,8 @@ func processUser(
 	userID string,
 	cardsMap map[string][]types.DatabaseCard,
 	leagueMap map[string][]int,
+	leagueRules map[int]leagueCardRules,
+	importedGameweeks map[int]bool,
 	resultsMap map[string][]types.DatabaseResults,
 	eventsMap map[int][]types.DatabaseEvent,
 ) error {
@@ -860,51 +682,167 @@ func processUser(
 		return nil
 	}
 
-	return pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
-		collection, err := txDao.FindCollectionByNameOrId("cards")
-		if err != nil {
-			return fmt.Errorf("error finding collection: %w", err)
+	var newCards []dbx.Params
+	var revisions []cardRevision
+	results := resultsMap[userID]
+	for _, result := range results {
+		processResult(&newCards, &revisions, result, userID, userLeagues, leagueRules, importedGameweeks, cardsMap, eventsMap)
+	}
+
+	// Cards that already exist are left as they are, they may have been
+	// completed or verified since
+	if err := UpsertRows(pb.Dao(), "cards", []string{"cardHash"}, nil, newCards); err != nil {
+		log.Printf("[ERROR] Failed to save cards: %v", err)
+		return fmt.Errorf("error saving card records: %w", err)
+	}
+	if err := applyCardRevisions(pb, revisions); err != nil {
+		log.Printf("[ERROR] Failed to revise cards: %v", err)
+		return fmt.Errorf("error revising cards: %w", err)
+	}
+	return nil
+}
+
+// cardTrigger is an event that earns a card, and whether it was the
+// captain's or a bench player's
+type cardTrigger struct {
+	event   types.DatabaseEvent
+	captain bool
+	bench   bool
+}
+
+// resultTriggers collects the events behind each type of card for a result's
+// squad, starters first. squadEvents holds every event FPL still records for
+// the squad, so a card that's no longer given can be told apart from one
+// whose stat was retracted.
+func resultTriggers(result types.DatabaseResults, eventsMap map[int][]types.DatabaseEvent) (map[string][]cardTrigger, map[string]bool) {
+	starters, captain := effectiveStarters(result)
+	squad := slices.Clone(starters)
+	for _, playerID := range resultPlayerIDs(result) {
+		if !slices.Contains(starters, playerID) {
+			squad = append(squad, playerID)
 		}
+	}
 
-		results := resultsMap[userID]
-		for _, result := range results {
-			if err := processResult(txDao, collection, result, userID, userLeagues, cardsMap, eventsMap); err != nil {
-				return err
+	triggers := make(map[string][]cardTrigger)
+	squadEvents := make(map[string]bool)
+	for position, playerID := range squad {
+		for _, event := range eventsMap[playerID] {
+			if event.Gameweek != result.Gameweek {
+				continue
+			}
+			if event.EventValue > 0 {
+				squadEvents[event.EventHash] = true
+			}
+			for cardIndex := 0; cardIndex < event.EventValue; cardIndex++ {
+				triggers[event.EventType] = append(triggers[event.EventType], cardTrigger{
+					event:   event,
+					captain: playerID == captain,
+					bench:   position >= len(starters),
+				})
 			}
 		}
-		return nil
-	})
+	}
+	return triggers, squadEvents
 }
 
+// deriveCardHash identifies the cardIndex'th card of a type a user gets in a
+// league and gameweek
+func deriveCardHash(userID string, leagueID int, gameweek int, eventType string, cardIndex int) string {
+	return fmt.Sprintf("%s_%d_%d_%s_%d", userID, leagueID, gameweek, eventType, cardIndex)
+}
+
+// processResult derives the cards one gameweek result earns in each of the
+// user's leagues and compares them with the cards already handed out. Only
+// the XI that actually played counts, after FPL's automatic substitutions,
+// unless the manager played Bench Boost in a league that cards the whole
+// squad for it. Cards are numbered per league, gameweek and type, so two
+// players with the same stat earn two cards, and the league's captain rules
+// can hand out extra cards for the captain's incidents.
 func processResult(
-	txDao *daos.Dao,
-	collection *models.Collection,
+	newCards *[]dbx.Params,
+	revisions *[]cardRevision,
 	result types.DatabaseResults,
 	userID string,
 	userLeagues []int,
+	leagueRules map[int]leagueCardRules,
+	importedGameweeks map[int]bool,
 	cardsMap map[string][]types.DatabaseCard,
 	eventsMap map[int][]types.DatabaseEvent,
-) error {
-	playerIDs := []int{
-		result.Pos1, result.Pos2, result.Pos3, result.Pos4, result.Pos5,
-		result.Pos6, result.Pos7, result.Pos8, result.Pos9, result.Pos10, result.Pos11,
-		result.Pos12, result.Pos13, result.Pos14, result.Pos15,
-	}
+) {
+	triggers, squadEvents := resultTriggers(result, eventsMap)
 
-	playerPositions := make(map[int]int)
-	for pos, playerID := range playerIDs {
-		playerPositions[playerID] = pos + 1
+	existingCards := make(map[string]types.DatabaseCard)
+	for _, card := range cardsMap[userID] {
+		existingCards[card.CardHash] = card
 	}
 
-	for _, playerID := range playerIDs {
-		position := playerPositions[playerID]
-		if position <= 11 {
-			if err := processPlayerEvents(txDao, collection, playerID, position, result, userID, userLeagues, cardsMap, eventsMap); err != nil {
-				return err
+	for _, leagueID := range userLeagues {
+		rules := leagueRules[leagueID]
+		if !rules.handsOutCards(result.Gameweek) {
+			continue
+		}
+
+		derived := make(map[string]bool)
+		derivedEvents := make(map[string]bool)
+		for eventType, eventTriggers := range triggers {
+			for cardIndex, event := range rules.cardEvents(eventTriggers, result.ActiveChip) {
+				cardHash := deriveCardHash(userID, leagueID, result.Gameweek, eventType, cardIndex)
+				derived[cardHash] = true
+				derivedEvents[event.EventHash] = true
+
+				existing, ok := existingCards[cardHash]
+				switch {
+				case !ok:
+					*newCards = append(*newCards, dbx.Params{
+						"teamID":          result.TeamID,
+						"userID":          userID,
+						"nominatorTeamID": 0,
+						"nominatorUserID": "",
+						"gameweek":        result.Gameweek,
+						"isCompleted":     false,
+						"adminVerified":   false,
+						"type":            eventType,
+						"leagueID":        leagueID,
+						"cardHash":        cardHash,
+						"eventHash":       event.EventHash,
+						"playerID":        event.PlayerID,
+						"fixtureID":       event.FixtureID,
+						"voided":          false,
+						"voidReason":      "",
+					})
+					log.Printf("[SUCCESS] Added new card %d of type %s for user %s in league %d gameweek %d",
+						cardIndex+1, eventType, userID, leagueID, result.Gameweek)
+				case existing.Voided:
+					*revisions = append(*revisions, cardRevision{card: existing, action: cardRestored, event: event})
+				// cards from before they kept their player and fixture are
+				// relinked too, so they can be explained
+				case existing.EventHash != event.EventHash || existing.PlayerID != event.PlayerID || existing.FixtureID != event.FixtureID:
+					*revisions = append(*revisions, cardRevision{card: existing, action: cardRelinked, event: event})
+				}
+			}
+		}
+
+		if !importedGameweeks[result.Gameweek] {
+			continue
+		}
+		for _, card := range cardsMap[userID] {
+			if card.LeagueID != leagueID || card.Gameweek != result.Gameweek || card.Voided ||
+				!eventCardTypes[types.StatIdentifier(card.Type)] || derived[card.CardHash] {
+				continue
+			}
+			var reason string
+			switch {
+			case derivedEvents[card.EventHash]:
+				reason = "The league's captain rules no longer give an extra card for this incident"
+			case squadEvents[card.EventHash]:
+				reason = "The player this card was given for no longer counts towards your team"
+			default:
+				reason = fmt.Sprintf("FPL revised the match stats and no longer records the %s this card was given for",
+					ReplaceUnderscoresWithSpaces(card.Type))
 			}
+			*revisions = append(*revisions, cardRevision{card: card, action: cardVoided, reason: reason})
 		}
 	}
-	return nil
 }
 
 func fetchExistingCards(pb *pocketbase.PocketBase) (map[string][]types.DatabaseCard, error) {
@@ -927,6 +865,10 @@ func fetchExistingCards(pb *pocketbase.PocketBase) (map[string][]types.DatabaseC
 			Type:            record.GetString("type"),
 			LeagueID:        record.GetInt("leagueID"),
 			CardHash:        record.GetString("cardHash"),
+			EventHash:       record.GetString("eventHash"),
+			PlayerID:        record.GetInt("playerID"),
+			FixtureID:       record.GetInt("fixtureID"),
+			Voided:          record.GetBool("voided"),
 		}
 
 		userID := card.UserID
@@ -940,7 +882,7 @@ func fetchUserLeagues(pb *pocketbase.PocketBase) (map[string][]int, error) {
 	leagueMap := make(map[string][]int)
 
 	records, err := pb.Dao().FindRecordsByExpr("leagues",
-		dbx.NewExp("isLinked = {:isLinked}", dbx.Params{"isLinked": true}),
+		dbx.NewExp("isLinked = {:isLinked} AND hasLeft = FALSE", dbx.Params{"isLinked": true}),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching user leagues: %w", err)
@@ -957,6 +899,84 @@ func fetchUserLeagues(pb *pocketbase.PocketBase) (map[string][]int, error) {
 	return leagueMap, nil
 }
 
+// leagueCardRules are the league settings that decide which cards a result
+// earns
+type leagueCardRules struct {
+	startGameweek             int
+	captainCardsDoubled       bool
+	benchBoostCardsAll        bool
+	tripleCaptainCardsTripled bool
+	seasonConfirmationPending bool
+}
+
+// captainCards is how many cards each of the captain's incidents earns
+func (rules leagueCardRules) captainCards(activeChip string) int {
+	switch {
+	case activeChip == ChipTripleCaptain && rules.tripleCaptainCardsTripled:
+		return 3
+	case rules.captainCardsDoubled:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// cardsBench reports whether the bench can earn cards, which is only with
+// Bench Boost when the league says so
+func (rules leagueCardRules) cardsBench(activeChip string) bool {
+	return activeChip == ChipBenchBoost && rules.benchBoostCardsAll
+}
+
+// handsOutCards reports whether the league gives cards for gameweek. Leagues
+// only hand out cards from their configured start gameweek, and not at all
+// until they've confirmed they're playing this season.
+func (rules leagueCardRules) handsOutCards(gameweek int) bool {
+	return gameweek >= rules.startGameweek && !rules.seasonConfirmationPending
+}
+
+// cardEvents lists the event behind each card one type of triggers earns in
+// the league, in card order
+func (rules leagueCardRules) cardEvents(triggers []cardTrigger, activeChip string) []types.DatabaseEvent {
+	var events []types.DatabaseEvent
+	for _, trigger := range triggers {
+		if trigger.bench && !rules.cardsBench(activeChip) {
+			continue
+		}
+		copies := 1
+		if trigger.captain {
+			copies = rules.captainCards(activeChip)
+		}
+		for range copies {
+			events = append(events, trigger.event)
+		}
+	}
+	return events
+}
+
+// fetchLeagueCardRules maps leagueID to the league's card rules. Leagues
+// without settings start from gameweek 1 and leave chips and captains out of
+// their cards.
+func fetchLeagueCardRules(pb *pocketbase.PocketBase) (map[int]leagueCardRules, error) {
+	leagueRules := make(map[int]leagueCardRules)
+
+	records, err := pb.Dao().FindRecordsByExpr("league_settings")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching league settings: %w", err)
+	}
+
+	for _, record := range records {
+		leagueRules[record.GetInt("leagueID")] = leagueCardRules{
+			startGameweek:             record.GetInt("startGameweek"),
+			captainCardsDoubled:       record.GetBool("captainCardsDoubled"),
+			benchBoostCardsAll:        record.GetBool("benchBoostCardsAll"),
+			tripleCaptainCardsTripled: record.GetBool("tripleCaptainCardsTripled"),
+			seasonConfirmationPending: record.GetBool("seasonConfirmationPending"),
+		}
+	}
+
+	return leagueRules, nil
+}
+
 func fetchResults(pb *pocketbase.PocketBase) (map[string][]types.DatabaseResults, error) {
 	resultsMap := make(map[string][]types.DatabaseResults)
 
@@ -967,24 +987,29 @@ func fetchResults(pb *pocketbase.PocketBase) (map[string][]types.DatabaseResults
 
 	for _, record := range records {
 		result := types.DatabaseResults{
-			TeamID:   record.GetInt("teamID"),
-			UserID:   record.GetString("userID"),
-			Gameweek: record.GetInt("gameweek"),
-			Pos1:     record.GetInt("pos_1"),
-			Pos2:     record.GetInt("pos_2"),
-			Pos3:     record.GetInt("pos_3"),
-			Pos4:     record.GetInt("pos_4"),
-			Pos5:     record.GetInt("pos_5"),
-			Pos6:     record.GetInt("pos_6"),
-			Pos7:     record.GetInt("pos_7"),
-			Pos8:     record.GetInt("pos_8"),
-			Pos9:     record.GetInt("pos_9"),
-			Pos10:    record.GetInt("pos_10"),
-			Pos11:    record.GetInt("pos_11"),
-			Pos12:    record.GetInt("pos_12"),
-			Pos13:    record.GetInt("pos_13"),
-			Pos14:    record.GetInt("pos_14"),
-			Pos15:    record.GetInt("pos_15"),
+			TeamID:        record.GetInt("teamID"),
+			UserID:        record.GetString("userID"),
+			Gameweek:      record.GetInt("gameweek"),
+			ActiveChip:    record.GetString("activeChip"),
+			Pos1:          record.GetInt("pos_1"),
+			Pos2:          record.GetInt("pos_2"),
+			Pos3:          record.GetInt("pos_3"),
+			Pos4:          record.GetInt("pos_4"),
+			Pos5:          record.GetInt("pos_5"),
+			Pos6:          record.GetInt("pos_6"),
+			Pos7:          record.GetInt("pos_7"),
+			Pos8:          record.GetInt("pos_8"),
+			Pos9:          record.GetInt("pos_9"),
+			Pos10:         record.GetInt("pos_10"),
+			Pos11:         record.GetInt("pos_11"),
+			Pos12:         record.GetInt("pos_12"),
+			Pos13:         record.GetInt("pos_13"),
+			Pos14:         record.GetInt("pos_14"),
+			Pos15:         record.GetInt("pos_15"),
+			Captain:       record.GetInt("captain"),
+			ViceCaptain:   record.GetInt("viceCaptain"),
+			Multipliers:   record.GetString("multipliers"),
+			AutomaticSubs: record.GetString("automaticSubs"),
 		}
 		resultsMap[result.UserID] = append(resultsMap[result.UserID], result)
 	}
@@ -1002,6 +1027,8 @@ func fetchEvents(pb *pocketbase.PocketBase) (map[int][]types.DatabaseEvent, erro
 
 	for _, record := range records {
 		event := types.DatabaseEvent{
+			EventHash:  record.GetString("eventHash"),
+			FixtureID:  record.GetInt("fixtureID"),
 			PlayerID:   record.GetInt("playerID"),
 			Gameweek:   record.GetInt("gameweek"),
 			EventType:  record.GetString("eventType"),
@@ -1013,82 +1040,6 @@ func fetchEvents(pb *pocketbase.PocketBase) (map[int][]types.DatabaseEvent, erro
 	return eventsMap, nil
 }
 
-func processPlayerEvents(
-	txDao *daos.Dao,
-	collection *models.Collection,
-	playerID int,
-	position int,
-	result types.DatabaseResults,
-	userID string,
-	userLeagues []int,
-	cardsMap map[string][]types.DatabaseCard,
-	eventsMap map[int][]types.DatabaseEvent,
-) error {
-	playerEvents := eventsMap[playerID]
-	log.Printf("[DEBUG] Processing events for player %d, found %d events", playerID, len(playerEvents))
-
-	for _, event := range playerEvents {
-		log.Printf("[DEBUG] Checking event: gameweek=%d, type=%s, value=%d (target gameweek=%d)",
-			event.Gameweek, event.EventType, event.EventValue, result.Gameweek)
-
-		if event.Gameweek == result.Gameweek {
-			log.Printf("[DEBUG] Matched gameweek %d for player %d", event.Gameweek, playerID)
-
-			for cardIndex := 0; cardIndex < event.EventValue; cardIndex++ {
-				for _, leagueID := range userLeagues {
-					cardHash := fmt.Sprintf("%s_%d_%d_%s_%d", userID, leagueID, result.Gameweek, event.EventType, cardIndex)
-					log.Printf("[DEBUG] Checking card hash: %s", cardHash)
-
-					existingCards := cardsMap[userID]
-					cardExists := false
-					for _, existing := range existingCards {
-						if existing.CardHash == cardHash {
-							cardExists = true
-							log.Printf("[DEBUG] Found existing card with hash: %s", cardHash)
-							break
-						}
-					}
-
-					if !cardExists {
-						record := models.NewRecord(collection)
-						record.Set("teamID", result.TeamID)
-						record.Set("userID", userID)
-						record.Set("nominatorTeamID", nil)
-						record.Set("nominatorUserID", "")
-						record.Set("gameweek", result.Gameweek)
-						record.Set("isCompleted", false)
-						record.Set("adminVerified", false)
-						record.Set("type", event.EventType)
-						record.Set("leagueID", leagueID)
-						record.Set("cardHash", cardHash)
-
-						err := txDao.SaveRecord(record)
-						if err != nil {
-							log.Printf("[ERROR] Failed to save card: %v", err)
-							return fmt.Errorf("error saving card record: %w", err)
-						}
-
-						// Add the new card to the cardsMap to prevent duplicates in subsequent processing
-						newCard := types.DatabaseCard{
-							TeamID:   result.TeamID,
-							UserID:   userID,
-							Gameweek: result.Gameweek,
-							Type:     event.EventType,
-							LeagueID: leagueID,
-							CardHash: cardHash,
-						}
-						cardsMap[userID] = append(cardsMap[userID], newCard)
-
-						log.Printf("[SUCCESS] Added new card %d of %d type %s for user %s in league %d gameweek %d position %d",
-							cardIndex+1, event.EventValue, event.EventType, userID, leagueID, result.Gameweek, position)
-					}
-				}
-			}
-		}
-	}
-	return nil
-}
-
 func updateResultsAggregated(pb *pocketbase.PocketBase) error {
 	log.Println("[ResultsAggregating] Starting aggregation pipeline")
 
@@ -1099,7 +1050,7 @@ func updateResultsAggregated(pb *pocketbase.PocketBase) error {
 	var outstandingCards []types.OutstandingCards
 	log.Println("[ResultsAggregating] Fetching outstanding cards...")
 	err := pb.Dao().DB().
-		NewQuery("SELECT distinct teamID, userID, gameweek, type FROM cards where adminVerified = FALSE").
+		NewQuery("SELECT distinct teamID, userID, gameweek, type FROM cards where adminVerified = FALSE AND voided = FALSE").
 		All(&outstandingCards)
 
 	if err != nil {
@@ -1127,7 +1078,7 @@ func updateResultsAggregated(pb *pocketbase.PocketBase) error {
 		for _, count := range weeks {
 			totalCards += count
 		}
-		if totalCards >= 2 {
+		if totalCards >= suspensionCardThreshold {
 			penalizedUsers = append(penalizedUsers, userID)
 		}
 	}
@@ -1155,9 +1106,9 @@ card_status AS (
         COUNT(*) as incomplete_cards,
         MAX(gameweek) as max_card_gameweek
     FROM cards
-    WHERE adminVerified = FALSE
+    WHERE adminVerified = FALSE AND voided = FALSE
     GROUP BY userID
-    HAVING COUNT(*) >= 4
+    HAVING COUNT(*) >= %d
 ),
 suspension_status AS (
     SELECT 
@@ -1169,7 +1120,7 @@ suspension_status AS (
             WHEN r.userID IN (%s) AND r.gameweek = (
                 SELECT MAX(c.gameweek) 
                 FROM cards c
-                WHERE c.adminVerified = FALSE 
+                WHERE c.adminVerified = FALSE AND c.voided = FALSE
                 AND c.userID = r.userID
             ) THEN TRUE
             ELSE FALSE
@@ -1216,7 +1167,7 @@ SELECT
     ) as totalPoints,
     isSuspendedNext
 FROM adjusted_points 
-`, penalizedUsersStr)
+`, suspensionRawCardThreshold, penalizedUsersStr)
 
 	err = pb.Dao().DB().NewQuery(query).All(&aggregatedResults)
 	if err != nil {
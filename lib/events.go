@@ -0,0 +1,219 @@
+package lib
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// syncFixtureEvents brings the events of every finished fixture in line with
+// what FPL reports now. New stats are added and corrected values updated.
+// Stats FPL has dropped (a rescinded red card, an own goal given to someone
+// else) are kept with a value of 0 rather than deleted, so updateCards can
+// tell a retraction apart from a gameweek that hasn't been imported yet.
+// Every correction and retraction is written to the audit log. It returns how
+// many events were revised.
+func syncFixtureEvents(pb *pocketbase.PocketBase, fixtures []types.FixtureStats) (int, error) {
+	rows := eventRows(fixtures)
+
+	fetched := make(map[string]dbx.Params, len(rows))
+	for _, row := range rows {
+		fetched[row["eventHash"].(string)] = row
+	}
+
+	fixtureIDs := []any{}
+	for _, fixture := range fixtures {
+		if fixture.Finished {
+			fixtureIDs = append(fixtureIDs, fixture.FixtureID)
+		}
+	}
+	if len(fixtureIDs) == 0 {
+		return 0, nil
+	}
+
+	revisions := 0
+	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		var existing []types.DatabaseFixtureStats
+		err := txDao.DB().
+			Select("eventHash", "fixtureID", "gameweek", "playerID", "eventType", "side", "eventValue").
+			From("events").
+			Where(dbx.In("fixtureID", fixtureIDs...)).
+			All(&existing)
+		if err != nil {
+			return fmt.Errorf("error fetching events: %w", err)
+		}
+
+		for _, event := range existing {
+			row, ok := fetched[event.EventHash]
+			if ok && row["eventValue"].(int) == event.EventValue {
+				continue
+			}
+			if !ok && event.EventValue == 0 {
+				continue
+			}
+
+			action := "event_corrected"
+			value := 0
+			if ok {
+				value = row["eventValue"].(int)
+			} else {
+				action = "event_retracted"
+				rows = append(rows, dbx.Params{
+					"eventHash":  event.EventHash,
+					"fixtureID":  event.FixtureID,
+					"gameweek":   event.Gameweek,
+					"playerID":   event.PlayerID,
+					"eventType":  event.EventType,
+					"side":       event.Side,
+					"eventValue": 0,
+				})
+			}
+
+			log.Printf("[EventUpdate] %s: player %d %s in fixture %d went from %d to %d",
+				action, event.PlayerID, event.EventType, event.FixtureID, event.EventValue, value)
+			err := WriteAuditLog(txDao, "", action, "", map[string]any{
+				"eventHash": event.EventHash,
+				"fixtureID": event.FixtureID,
+				"gameweek":  event.Gameweek,
+				"playerID":  event.PlayerID,
+				"eventType": event.EventType,
+				"from":      event.EventValue,
+				"to":        value,
+			})
+			if err != nil {
+				return err
+			}
+			revisions++
+		}
+
+		return UpsertRows(txDao, "events", eventConflictColumns, eventUpdateColumns, rows)
+	})
+
+	return revisions, err
+}
+
+const (
+	cardVoided   = "card_voided"
+	cardRestored = "card_restored"
+	cardRelinked = "card_relinked"
+)
+
+// The stats that earn cards. Cards of any other type (nominations, reversed
+// cards) don't come from events and are never revised.
+var eventCardTypes = map[types.StatIdentifier]bool{
+	types.OwnGoals:        true,
+	types.PenaltiesMissed: true,
+	types.RedCards:        true,
+}
+
+// cardRevision is a change to a card that already exists, found by deriving
+// its gameweek's cards again from the current events.
+type cardRevision struct {
+	card   types.DatabaseCard
+	action string
+	event  types.DatabaseEvent
+	reason string
+}
+
+// setCardEvent points a card at the event that earns it
+func setCardEvent(card *models.Record, event types.DatabaseEvent) {
+	card.Set("eventHash", event.EventHash)
+	card.Set("playerID", event.PlayerID)
+	card.Set("fixtureID", event.FixtureID)
+}
+
+// applyCardRevisions voids cards whose event FPL retracted, restores voided
+// cards whose event came back and points cards at the event that now earns
+// them. Voiding and restoring are written to the audit log against the card's
+// owner, who is also notified. Suspensions that relied on a voided card are
+// lifted.
+func applyCardRevisions(pb *pocketbase.PocketBase, revisions []cardRevision) error {
+	if len(revisions) == 0 {
+		return nil
+	}
+
+	return pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		// The earliest gameweek each user lost a card in
+		voidedFrom := make(map[string]int)
+		// Every league has its own copy of a card, but its owner only hears
+		// about it once
+		notified := make(map[string]bool)
+
+		for _, revision := range revisions {
+			card, err := txDao.FindFirstRecordByData("cards", "cardHash", revision.card.CardHash)
+			if err != nil {
+				return fmt.Errorf("error finding card %s: %w", revision.card.CardHash, err)
+			}
+
+			switch revision.action {
+			case cardVoided:
+				card.Set("voided", true)
+				card.Set("voidReason", revision.reason)
+			case cardRestored:
+				card.Set("voided", false)
+				card.Set("voidReason", "")
+				setCardEvent(card, revision.event)
+			case cardRelinked:
+				setCardEvent(card, revision.event)
+			}
+			if err := txDao.SaveRecord(card); err != nil {
+				return fmt.Errorf("error saving card %s: %w", revision.card.CardHash, err)
+			}
+
+			// Cards imported before events had their own identity are relinked
+			// on the first run, which isn't worth an audit entry each
+			if revision.action == cardRelinked {
+				continue
+			}
+
+			log.Printf("[CardsUpdate] %s: card %s for user %s", revision.action, revision.card.CardHash, revision.card.UserID)
+			err = WriteAuditLog(txDao, "", revision.action, card.GetString("userID"), map[string]any{
+				"cardHash":  revision.card.CardHash,
+				"leagueID":  revision.card.LeagueID,
+				"gameweek":  revision.card.Gameweek,
+				"type":      revision.card.Type,
+				"eventHash": card.GetString("eventHash"),
+				"reason":    revision.reason,
+			})
+			if err != nil {
+				return err
+			}
+
+			userID := card.GetString("userID")
+			incident := cardIncident(txDao, "your", types.DatabaseCard{
+				Type:      card.GetString("type"),
+				PlayerID:  card.GetInt("playerID"),
+				FixtureID: card.GetInt("fixtureID"),
+			})
+			message := fmt.Sprintf("Your gameweek %d card is back, FPL has restored the stat it was given for: %s.",
+				revision.card.Gameweek, incident)
+			if revision.action == cardVoided {
+				message = fmt.Sprintf("Your gameweek %d card has been voided. It was given because %s. %s.",
+					revision.card.Gameweek, incident, revision.reason)
+				if from, ok := voidedFrom[userID]; !ok || revision.card.Gameweek < from {
+					voidedFrom[userID] = revision.card.Gameweek
+				}
+			}
+			key := fmt.Sprintf("%s:%s:%d:%s", userID, revision.action, revision.card.Gameweek, card.GetString("type"))
+			if notified[key] {
+				continue
+			}
+			notified[key] = true
+			if err := Notify(txDao, userID, revision.action, message); err != nil {
+				return err
+			}
+		}
+
+		for userID, gameweek := range voidedFrom {
+			if err := liftUnsupportedSuspensions(txDao, userID, gameweek); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,343 @@
+package lib
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/daos"
+)
+
+const (
+	ExportCSV  = "csv"
+	ExportJSON = "json"
+
+	lastGameweek = 38
+	// rows written between flushes, so a large export reaches the client as
+	// it's read rather than all at the end
+	exportFlushRows = 500
+)
+
+// ExportDatasets are the league data that can be exported, each a list of
+// one of the Export* rows in app/types
+var ExportDatasets = []string{"cards", "standings", "fines", "nominations"}
+
+// ExportRequest picks a league's data to export, from the current season's
+// gameweeks FromGameweek to ToGameweek
+type ExportRequest struct {
+	LeagueID     int
+	Dataset      string
+	Format       string
+	FromGameweek int
+	ToGameweek   int
+}
+
+// NewExportRequest is an export of dataset as format across the whole season
+func NewExportRequest(leagueID int, dataset, format string) ExportRequest {
+	return ExportRequest{
+		LeagueID:     leagueID,
+		Dataset:      dataset,
+		Format:       format,
+		FromGameweek: 1,
+		ToGameweek:   lastGameweek,
+	}
+}
+
+// Validate says what's wrong with the request, in words fit for the person who
+// made it
+func (request ExportRequest) Validate() error {
+	if !slices.Contains(ExportDatasets, request.Dataset) {
+		return fmt.Errorf("dataset must be one of %s", strings.Join(ExportDatasets, ", "))
+	}
+	if request.Format != ExportCSV && request.Format != ExportJSON {
+		return fmt.Errorf("format must be %s or %s", ExportCSV, ExportJSON)
+	}
+	if request.FromGameweek < 1 || request.ToGameweek > lastGameweek || request.FromGameweek > request.ToGameweek {
+		return fmt.Errorf("gameweeks must be a range between 1 and %d", lastGameweek)
+	}
+	return nil
+}
+
+// Filename is what an export is saved as, e.g. league-1234-cards-gw1-38.csv
+func (request ExportRequest) Filename() string {
+	return fmt.Sprintf("league-%d-%s-gw%d-%d.%s",
+		request.LeagueID, request.Dataset, request.FromGameweek, request.ToGameweek, request.Format)
+}
+
+// ContentType is the MIME type of an export's format
+func (request ExportRequest) ContentType() string {
+	if request.Format == ExportJSON {
+		return "application/json; charset=utf-8"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// ExportLeague runs an export from the command line, where the migrations may
+// not have run yet
+func ExportLeague(pb *pocketbase.PocketBase, w io.Writer, request ExportRequest) error {
+	if err := runMigrations(pb); err != nil {
+		return err
+	}
+	return WriteLeagueExport(pb.Dao(), w, request)
+}
+
+// WriteLeagueExport streams a league's data to w a row at a time, so even a
+// large league's export isn't held in memory. Cards and results are archived
+// at the end of a season, so exports cover the current season.
+func WriteLeagueExport(dao *daos.Dao, w io.Writer, request ExportRequest) error {
+	if err := request.Validate(); err != nil {
+		return err
+	}
+
+	params := dbx.Params{
+		"leagueID": request.LeagueID,
+		"from":     request.FromGameweek,
+		"to":       request.ToGameweek,
+	}
+
+	switch request.Dataset {
+	case "cards":
+		query := dao.DB().NewQuery(`
+SELECT
+    c.gameweek,
+    c.leagueID,
+    c.cardHash,
+    c.userID,
+    c.teamID,
+    TRIM(COALESCE(u.firstName, '') || ' ' || COALESCE(u.lastName, '')) as manager,
+    c.type,
+    CASE
+        WHEN c.voided = TRUE THEN 'voided'
+        WHEN COALESCE(c.fineApprovedAt, '') != '' THEN 'fine_approved'
+        WHEN c.adminVerified = TRUE THEN 'cleared'
+        WHEN c.isCompleted = TRUE THEN 'awaiting_approval'
+        ELSE 'open'
+    END as status,
+    c.nominatorUserID,
+    TRIM(COALESCE(n.firstName, '') || ' ' || COALESCE(n.lastName, '')) as nominator,
+    c.created
+FROM {{cards}} c
+LEFT JOIN {{users}} u ON u.id = c.userID
+LEFT JOIN {{users}} n ON n.id = c.nominatorUserID
+WHERE c.leagueID = {:leagueID} AND c.gameweek BETWEEN {:from} AND {:to}
+ORDER BY c.gameweek, c.created, c.cardHash`).Bind(params)
+		return streamExport[types.ExportCard](query, w, request.Format)
+
+	case "standings":
+		// standings only start from the league's start gameweek
+		startGameweek := 1
+		if settings, err := dao.FindFirstRecordByFilter("league_settings", "leagueID = {:leagueID}",
+			dbx.Params{"leagueID": request.LeagueID}); err == nil {
+			startGameweek = max(settings.GetInt("startGameweek"), 1)
+		}
+		params["from"] = max(request.FromGameweek, startGameweek)
+		params["start"] = startGameweek - 1
+
+		query := dao.DB().NewQuery(`
+WITH members AS (
+    SELECT DISTINCT teamID FROM {{leagues}}
+    WHERE leagueID = {:leagueID} AND hasLeft = FALSE
+),
+standings AS (
+    SELECT
+        ag.gameweek,
+        ag.userID,
+        ag.teamID,
+        TRIM(COALESCE(u.firstName, '') || ' ' || COALESCE(u.lastName, '')) as manager,
+        ag.totalPoints - COALESCE(s.totalPoints, 0) as leaguePoints,
+        ag.points,
+        ag.totalPoints,
+        ag.isSuspendedNext
+    FROM {{aggregated_results}} ag
+    JOIN members m ON m.teamID = ag.teamID
+    LEFT JOIN {{aggregated_results}} s ON s.userID = ag.userID AND s.gameweek = {:start}
+    LEFT JOIN {{users}} u ON u.id = ag.userID
+    WHERE ag.gameweek BETWEEN {:from} AND {:to}
+)
+SELECT
+    gameweek,
+    userID,
+    teamID,
+    manager,
+    RANK() OVER (PARTITION BY gameweek ORDER BY leaguePoints DESC) as position,
+    leaguePoints,
+    points,
+    totalPoints,
+    isSuspendedNext
+FROM standings
+ORDER BY gameweek, position, manager`).Bind(params)
+		return streamExport[types.ExportStanding](query, w, request.Format)
+
+	case "fines":
+		// cards cleared by a suspension were never fined, and approvals from
+		// before they were recorded can't be told apart from them
+		query := dao.DB().NewQuery(`
+SELECT
+    c.gameweek,
+    c.cardHash,
+    c.userID,
+    TRIM(COALESCE(u.firstName, '') || ' ' || COALESCE(u.lastName, '')) as manager,
+    c.type,
+    CASE WHEN c.adminVerified = TRUE THEN 'approved' ELSE 'awaiting_approval' END as status,
+    COALESCE(c.fineApprovedBy, '') as approvedByUserID,
+    TRIM(COALESCE(a.firstName, '') || ' ' || COALESCE(a.lastName, '')) as approvedBy,
+    COALESCE(c.fineApprovedAt, '') as approvedAt
+FROM {{cards}} c
+LEFT JOIN {{users}} u ON u.id = c.userID
+LEFT JOIN {{users}} a ON a.id = c.fineApprovedBy
+WHERE c.leagueID = {:leagueID} AND c.gameweek BETWEEN {:from} AND {:to}
+AND c.isCompleted = TRUE AND c.voided = FALSE
+AND (c.adminVerified = FALSE OR COALESCE(c.fineApprovedAt, '') != '')
+ORDER BY c.gameweek, c.created, c.cardHash`).Bind(params)
+		return streamExport[types.ExportFine](query, w, request.Format)
+
+	default:
+		query := dao.DB().NewQuery(`
+SELECT
+    c.gameweek,
+    c.type,
+    c.cardHash,
+    c.nominatorUserID as fromUserID,
+    TRIM(COALESCE(n.firstName, '') || ' ' || COALESCE(n.lastName, '')) as "from",
+    c.userID as toUserID,
+    TRIM(COALESCE(u.firstName, '') || ' ' || COALESCE(u.lastName, '')) as "to",
+    c.voided,
+    c.created
+FROM {{cards}} c
+LEFT JOIN {{users}} u ON u.id = c.userID
+LEFT JOIN {{users}} n ON n.id = c.nominatorUserID
+WHERE c.leagueID = {:leagueID} AND c.gameweek BETWEEN {:from} AND {:to}
+AND c.type IN ('nomination', 'reverse')
+ORDER BY c.gameweek, c.created, c.cardHash`).Bind(params)
+		return streamExport[types.ExportNomination](query, w, request.Format)
+	}
+}
+
+// streamExport writes each row query returns as a T, flushing w as it goes
+// when it's an HTTP response
+func streamExport[T any](query *dbx.Query, w io.Writer, format string) error {
+	rows, err := query.Rows()
+	if err != nil {
+		return fmt.Errorf("error reading export: %w", err)
+	}
+	defer rows.Close()
+
+	encoder, err := newExportEncoder(w, format, reflect.TypeFor[T]())
+	if err != nil {
+		return err
+	}
+	written := 0
+	for rows.Next() {
+		var row T
+		if err := rows.ScanStruct(&row); err != nil {
+			return fmt.Errorf("error reading export row: %w", err)
+		}
+		if err := encoder.write(reflect.ValueOf(row)); err != nil {
+			return fmt.Errorf("error writing export row: %w", err)
+		}
+		written++
+		if written%exportFlushRows == 0 {
+			if err := encoder.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading export: %w", err)
+	}
+	return encoder.close()
+}
+
+// exportEncoder writes export rows as CSV or a JSON array. Columns are named by
+// the rows' json tags.
+type exportEncoder struct {
+	w       io.Writer
+	csv     *csv.Writer
+	columns []int
+	rows    int
+}
+
+func newExportEncoder(w io.Writer, format string, rowType reflect.Type) (*exportEncoder, error) {
+	encoder := &exportEncoder{w: w}
+
+	var header []string
+	for i := range rowType.NumField() {
+		name, _, _ := strings.Cut(rowType.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		encoder.columns = append(encoder.columns, i)
+		header = append(header, name)
+	}
+
+	if format == ExportCSV {
+		encoder.csv = csv.NewWriter(w)
+		if err := encoder.csv.Write(header); err != nil {
+			return nil, fmt.Errorf("error writing export header: %w", err)
+		}
+		return encoder, nil
+	}
+	if _, err := io.WriteString(w, "["); err != nil {
+		return nil, fmt.Errorf("error writing export: %w", err)
+	}
+	return encoder, nil
+}
+
+func (encoder *exportEncoder) write(row reflect.Value) error {
+	encoder.rows++
+	if encoder.csv != nil {
+		record := make([]string, len(encoder.columns))
+		for i, field := range encoder.columns {
+			record[i] = fmt.Sprint(row.Field(field).Interface())
+		}
+		return encoder.csv.Write(record)
+	}
+
+	data, err := json.Marshal(row.Interface())
+	if err != nil {
+		return err
+	}
+	separator := ",\n"
+	if encoder.rows == 1 {
+		separator = "\n"
+	}
+	if _, err := io.WriteString(encoder.w, separator); err != nil {
+		return err
+	}
+	_, err = encoder.w.Write(data)
+	return err
+}
+
+func (encoder *exportEncoder) flush() error {
+	if encoder.csv != nil {
+		encoder.csv.Flush()
+		if err := encoder.csv.Error(); err != nil {
+			return fmt.Errorf("error writing export: %w", err)
+		}
+	}
+	if flusher, ok := encoder.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+func (encoder *exportEncoder) close() error {
+	if encoder.csv == nil {
+		closing := "]\n"
+		if encoder.rows > 0 {
+			closing = "\n]\n"
+		}
+		if _, err := io.WriteString(encoder.w, closing); err != nil {
+			return fmt.Errorf("error writing export: %w", err)
+		}
+	}
+	return encoder.flush()
+}
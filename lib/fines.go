@@ -0,0 +1,328 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	pbtypes "github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	FineLedgerCollection      = "fine_ledger"
+	FineSettlementsCollection = "fine_settlements"
+	DefaultFineCurrency       = "GBP"
+
+	FineCharge  = "charge"
+	FinePayment = "payment"
+	FineWaiver  = "waiver"
+)
+
+// FineCardTypes are the card types a league can put money on
+var FineCardTypes = []string{"own_goals", "penalties_missed", "red_cards", "nomination", "reverse"}
+
+var currencySymbols = map[string]string{
+	"GBP": "£",
+	"EUR": "€",
+	"USD": "$",
+	"AUD": "A$",
+	"CAD": "C$",
+	"NZD": "NZ$",
+}
+
+// FormatAmount writes an amount in minor units with two decimal places, e.g.
+// 250 as 2.50
+func FormatAmount(amount int) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
+
+// FormatMoney writes an amount in minor units in a currency, e.g. £2.50, or
+// 2.50 SEK for currencies without a symbol here
+func FormatMoney(amount int, currency string) string {
+	symbol, ok := currencySymbols[currency]
+	if !ok {
+		return FormatAmount(amount) + " " + currency
+	}
+	if amount < 0 {
+		return "-" + symbol + FormatAmount(-amount)
+	}
+	return symbol + FormatAmount(amount)
+}
+
+// ParseMoney reads an amount such as 2, 2.5 or 2.50 into minor units. Amounts
+// can't be negative.
+func ParseMoney(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	whole, fraction, _ := strings.Cut(value, ".")
+	if whole == "" {
+		whole = "0"
+	}
+	if len(fraction) > 2 || strings.ContainsAny(whole+fraction, "+-") {
+		return 0, fmt.Errorf("%q isn't an amount", value)
+	}
+	units, err := strconv.Atoi(whole)
+	if err != nil {
+		return 0, fmt.Errorf("%q isn't an amount", value)
+	}
+	cents := 0
+	if fraction != "" {
+		cents, err = strconv.Atoi(fraction)
+		if err != nil {
+			return 0, fmt.Errorf("%q isn't an amount", value)
+		}
+		if len(fraction) == 1 {
+			cents *= 10
+		}
+	}
+	return units*100 + cents, nil
+}
+
+// SyncFineLedgers brings the charges of every league with money fines in
+// step with its cards. The ETL runs it once a gameweek's cards are issued.
+func SyncFineLedgers(pb *pocketbase.PocketBase) error {
+	return pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		leagues, err := txDao.FindRecordsByExpr("league_settings", dbx.HashExp{"finesEnabled": true})
+		if err != nil {
+			return fmt.Errorf("error fetching league settings: %w", err)
+		}
+		for _, settings := range leagues {
+			if err := SyncFineCharges(txDao, settings); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SyncFineCharges charges the league's members for each card they hold that
+// was handed out since money fines were turned on, at the schedule's amount
+// for its type plus the repeat offence surcharge for every card they've
+// already been charged for this season. Charges for cards that have since
+// been voided, or reversed onto someone else, are cancelled. Card types
+// without an amount aren't charged.
+func SyncFineCharges(txDao *daos.Dao, settings *models.Record) error {
+	if !settings.GetBool("finesEnabled") {
+		return nil
+	}
+	leagueID := settings.GetInt("leagueID")
+	season := CurrentSeasonStartYear(txDao)
+	enabledAt := settings.GetDateTime("finesEnabledAt").String()
+
+	amounts := make(map[string]int)
+	if raw := settings.GetString("fineAmounts"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &amounts); err != nil {
+			return fmt.Errorf("error reading the fine amounts of league %d: %w", leagueID, err)
+		}
+	}
+	escalation := settings.GetInt("fineEscalation")
+
+	var cards []struct {
+		CardHash string `db:"cardHash"`
+		UserID   string `db:"userID"`
+		Type     string `db:"type"`
+		Gameweek int    `db:"gameweek"`
+		Voided   bool   `db:"voided"`
+		Created  string `db:"created"`
+	}
+	err := txDao.DB().
+		Select("cardHash", "userID", "type", "gameweek", "voided", "created").
+		From("cards").
+		Where(dbx.HashExp{"leagueID": leagueID}).
+		OrderBy("gameweek asc", "created asc", "cardHash asc").
+		All(&cards)
+	if err != nil {
+		return fmt.Errorf("error fetching the cards of league %d: %w", leagueID, err)
+	}
+	byHash := make(map[string]int, len(cards))
+	for i, card := range cards {
+		byHash[card.CardHash] = i
+	}
+
+	charges, err := txDao.FindRecordsByFilter(FineLedgerCollection,
+		"leagueID = {:leagueID} && seasonStartYear = {:season} && kind = {:kind} && cancelled = false", "", 0, 0,
+		dbx.Params{"leagueID": leagueID, "season": season, "kind": FineCharge})
+	if err != nil {
+		return fmt.Errorf("error fetching the charges of league %d: %w", leagueID, err)
+	}
+
+	charged := make(map[string]bool)
+	offences := make(map[string]int)
+	for _, charge := range charges {
+		i, ok := byHash[charge.GetString("cardHash")]
+		if !ok || cards[i].Voided || cards[i].UserID != charge.GetString("userID") || cards[i].Type != charge.GetString("cardType") {
+			charge.Set("cancelled", true)
+			if err := txDao.SaveRecord(charge); err != nil {
+				return fmt.Errorf("error cancelling charge %s: %w", charge.Id, err)
+			}
+			continue
+		}
+		charged[cards[i].CardHash] = true
+		offences[cards[i].UserID]++
+	}
+
+	collection, err := txDao.FindCollectionByNameOrId(FineLedgerCollection)
+	if err != nil {
+		return fmt.Errorf("error finding collection: %w", err)
+	}
+	added := 0
+	for _, card := range cards {
+		amount := amounts[card.Type]
+		if card.Voided || charged[card.CardHash] || card.Created < enabledAt || amount <= 0 {
+			continue
+		}
+
+		charge := models.NewRecord(collection)
+		charge.Set("leagueID", leagueID)
+		charge.Set("seasonStartYear", season)
+		charge.Set("userID", card.UserID)
+		charge.Set("kind", FineCharge)
+		charge.Set("amount", amount+escalation*offences[card.UserID])
+		charge.Set("cardHash", card.CardHash)
+		charge.Set("cardType", card.Type)
+		charge.Set("gameweek", card.Gameweek)
+		if err := txDao.SaveRecord(charge); err != nil {
+			return fmt.Errorf("error charging card %s: %w", card.CardHash, err)
+		}
+		offences[card.UserID]++
+		added++
+	}
+
+	if added > 0 {
+		log.Printf("[Fines] Charged %d cards in league %d", added, leagueID)
+	}
+	return nil
+}
+
+// GetFineBalances adds up each member's ledger for a season, most owed first,
+// along with the league's totals
+func GetFineBalances(dao *daos.Dao, leagueID int, season int) ([]types.FineBalance, types.FineBalance, error) {
+	var totals types.FineBalance
+
+	var rows []struct {
+		UserID    string `db:"userID"`
+		FirstName string `db:"firstName"`
+		LastName  string `db:"lastName"`
+		Cards     int    `db:"cards"`
+		Charged   int    `db:"charged"`
+		Paid      int    `db:"paid"`
+		Waived    int    `db:"waived"`
+	}
+	err := dao.DB().NewQuery(`
+SELECT
+    f.userID,
+    COALESCE(u.firstName, '') as firstName,
+    COALESCE(u.lastName, '') as lastName,
+    SUM(CASE WHEN f.kind = 'charge' AND f.cancelled = FALSE THEN 1 ELSE 0 END) as cards,
+    SUM(CASE WHEN f.kind = 'charge' AND f.cancelled = FALSE THEN f.amount ELSE 0 END) as charged,
+    SUM(CASE WHEN f.kind = 'payment' THEN f.amount ELSE 0 END) as paid,
+    SUM(CASE WHEN f.kind = 'waiver' THEN f.amount ELSE 0 END) as waived
+FROM {{fine_ledger}} f
+LEFT JOIN {{users}} u ON u.id = f.userID
+WHERE f.leagueID = {:leagueID} AND f.seasonStartYear = {:season}
+GROUP BY f.userID`).
+		Bind(dbx.Params{"leagueID": leagueID, "season": season}).
+		All(&rows)
+	if err != nil {
+		return nil, totals, fmt.Errorf("error fetching fine balances: %w", err)
+	}
+
+	balances := make([]types.FineBalance, 0, len(rows))
+	for _, row := range rows {
+		balance := types.FineBalance{
+			UserID:  row.UserID,
+			Name:    ManagerName(row.FirstName, row.LastName),
+			Cards:   row.Cards,
+			Charged: row.Charged,
+			Paid:    row.Paid,
+			Waived:  row.Waived,
+			Owed:    row.Charged - row.Paid - row.Waived,
+		}
+		balances = append(balances, balance)
+
+		totals.Cards += balance.Cards
+		totals.Charged += balance.Charged
+		totals.Paid += balance.Paid
+		totals.Waived += balance.Waived
+		totals.Owed += balance.Owed
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		if balances[i].Owed != balances[j].Owed {
+			return balances[i].Owed > balances[j].Owed
+		}
+		return balances[i].Name < balances[j].Name
+	})
+	return balances, totals, nil
+}
+
+// GetFineSettlements lists the pots paid out in a season, latest first
+func GetFineSettlements(dao *daos.Dao, leagueID int, season int) ([]types.FineSettlement, error) {
+	var rows []struct {
+		Amount    int    `db:"amount"`
+		Currency  string `db:"currency"`
+		Payments  int    `db:"payments"`
+		Note      string `db:"note"`
+		SettledBy string `db:"settledBy"`
+		Created   string `db:"created"`
+	}
+	err := dao.DB().
+		Select(
+			"s.amount",
+			"s.currency",
+			"s.payments",
+			"s.note",
+			"TRIM(COALESCE(u.firstName, '') || ' ' || COALESCE(u.lastName, '')) as settledBy",
+			"s.created").
+		From(FineSettlementsCollection+" s").
+		LeftJoin("users u", dbx.NewExp("u.id = s.settledBy")).
+		Where(dbx.HashExp{"s.leagueID": leagueID, "s.seasonStartYear": season}).
+		OrderBy("s.created desc").
+		All(&rows)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching settlements: %w", err)
+	}
+
+	settlements := make([]types.FineSettlement, 0, len(rows))
+	for _, row := range rows {
+		settlement := types.FineSettlement{
+			Amount:    row.Amount,
+			Currency:  row.Currency,
+			Payments:  row.Payments,
+			Note:      row.Note,
+			SettledBy: row.SettledBy,
+		}
+		if created, err := pbtypes.ParseDateTime(row.Created); err == nil {
+			settlement.Created = created.Time()
+		}
+		settlements = append(settlements, settlement)
+	}
+	return settlements, nil
+}
+
+// FinePot is the payments collected in a season that haven't been paid out
+func FinePot(dao *daos.Dao, leagueID int, season int) (int, error) {
+	var pot struct {
+		Amount int `db:"amount"`
+	}
+	err := dao.DB().
+		Select("COALESCE(SUM(amount), 0) as amount").
+		From(FineLedgerCollection).
+		Where(dbx.HashExp{"leagueID": leagueID, "seasonStartYear": season, "kind": FinePayment, "settlementID": ""}).
+		One(&pot)
+	if err != nil {
+		return 0, fmt.Errorf("error adding up the pot: %w", err)
+	}
+	return pot.Amount, nil
+}
@@ -7,19 +7,18 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"sync"
 	"time"
 
 	"github.com/cmcd97/bytesize/app/types"
 	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
-	"github.com/pocketbase/pocketbase/models"
+	pbtypes "github.com/pocketbase/pocketbase/tools/types"
 )
 
 const (
-	FPLEndpoint = "https://fantasy.premierleague.com/api/bootstrap-static/"
-	timeout     = 30 * time.Second
+	timeout = 30 * time.Second
 )
 
 func GetAllPlayers(e *core.ServeEvent, pb *pocketbase.PocketBase) error {
@@ -39,11 +38,33 @@ func GetAllPlayers(e *core.ServeEvent, pb *pocketbase.PocketBase) error {
 		return fmt.Errorf("failed to fetch FPL data: %w", err)
 	}
 
+	// The first season recorded is the one the stored data is from, which
+	// may be behind FPL if the server was down over the summer
+	fplYear, err := seasonStartYear(fplData)
+	if err != nil {
+		return err
+	}
+	dataYear := fplYear
+	if maxYear != nil {
+		dataYear = *maxYear
+	}
+	if err := recordSeason(pb, dataYear, fplYear); err != nil {
+		return fmt.Errorf("failed to record season: %w", err)
+	}
+
 	// Process players if needed
 	if err := processPlayers(pb, fplData, maxYear); err != nil {
 		return fmt.Errorf("failed to process players: %w", err)
 	}
 
+	// Teams are cheap to refresh, and installs from before they were stored
+	// pick them up this way
+	if fplYear == dataYear {
+		if err := UpsertRows(pb.Dao(), "teams", teamConflictColumns, teamUpdateColumns, teamRows(fplData.Teams, fplYear)); err != nil {
+			return fmt.Errorf("failed to save teams: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -64,7 +85,7 @@ func getDBState(pb *pocketbase.PocketBase) (*int, int, error) {
 
 func fetchFPLData(ctx context.Context) (*types.FPLResponse, error) {
 	log.Printf("fetching current players...")
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, FPLEndpoint, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, FPLAPIBase+"/bootstrap-static/", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -90,112 +111,125 @@ func fetchFPLData(ctx context.Context) (*types.FPLResponse, error) {
 
 func processPlayers(pb *pocketbase.PocketBase, fplData *types.FPLResponse, maxYear *int) error {
 	log.Printf("processing players...")
-	if len(fplData.Events) == 0 {
-		return fmt.Errorf("no events found in FPL data")
-	}
-
-	deadlineTime, err := time.Parse(time.RFC3339, fplData.Events[0].DeadlineTime)
+	currentYear, err := seasonStartYear(fplData)
 	if err != nil {
-		return fmt.Errorf("error parsing deadline time: %w", err)
+		return err
 	}
 
-	currentYear := deadlineTime.Year()
 	if maxYear != nil && *maxYear >= currentYear {
 		return nil // Already up to date
 	}
 
-	collection, err := pb.Dao().FindCollectionByNameOrId("players")
-	if err != nil {
-		return fmt.Errorf("error finding collection: %w", err)
-	}
-
-	const (
-		batchSize  = 100
-		numWorkers = 4
-	)
-
-	// Create channels
-	recordsChan := make(chan []*models.Record, len(fplData.Elements)/batchSize+1)
-	errorsChan := make(chan error, numWorkers)
-	var wg sync.WaitGroup
-
-	// Start worker pool
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for records := range recordsChan {
-				if err := saveRecords(pb, records); err != nil {
-					errorsChan <- err
-					return
-				}
-			}
-		}()
+	if err := UpsertRows(pb.Dao(), "players", playerConflictColumns, playerUpdateColumns, playerRows(fplData.Elements, currentYear)); err != nil {
+		return fmt.Errorf("error saving players: %w", err)
 	}
 
-	// Process players in batches
-	records := make([]*models.Record, 0, batchSize)
-	for _, player := range fplData.Elements {
-		record := models.NewRecord(collection)
-		record.Set("playerID", player.ID)
-		record.Set("playerTeamID", player.Team)
-		record.Set("playerName", player.WebName)
-		record.Set("seasonStartYear", currentYear)
-
-		records = append(records, record)
+	return nil
+}
 
-		if len(records) >= batchSize {
-			recordsChan <- records
-			records = make([]*models.Record, 0, batchSize)
-		}
+// seasonStartYear is the year the season in fplData kicks off, taken from
+// the first gameweek's deadline.
+func seasonStartYear(fplData *types.FPLResponse) (int, error) {
+	if len(fplData.Events) == 0 {
+		return 0, fmt.Errorf("no events found in FPL data")
 	}
 
-	// Send remaining records
-	if len(records) > 0 {
-		recordsChan <- records
+	deadlineTime, err := time.Parse(time.RFC3339, fplData.Events[0].DeadlineTime)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing deadline time: %w", err)
 	}
 
-	// Close channel and wait for workers
-	close(recordsChan)
-	wg.Wait()
-	close(errorsChan)
+	return deadlineTime.Year(), nil
+}
 
-	// Check for errors
-	for err := range errorsChan {
-		if err != nil {
-			return fmt.Errorf("error in worker: %w", err)
-		}
-	}
+var (
+	playerConflictColumns  = []string{"playerID", "seasonStartYear"}
+	playerUpdateColumns    = []string{"playerTeamID", "playerName"}
+	teamConflictColumns    = []string{"teamID", "seasonStartYear"}
+	teamUpdateColumns      = []string{"name", "shortName"}
+	fixtureConflictColumns = []string{"fixtureID"}
+	fixtureUpdateColumns   = []string{"gameweek", "kickoff", "homeTeamID", "awayTeamID"}
+	eventConflictColumns   = []string{"eventHash"}
+	eventUpdateColumns     = []string{"gameweek", "eventValue"}
+)
 
-	return nil
+func playerRows(players []types.Player, seasonStartYear int) []dbx.Params {
+	rows := make([]dbx.Params, 0, len(players))
+	for _, player := range players {
+		rows = append(rows, dbx.Params{
+			"playerID":        player.ID,
+			"playerTeamID":    player.Team,
+			"playerName":      player.WebName,
+			"seasonStartYear": seasonStartYear,
+		})
+	}
+	return rows
 }
 
-func saveRecords(pb *pocketbase.PocketBase, records []*models.Record) error {
-	log.Printf("saving players updates...")
-	var wg sync.WaitGroup
-	errorsChan := make(chan error, len(records))
-
-	for _, record := range records {
-		wg.Add(1)
-		go func(r *models.Record) {
-			defer wg.Done()
-			if err := pb.Dao().SaveRecord(r); err != nil {
-				errorsChan <- fmt.Errorf("error saving record: %w", err)
-			}
-		}(record)
-	}
-
-	wg.Wait()
-	close(errorsChan)
+func teamRows(teams []types.FPLTeam, seasonStartYear int) []dbx.Params {
+	rows := make([]dbx.Params, 0, len(teams))
+	for _, team := range teams {
+		rows = append(rows, dbx.Params{
+			"teamID":          team.ID,
+			"name":            team.Name,
+			"shortName":       team.ShortName,
+			"seasonStartYear": seasonStartYear,
+		})
+	}
+	return rows
+}
 
-	// Return first error if any
-	for err := range errorsChan {
+// fixtureRows stores kickoffs in PocketBase's date format, as UpsertRows
+// writes values as they are and the kickoffs are compared as strings.
+// Postponed fixtures have no kickoff.
+func fixtureRows(fixtures []types.Fixtures) []dbx.Params {
+	rows := make([]dbx.Params, 0, len(fixtures))
+	for _, fixture := range fixtures {
+		kickoff, err := pbtypes.ParseDateTime(fixture.Kickoff)
 		if err != nil {
-			return err
+			log.Printf("Fixture %d has an unreadable kickoff %q: %v", fixture.FixtureID, fixture.Kickoff, err)
 		}
-	}
+		rows = append(rows, dbx.Params{
+			"fixtureID":  fixture.FixtureID,
+			"gameweek":   fixture.Gameweek,
+			"kickoff":    kickoff.String(),
+			"homeTeamID": fixture.HomeTeamID,
+			"awayTeamID": fixture.AwayTeamID,
+		})
+	}
+	return rows
+}
 
-	return nil
+// eventRows flattens the stats of every finished fixture into events rows,
+// one per player, stat and side.
+func eventRows(fixtures []types.FixtureStats) []dbx.Params {
+	var rows []dbx.Params
+	for _, fixture := range fixtures {
+		if !fixture.Finished {
+			continue
+		}
+
+		for _, stat := range fixture.Stats {
+			sides := []struct {
+				name   string
+				values []types.StatValue
+			}{{"h", stat.Home}, {"a", stat.Away}}
+			for _, side := range sides {
+				for _, value := range side.values {
+					rows = append(rows, dbx.Params{
+						"eventHash":  CreateEventHash(fixture.FixtureID, value.Element, string(stat.Identifier), side.name),
+						"fixtureID":  fixture.FixtureID,
+						"gameweek":   fixture.Gameweek,
+						"playerID":   value.Element,
+						"eventType":  string(stat.Identifier),
+						"side":       side.name,
+						"eventValue": value.Value,
+					})
+				}
+			}
+		}
+	}
+	return rows
 }
 
 func GetAllFixtureEvents(e *core.ServeEvent, pb *pocketbase.PocketBase) error {
@@ -219,7 +253,7 @@ func GetAllFixtureEvents(e *core.ServeEvent, pb *pocketbase.PocketBase) error {
 	}
 
 	// get all events where finished is true
-	endpoint := "https://fantasy.premierleague.com/api/fixtures/"
+	endpoint := FPLAPIBase + "/fixtures/"
 
 	resp, err := http.Get(endpoint)
 	if err != nil {
@@ -238,52 +272,8 @@ func GetAllFixtureEvents(e *core.ServeEvent, pb *pocketbase.PocketBase) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to parse team data: %v", err))
 	}
 
-	collection, err := pb.Dao().FindCollectionByNameOrId("events")
-	if err != nil {
-		return fmt.Errorf("error finding collection: %w", err)
-	}
-
-	// Process fixtures
-
-	for _, fixture := range Fixtures {
-		if !fixture.Finished {
-			continue
-		}
-
-		for _, stat := range fixture.Stats {
-
-			// Process home team stats
-			for _, home := range stat.Home {
-				eventHash := CreateEventHash(fixture.FixtureID, fixture.Gameweek, string(stat.Identifier))
-				record := models.NewRecord(collection)
-				record.Set("eventHash", eventHash)
-				record.Set("fixtureID", fixture.FixtureID)
-				record.Set("gameweek", fixture.Gameweek)
-				record.Set("playerID", home.Element)
-				record.Set("eventType", string(stat.Identifier))
-				record.Set("eventValue", home.Value)
-
-				if err := pb.Dao().SaveRecord(record); err != nil {
-					return fmt.Errorf("error saving home record: %w", err)
-				}
-			}
-
-			// Process away team stats
-			for _, away := range stat.Away {
-				eventHash := CreateEventHash(fixture.FixtureID, fixture.Gameweek, string(stat.Identifier))
-				record := models.NewRecord(collection)
-				record.Set("eventHash", eventHash)
-				record.Set("fixtureID", fixture.FixtureID)
-				record.Set("gameweek", fixture.Gameweek)
-				record.Set("playerID", away.Element)
-				record.Set("eventType", string(stat.Identifier))
-				record.Set("eventValue", away.Value)
-
-				if err := pb.Dao().SaveRecord(record); err != nil {
-					return fmt.Errorf("error saving away record: %w", err)
-				}
-			}
-		}
+	if _, err := syncFixtureEvents(pb, Fixtures); err != nil {
+		return fmt.Errorf("error saving events: %w", err)
 	}
 
 	return nil
@@ -310,7 +300,7 @@ func GetAllFixtures(e *core.ServeEvent, pb *pocketbase.PocketBase) error {
 	}
 
 	// get all events where finished is true
-	endpoint := "https://fantasy.premierleague.com/api/fixtures/"
+	endpoint := FPLAPIBase + "/fixtures/"
 
 	resp, err := http.Get(endpoint)
 	if err != nil {
@@ -329,22 +319,8 @@ func GetAllFixtures(e *core.ServeEvent, pb *pocketbase.PocketBase) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to parse team data: %v", err))
 	}
 
-	collection, err := pb.Dao().FindCollectionByNameOrId("fixtures")
-	if err != nil {
-		return fmt.Errorf("error finding collection: %w", err)
-	}
-
-	for _, fixtures := range Fixtures {
-		record := models.NewRecord(collection)
-		record.Set("fixtureID", fixtures.FixtureID)
-		record.Set("gameweek", fixtures.Gameweek)
-		record.Set("kickoff", fixtures.Kickoff)
-		record.Set("homeTeamID", fixtures.HomeTeamID)
-		record.Set("awayTeamID", fixtures.AwayTeamID)
-
-		if err := pb.Dao().SaveRecord(record); err != nil {
-			return fmt.Errorf("error saving record: %w", err)
-		}
+	if err := UpsertRows(pb.Dao(), "fixtures", fixtureConflictColumns, fixtureUpdateColumns, fixtureRows(Fixtures)); err != nil {
+		return fmt.Errorf("error saving fixtures: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,250 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	pbtypes "github.com/pocketbase/pocketbase/tools/types"
+)
+
+// FPLAPIBase is where every request to FPL goes. It's a variable so a fake
+// FPL server can stand in for the real one.
+var FPLAPIBase = "https://fantasy.premierleague.com/api"
+
+const (
+	LeagueMembersCollection = "league_members"
+	leagueSyncTimeout       = 60 * time.Second
+	leagueSyncPageDelay     = 100 * time.Millisecond
+)
+
+// SyncLeagueMembers refreshes the membership of every linked league from the
+// FPL standings. A league counts as linked once someone has become its admin.
+func SyncLeagueMembers(pb *pocketbase.PocketBase) error {
+	log.Println("[LeagueSync] Starting league membership sync")
+
+	var leagueIDs []int
+	err := pb.Dao().DB().
+		NewQuery("SELECT DISTINCT leagueID FROM leagues WHERE adminUserID != 'temp' AND adminUserID != ''").
+		Column(&leagueIDs)
+	if err != nil {
+		log.Printf("[LeagueSync] Error fetching linked leagues: %v", err)
+		return fmt.Errorf("error fetching linked leagues: %w", err)
+	}
+
+	errorCount := 0
+	for _, leagueID := range leagueIDs {
+		if err := SyncLeague(pb, leagueID); err != nil {
+			errorCount++
+			log.Printf("[LeagueSync] Error syncing league %d: %v", leagueID, err)
+		}
+	}
+
+	if errorCount > 0 {
+		return fmt.Errorf("completed with %d errors", errorCount)
+	}
+
+	log.Printf("[LeagueSync] Synced %d leagues", len(leagueIDs))
+	return nil
+}
+
+// SyncLeague pulls every standings page of one classic league and reconciles
+// it with the league_members and leagues collections.
+func SyncLeague(pb *pocketbase.PocketBase, leagueID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), leagueSyncTimeout)
+	defer cancel()
+
+	league, entries, err := fetchLeagueStandings(ctx, leagueID)
+	if err != nil {
+		return err
+	}
+
+	// An empty league almost certainly means FPL returned something unexpected,
+	// and treating it as real would mark every member as having left.
+	if len(entries) == 0 {
+		return fmt.Errorf("no standings returned for league %d", leagueID)
+	}
+
+	return pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		return applyLeagueSync(txDao, leagueID, league, entries)
+	})
+}
+
+func fetchLeagueStandings(ctx context.Context, leagueID int) (types.FPLLeagueInfo, []types.FPLStandingEntry, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	var league types.FPLLeagueInfo
+	var entries []types.FPLStandingEntry
+
+	for page := 1; ; page++ {
+		endpoint := fmt.Sprintf("%s/leagues-classic/%d/standings/?page_standings=%d", FPLAPIBase, leagueID, page)
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return league, nil, fmt.Errorf("creating request: %w", err)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return league, nil, fmt.Errorf("fetching standings page %d: %w", page, err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return league, nil, fmt.Errorf("reading standings page %d: %w", page, err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return league, nil, fmt.Errorf("unexpected status code %d for standings page %d", resp.StatusCode, page)
+		}
+
+		var standings types.FPLLeagueStandingsResponse
+		if err := json.Unmarshal(body, &standings); err != nil {
+			return league, nil, fmt.Errorf("parsing standings page %d: %w", page, err)
+		}
+
+		league = standings.League
+		entries = append(entries, standings.Standings.Results...)
+
+		if !standings.Standings.HasNext {
+			break
+		}
+
+		// Add small delay to avoid rate limiting
+		time.Sleep(leagueSyncPageDelay)
+	}
+
+	return league, entries, nil
+}
+
+func applyLeagueSync(txDao *daos.Dao, leagueID int, league types.FPLLeagueInfo, entries []types.FPLStandingEntry) error {
+	now := pbtypes.NowDateTime()
+
+	membersCollection, err := txDao.FindCollectionByNameOrId(LeagueMembersCollection)
+	if err != nil {
+		return fmt.Errorf("error finding collection: %w", err)
+	}
+	leaguesCollection, err := txDao.FindCollectionByNameOrId("leagues")
+	if err != nil {
+		return fmt.Errorf("error finding collection: %w", err)
+	}
+
+	existingMembers, err := txDao.FindRecordsByExpr(LeagueMembersCollection,
+		dbx.HashExp{"leagueID": leagueID})
+	if err != nil {
+		return fmt.Errorf("error fetching league members: %w", err)
+	}
+	membersByEntry := make(map[int]*models.Record, len(existingMembers))
+	for _, member := range existingMembers {
+		membersByEntry[member.GetInt("entryID")] = member
+	}
+
+	leagueRows, err := txDao.FindRecordsByExpr("leagues", dbx.HashExp{"leagueID": leagueID})
+	if err != nil {
+		return fmt.Errorf("error fetching league rows: %w", err)
+	}
+	rowsByTeam := make(map[int]*models.Record, len(leagueRows))
+	adminUserID, leagueName := "temp", league.Name
+	for _, row := range leagueRows {
+		rowsByTeam[row.GetInt("teamID")] = row
+		if admin := row.GetString("adminUserID"); admin != "temp" && admin != "" {
+			adminUserID = admin
+			leagueName = row.GetString("leagueName")
+		}
+	}
+
+	teamIDs := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		teamIDs = append(teamIDs, entry.Entry)
+	}
+	users, err := txDao.FindRecordsByExpr("users", dbx.In("teamID", teamIDs...))
+	if err != nil {
+		return fmt.Errorf("error fetching users: %w", err)
+	}
+	usersByTeam := make(map[int]string, len(users))
+	for _, user := range users {
+		usersByTeam[user.GetInt("teamID")] = user.Id
+	}
+
+	seasonStartYear := CurrentSeasonStartYear(txDao)
+
+	inStandings := make(map[int]bool, len(entries))
+	for _, entry := range entries {
+		inStandings[entry.Entry] = true
+		userID := usersByTeam[entry.Entry]
+
+		member, ok := membersByEntry[entry.Entry]
+		if !ok {
+			member = models.NewRecord(membersCollection)
+			member.Set("leagueID", leagueID)
+			member.Set("entryID", entry.Entry)
+		}
+		member.Set("entryName", entry.EntryName)
+		member.Set("playerName", entry.PlayerName)
+		member.Set("userID", userID)
+		member.Set("hasLeft", false)
+		member.Set("lastSyncedAt", now)
+		if err := txDao.SaveRecord(member); err != nil {
+			return fmt.Errorf("error saving league member %d: %w", entry.Entry, err)
+		}
+
+		// Unregistered entries stay unclaimed until someone signs up with them
+		if userID == "" {
+			continue
+		}
+
+		row, ok := rowsByTeam[entry.Entry]
+		if !ok {
+			row = models.NewRecord(leaguesCollection)
+			row.Set("leagueID", leagueID)
+			row.Set("adminUserID", adminUserID)
+			row.Set("teamID", entry.Entry)
+			row.Set("leagueName", leagueName)
+			row.Set("seasonStartYear", seasonStartYear)
+			row.Set("userID", userID)
+			row.Set("isLinked", false)
+			row.Set("isActive", false)
+			log.Printf("[LeagueSync] Adding user %s to league %d", userID, leagueID)
+		} else if !row.GetBool("hasLeft") {
+			continue
+		}
+		row.Set("hasLeft", false)
+		if err := txDao.SaveRecord(row); err != nil {
+			return fmt.Errorf("error saving league row for team %d: %w", entry.Entry, err)
+		}
+	}
+
+	for entryID, member := range membersByEntry {
+		if inStandings[entryID] || member.GetBool("hasLeft") {
+			continue
+		}
+		member.Set("hasLeft", true)
+		member.Set("lastSyncedAt", now)
+		if err := txDao.SaveRecord(member); err != nil {
+			return fmt.Errorf("error saving league member %d: %w", entryID, err)
+		}
+		log.Printf("[LeagueSync] Entry %d has left league %d", entryID, leagueID)
+	}
+
+	for teamID, row := range rowsByTeam {
+		if inStandings[teamID] || row.GetBool("hasLeft") {
+			continue
+		}
+		row.Set("hasLeft", true)
+		if err := txDao.SaveRecord(row); err != nil {
+			return fmt.Errorf("error saving league row for team %d: %w", teamID, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,298 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/daos"
+	pbtypes "github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	ProvisionalCardsCollection = "provisional_cards"
+	liveCardsTimeout           = 2 * time.Minute
+)
+
+var (
+	provisionalCardConflictColumns = []string{"cardHash"}
+	provisionalCardUpdateColumns   = []string{"eventHash", "playerID", "fixtureID", "fixtureFinished"}
+)
+
+// livePicks keeps each team's picks for the live gameweek, which can't change
+// once its first fixture has kicked off
+var livePicks = struct {
+	sync.Mutex
+	gameweek int
+	results  map[int]types.DatabaseResults
+}{}
+
+// LiveGameweek is the latest gameweek with a fixture that has kicked off, or
+// 0 before the season starts
+func LiveGameweek(dao *daos.Dao) int {
+	var gameweek struct {
+		Gameweek int `db:"gameweek"`
+	}
+	err := dao.DB().
+		Select("COALESCE(MAX(gameweek), 0) as gameweek").
+		From("fixtures").
+		Where(dbx.NewExp("kickoff <= {:now}", dbx.Params{"now": pbtypes.NowDateTime().String()})).
+		One(&gameweek)
+	if err != nil {
+		return 0
+	}
+	return gameweek.Gameweek
+}
+
+// PollLiveCards turns the live match stats of the gameweek being played into
+// provisional cards, for every manager whose real cards for it haven't been
+// issued yet. Each manager's picks and their leagues' card rules are applied
+// the same way updateCards applies them, except that FPL's automatic
+// substitutions aren't known until the gameweek is over.
+func PollLiveCards(pb *pocketbase.PocketBase) error {
+	gameweek := LiveGameweek(pb.Dao())
+	if gameweek == 0 {
+		return nil
+	}
+
+	var managers []types.DatabaseUsers
+	err := pb.Dao().DB().NewQuery(`
+SELECT DISTINCT u.id, u.teamID
+FROM {{leagues}} l
+JOIN {{users}} u ON u.id = l.userID
+WHERE l.isLinked = TRUE AND l.hasLeft = FALSE AND u.teamID != 0
+AND NOT EXISTS (SELECT 1 FROM {{results}} r WHERE r.userID = u.id AND r.gameweek = {:gameweek})`).
+		Bind(dbx.Params{"gameweek": gameweek}).
+		All(&managers)
+	if err != nil {
+		log.Printf("[LiveCards] Error fetching managers: %v", err)
+		return fmt.Errorf("error fetching managers: %w", err)
+	}
+	if len(managers) == 0 {
+		return clearConfirmedProvisionalCards(pb.Dao())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), liveCardsTimeout)
+	defer cancel()
+
+	eventsMap, finished, err := fetchLiveEvents(ctx, pb.Dao(), gameweek)
+	if err != nil {
+		log.Printf("[LiveCards] Error fetching live stats for gameweek %d: %v", gameweek, err)
+		return err
+	}
+	leagueMap, err := fetchUserLeagues(pb)
+	if err != nil {
+		return err
+	}
+	leagueRules, err := fetchLeagueCardRules(pb)
+	if err != nil {
+		return err
+	}
+
+	var rows []dbx.Params
+	var cardHashes []any
+	var skipped []any
+	for _, manager := range managers {
+		result, err := cachedLivePicks(ctx, manager.TeamID, manager.UserID, gameweek)
+		if err != nil {
+			// their provisional cards are kept until the picks can be read again
+			log.Printf("[LiveCards] Error fetching picks of team %d: %v", manager.TeamID, err)
+			skipped = append(skipped, manager.UserID)
+			continue
+		}
+
+		triggers, _ := resultTriggers(result, eventsMap)
+		for _, leagueID := range leagueMap[manager.UserID] {
+			rules := leagueRules[leagueID]
+			if !rules.handsOutCards(gameweek) {
+				continue
+			}
+			for eventType, eventTriggers := range triggers {
+				for cardIndex, event := range rules.cardEvents(eventTriggers, result.ActiveChip) {
+					cardHash := deriveCardHash(manager.UserID, leagueID, gameweek, eventType, cardIndex)
+					cardHashes = append(cardHashes, cardHash)
+					rows = append(rows, dbx.Params{
+						"leagueID":        leagueID,
+						"userID":          manager.UserID,
+						"teamID":          manager.TeamID,
+						"gameweek":        gameweek,
+						"type":            eventType,
+						"cardHash":        cardHash,
+						"eventHash":       event.EventHash,
+						"playerID":        event.PlayerID,
+						"fixtureID":       event.FixtureID,
+						"fixtureFinished": finished[event.FixtureID],
+					})
+				}
+			}
+		}
+	}
+
+	err = pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		if err := UpsertRows(txDao, ProvisionalCardsCollection, provisionalCardConflictColumns, provisionalCardUpdateColumns, rows); err != nil {
+			return fmt.Errorf("error saving provisional cards: %w", err)
+		}
+		// cards whose incident FPL has since taken back
+		_, err := txDao.DB().Delete(ProvisionalCardsCollection, dbx.And(
+			dbx.HashExp{"gameweek": gameweek},
+			dbx.NotIn("cardHash", cardHashes...),
+			dbx.NotIn("userID", skipped...),
+		)).Execute()
+		if err != nil {
+			return fmt.Errorf("error removing retracted provisional cards: %w", err)
+		}
+		return clearConfirmedProvisionalCards(txDao)
+	})
+	if err != nil {
+		log.Printf("[LiveCards] Transaction failed: %v", err)
+		return err
+	}
+
+	log.Printf("[LiveCards] %d provisional cards for %d managers in gameweek %d", len(rows), len(managers), gameweek)
+	return nil
+}
+
+// clearConfirmedProvisionalCards removes the provisional cards of every
+// manager whose results, and so real cards, are in for the gameweek
+func clearConfirmedProvisionalCards(dao *daos.Dao) error {
+	_, err := dao.DB().NewQuery(`
+DELETE FROM {{provisional_cards}}
+WHERE EXISTS (
+    SELECT 1 FROM {{results}} r
+    WHERE r.userID = provisional_cards.userID AND r.gameweek = provisional_cards.gameweek
+)`).Execute()
+	if err != nil {
+		return fmt.Errorf("error clearing confirmed provisional cards: %w", err)
+	}
+	return nil
+}
+
+// cachedLivePicks returns teamID's picks for the live gameweek as a results
+// row, asking FPL only the first time
+func cachedLivePicks(ctx context.Context, teamID int, userID string, gameweek int) (types.DatabaseResults, error) {
+	livePicks.Lock()
+	if livePicks.gameweek != gameweek {
+		livePicks.gameweek = gameweek
+		livePicks.results = make(map[int]types.DatabaseResults)
+	}
+	result, ok := livePicks.results[teamID]
+	livePicks.Unlock()
+	if ok {
+		return result, nil
+	}
+
+	picks, err := fetchGameweekPicks(ctx, teamID, gameweek)
+	if err != nil {
+		return result, err
+	}
+	result = flattenAPIResults(picks, teamID, userID)
+	result.Gameweek = gameweek
+
+	livePicks.Lock()
+	if livePicks.gameweek == gameweek {
+		livePicks.results[teamID] = result
+	}
+	livePicks.Unlock()
+	return result, nil
+}
+
+// fetchLiveEvents reads the incidents so far in gameweek's fixtures that have
+// kicked off as events keyed by player, and which fixtures have finished.
+// Events carry the same hash the imported ones will. The fixture stats give
+// each incident's side; an incident /event/{id}/live/ already has that they
+// don't yet gets its side from the player's team.
+func fetchLiveEvents(ctx context.Context, dao *daos.Dao, gameweek int) (map[int][]types.DatabaseEvent, map[int]bool, error) {
+	var fixtures []types.FixtureStats
+	if err := fetchFPLJSON(ctx, fmt.Sprintf("%s/fixtures/?event=%d", FPLAPIBase, gameweek), &fixtures); err != nil {
+		return nil, nil, err
+	}
+	var live types.FPLLive
+	if err := fetchFPLJSON(ctx, fmt.Sprintf("%s/event/%d/live/", FPLAPIBase, gameweek), &live); err != nil {
+		return nil, nil, err
+	}
+
+	type incident struct {
+		fixtureID int
+		playerID  int
+		eventType string
+	}
+	eventsMap := make(map[int][]types.DatabaseEvent)
+	seen := make(map[incident]bool)
+	started := make(map[int]bool)
+	finished := make(map[int]bool)
+	addEvent := func(fixtureID, playerID int, eventType, side string, value int) {
+		seen[incident{fixtureID, playerID, eventType}] = true
+		eventsMap[playerID] = append(eventsMap[playerID], types.DatabaseEvent{
+			EventHash:  CreateEventHash(fixtureID, playerID, eventType, side),
+			FixtureID:  fixtureID,
+			Gameweek:   gameweek,
+			PlayerID:   playerID,
+			EventType:  eventType,
+			EventValue: value,
+		})
+	}
+
+	for _, fixture := range fixtures {
+		if !fixture.Started {
+			continue
+		}
+		started[fixture.FixtureID] = true
+		finished[fixture.FixtureID] = fixture.Finished
+		for _, stat := range fixture.Stats {
+			for _, value := range stat.Home {
+				addEvent(fixture.FixtureID, value.Element, string(stat.Identifier), "h", value.Value)
+			}
+			for _, value := range stat.Away {
+				addEvent(fixture.FixtureID, value.Element, string(stat.Identifier), "a", value.Value)
+			}
+		}
+	}
+
+	var fixturePlayers []struct {
+		FixtureID  int `db:"fixtureID"`
+		HomeTeamID int `db:"homeTeamID"`
+		PlayerID   int `db:"playerID"`
+		TeamID     int `db:"playerTeamID"`
+	}
+	err := dao.DB().NewQuery(`
+SELECT f.fixtureID, f.homeTeamID, p.playerID, p.playerTeamID
+FROM {{fixtures}} f
+JOIN {{players}} p ON p.playerTeamID IN (f.homeTeamID, f.awayTeamID) AND p.seasonStartYear = {:season}
+WHERE f.gameweek = {:gameweek}`).
+		Bind(dbx.Params{"gameweek": gameweek, "season": CurrentSeasonStartYear(dao)}).
+		All(&fixturePlayers)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error fetching fixture teams: %w", err)
+	}
+	sides := make(map[[2]int]string)
+	for _, row := range fixturePlayers {
+		side := "a"
+		if row.TeamID == row.HomeTeamID {
+			side = "h"
+		}
+		sides[[2]int{row.FixtureID, row.PlayerID}] = side
+	}
+
+	for _, element := range live.Elements {
+		for _, fixture := range element.Explain {
+			if !started[fixture.FixtureID] {
+				continue
+			}
+			for _, stat := range fixture.Stats {
+				key := incident{fixture.FixtureID, element.PlayerID, string(stat.Identifier)}
+				side, ok := sides[[2]int{fixture.FixtureID, element.PlayerID}]
+				if !eventCardTypes[stat.Identifier] || stat.Value == 0 || seen[key] || !ok {
+					continue
+				}
+				addEvent(fixture.FixtureID, element.PlayerID, string(stat.Identifier), side, stat.Value)
+			}
+		}
+	}
+
+	return eventsMap, finished, nil
+}
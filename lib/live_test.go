@@ -0,0 +1,221 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+)
+
+const liveTestGameweek = seedGameweeks + 1
+
+// fakeLiveFPL serves the fixtures, live stats and picks of the demo league's
+// next gameweek, which is under way
+type fakeLiveFPL struct {
+	mu       sync.Mutex
+	fixtures []types.FixtureStats
+	live     types.FPLLive
+}
+
+func (f *fakeLiveFPL) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/fixtures/":
+		json.NewEncoder(w).Encode(f.fixtures)
+	case fmt.Sprintf("/event/%d/live/", liveTestGameweek):
+		json.NewEncoder(w).Encode(f.live)
+	default:
+		for i, manager := range seedManagers {
+			if r.URL.Path != fmt.Sprintf("/entry/%d/event/%d/picks/", manager.teamID, liveTestGameweek) {
+				continue
+			}
+			picks := types.GameweekHistory{GameweekHistrory: types.GameweekResults{Gameweek: liveTestGameweek}}
+			for position, playerID := range seedPicks(i) {
+				multiplier := 0
+				if position < 11 {
+					multiplier = 1
+				}
+				if position == 0 {
+					multiplier = 2
+				}
+				picks.Players = append(picks.Players, types.GameweekSelection{
+					PlayerID:      playerID,
+					Position:      position + 1,
+					Multiplier:    multiplier,
+					IsCaptain:     position == 0,
+					IsViceCaptain: position == 1,
+				})
+			}
+			json.NewEncoder(w).Encode(picks)
+			return
+		}
+		http.NotFound(w, r)
+	}
+}
+
+// newLiveGameweek seeds the demo league with a fifth gameweek that kicked off
+// an hour ago, and points lib.FPLAPIBase at a fake FPL serving it
+func newLiveGameweek(t *testing.T) (*pocketbase.PocketBase, *fakeLiveFPL) {
+	t.Helper()
+
+	pb := newTestApp(t)
+	if err := SeedDemoLeague(pb); err != nil {
+		t.Fatal(err)
+	}
+	kickoff := time.Now().UTC().Add(-time.Hour).Format("2006-01-02 15:04:05.000Z")
+	fixture := []dbx.Params{{
+		"fixtureID":  liveTestGameweek,
+		"gameweek":   liveTestGameweek,
+		"kickoff":    kickoff,
+		"homeTeamID": 1,
+		"awayTeamID": 2,
+	}}
+	if err := UpsertRows(pb.Dao(), "fixtures", fixtureConflictColumns, fixtureUpdateColumns, fixture); err != nil {
+		t.Fatal(err)
+	}
+	_, err := pb.DB().Update("league_settings", dbx.Params{"captainCardsDoubled": true}, dbx.HashExp{"leagueID": seedLeagueID}).Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Dana's captain, player 1, scores an own goal at home. Player 20 is sent
+	// off for the away side, which only the live stats know about so far;
+	// he's on Alex's bench and in Sam's and Chris's XIs.
+	fake := &fakeLiveFPL{
+		fixtures: []types.FixtureStats{{
+			Gameweek:  liveTestGameweek,
+			FixtureID: liveTestGameweek,
+			Started:   true,
+			Stats: []types.StatCategory{{
+				Identifier: types.OwnGoals,
+				Home:       []types.StatValue{{Value: 1, Element: 1}},
+			}},
+		}},
+		live: types.FPLLive{Elements: []types.FPLLiveElement{{
+			PlayerID: 20,
+			Explain: []types.FPLLiveFixture{{
+				FixtureID: liveTestGameweek,
+				Stats:     []types.FPLLiveStat{{Identifier: types.RedCards, Value: 1}},
+			}},
+		}}},
+	}
+	server := httptest.NewServer(fake)
+	original := FPLAPIBase
+	FPLAPIBase = server.URL
+	t.Cleanup(func() {
+		FPLAPIBase = original
+		server.Close()
+	})
+	return pb, fake
+}
+
+type provisionalCard struct {
+	Username  string `db:"username"`
+	Type      string `db:"type"`
+	EventHash string `db:"eventHash"`
+}
+
+func provisionalCards(t *testing.T, pb *pocketbase.PocketBase) []provisionalCard {
+	t.Helper()
+
+	var cards []provisionalCard
+	err := pb.DB().NewQuery(`
+SELECT u.username, p.type, p.eventHash
+FROM {{provisional_cards}} p
+JOIN {{users}} u ON u.id = p.userID
+ORDER BY u.username, p.type, p.cardHash`).All(&cards)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cards
+}
+
+// confirmResults stores usernames' results for the live gameweek, as the
+// ETL does once FPL has finalised it
+func confirmResults(t *testing.T, pb *pocketbase.PocketBase, usernames ...string) {
+	t.Helper()
+
+	for i, manager := range seedManagers {
+		if !slices.Contains(usernames, manager.username) {
+			continue
+		}
+		user, err := pb.Dao().FindAuthRecordByUsername("users", manager.username)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := types.DatabaseResults{
+			Gameweek:      liveTestGameweek,
+			UserID:        user.Id,
+			TeamID:        seedManagers[i].teamID,
+			Multipliers:   encodePicksJSON([]int{}),
+			AutomaticSubs: encodePicksJSON([]types.AutomaticSub{}),
+		}
+		if err := UpsertRows(pb.Dao(), "results", resultConflictColumns, resultUpdateColumns, []dbx.Params{resultRow(result)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPollLiveCards(t *testing.T) {
+	pb, fake := newLiveGameweek(t)
+
+	if err := PollLiveCards(pb); err != nil {
+		t.Fatal(err)
+	}
+	ownGoal := CreateEventHash(liveTestGameweek, 1, string(types.OwnGoals), "h")
+	redCard := CreateEventHash(liveTestGameweek, 20, string(types.RedCards), "a")
+	want := []provisionalCard{
+		{"chris", "red_cards", redCard},
+		// the league doubles cards for the captain
+		{"demo", "own_goals", ownGoal},
+		{"demo", "own_goals", ownGoal},
+		{"sam", "red_cards", redCard},
+	}
+	if got := provisionalCards(t, pb); !slices.Equal(got, want) {
+		t.Fatalf("provisional cards = %+v, want %+v", got, want)
+	}
+
+	// FPL takes the own goal back
+	fake.mu.Lock()
+	fake.fixtures[0].Stats = nil
+	fake.mu.Unlock()
+	if err := PollLiveCards(pb); err != nil {
+		t.Fatal(err)
+	}
+	want = []provisionalCard{
+		{"chris", "red_cards", redCard},
+		{"sam", "red_cards", redCard},
+	}
+	if got := provisionalCards(t, pb); !slices.Equal(got, want) {
+		t.Fatalf("provisional cards after the own goal was retracted = %+v, want %+v", got, want)
+	}
+
+	// Sam's real cards are issued first
+	confirmResults(t, pb, "sam")
+	if err := PollLiveCards(pb); err != nil {
+		t.Fatal(err)
+	}
+	want = []provisionalCard{{"chris", "red_cards", redCard}}
+	if got := provisionalCards(t, pb); !slices.Equal(got, want) {
+		t.Fatalf("provisional cards after Sam's results = %+v, want %+v", got, want)
+	}
+
+	// then the gameweek is finalised for everyone
+	confirmResults(t, pb, "demo", "alex", "chris")
+	if err := PollLiveCards(pb); err != nil {
+		t.Fatal(err)
+	}
+	if got := provisionalCards(t, pb); len(got) != 0 {
+		t.Fatalf("provisional cards after the gameweek was finalised = %+v, want none", got)
+	}
+}
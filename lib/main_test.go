@@ -0,0 +1,25 @@
+package lib
+
+import (
+	"testing"
+
+	_ "github.com/cmcd97/bytesize/migrations"
+	"github.com/pocketbase/pocketbase"
+)
+
+// newTestApp bootstraps an app with every migration applied in a temporary
+// data directory, which is removed once the test is done.
+func newTestApp(tb testing.TB) *pocketbase.PocketBase {
+	tb.Helper()
+
+	pb := pocketbase.NewWithConfig(pocketbase.Config{DefaultDataDir: tb.TempDir()})
+	if err := pb.Bootstrap(); err != nil {
+		tb.Fatalf("bootstrap app: %v", err)
+	}
+	tb.Cleanup(func() { pb.ResetBootstrapState() })
+
+	if err := runMigrations(pb); err != nil {
+		tb.Fatal(err)
+	}
+	return pb
+}
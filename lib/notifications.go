@@ -0,0 +1,30 @@
+package lib
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+const NotificationsCollection = "notifications"
+
+// Notify leaves message on userID's profile page until they dismiss it. kind
+// says what it's about, e.g. "card_voided".
+func Notify(txDao *daos.Dao, userID string, kind string, message string) error {
+	collection, err := txDao.FindCollectionByNameOrId(NotificationsCollection)
+	if err != nil {
+		return fmt.Errorf("find collection: %w", err)
+	}
+
+	notification := models.NewRecord(collection)
+	notification.Set("userID", userID)
+	notification.Set("kind", kind)
+	notification.Set("message", message)
+	notification.Set("dismissed", false)
+
+	if err := txDao.SaveRecord(notification); err != nil {
+		return fmt.Errorf("save notification: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package lib
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/cmcd97/bytesize/app/types"
+)
+
+// encodePicksJSON encodes the JSON columns of a results row. A missing list
+// is stored as [] so rows from FPL and rows read back compare equal.
+func encodePicksJSON[T any](values []T) string {
+	if values == nil {
+		values = []T{}
+	}
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return "[]"
+	}
+	return string(encoded)
+}
+
+func resultAutomaticSubs(result types.DatabaseResults) []types.AutomaticSub {
+	var subs []types.AutomaticSub
+	if err := json.Unmarshal([]byte(result.AutomaticSubs), &subs); err != nil {
+		return nil
+	}
+	return subs
+}
+
+func resultPlayerIDs(result types.DatabaseResults) []int {
+	return []int{
+		result.Pos1, result.Pos2, result.Pos3, result.Pos4, result.Pos5,
+		result.Pos6, result.Pos7, result.Pos8, result.Pos9, result.Pos10, result.Pos11,
+		result.Pos12, result.Pos13, result.Pos14, result.Pos15,
+	}
+}
+
+// effectiveStarters returns the XI a result actually fielded, with FPL's
+// automatic substitutions applied, and the player who wore the armband. The
+// vice captain takes over when the captain was substituted off. It returns 0
+// for the captain if neither of them played.
+func effectiveStarters(result types.DatabaseResults) ([]int, int) {
+	starters := resultPlayerIDs(result)[:11]
+	for _, sub := range resultAutomaticSubs(result) {
+		if i := slices.Index(starters, sub.ElementOut); i >= 0 {
+			starters[i] = sub.ElementIn
+		}
+	}
+
+	captain := 0
+	for _, playerID := range []int{result.Captain, result.ViceCaptain} {
+		if playerID != 0 && slices.Contains(starters, playerID) {
+			captain = playerID
+			break
+		}
+	}
+	return starters, captain
+}
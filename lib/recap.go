@@ -0,0 +1,390 @@
+package lib
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+const (
+	GameweekRecapsCollection = "gameweek_recaps"
+	recapMovers              = 3
+)
+
+type recapWeek struct {
+	UserID          string `db:"userID"`
+	Gameweek        int    `db:"gameweek"`
+	Points          int    `db:"points"`
+	TotalPoints     int    `db:"totalPoints"`
+	IsSuspendedNext bool   `db:"isSuspendedNext"`
+	FirstName       string `db:"firstName"`
+	LastName        string `db:"lastName"`
+	TeamName        string `db:"teamName"`
+}
+
+// GenerateRecaps builds the recap of gameweek for every league handing out
+// cards for it, replacing any built before. It runs after
+// updateResultsAggregated, once the gameweek's standings are final.
+func GenerateRecaps(pb *pocketbase.PocketBase, gameweek int) error {
+	log.Printf("[Recap] Building the recaps of gameweek %d", gameweek)
+
+	return pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		leagues, err := txDao.FindRecordsByExpr("league_settings")
+		if err != nil {
+			return fmt.Errorf("error fetching league settings: %w", err)
+		}
+		collection, err := txDao.FindCollectionByNameOrId(GameweekRecapsCollection)
+		if err != nil {
+			return fmt.Errorf("error finding collection: %w", err)
+		}
+
+		season := CurrentSeasonStartYear(txDao)
+		built := 0
+		for _, settings := range leagues {
+			rules := leagueCardRules{
+				startGameweek:             settings.GetInt("startGameweek"),
+				seasonConfirmationPending: settings.GetBool("seasonConfirmationPending"),
+			}
+			if !rules.handsOutCards(gameweek) {
+				continue
+			}
+
+			recap, err := buildGameweekRecap(txDao, settings, gameweek)
+			if err != nil {
+				return err
+			}
+			if recap.Winner.Name == "" {
+				continue
+			}
+
+			record, err := txDao.FindFirstRecordByFilter(GameweekRecapsCollection,
+				"seasonStartYear = {:season} && leagueID = {:leagueID} && gameweek = {:gameweek}",
+				dbx.Params{"season": season, "leagueID": recap.LeagueID, "gameweek": gameweek})
+			if err != nil {
+				record = models.NewRecord(collection)
+			}
+			record.Set("seasonStartYear", season)
+			record.Set("leagueID", recap.LeagueID)
+			record.Set("gameweek", gameweek)
+			record.Set("summary", recap)
+			if err := txDao.SaveRecord(record); err != nil {
+				return fmt.Errorf("error saving the recap of league %d: %w", recap.LeagueID, err)
+			}
+			built++
+		}
+
+		log.Printf("[Recap] Built %d recaps of gameweek %d", built, gameweek)
+		return nil
+	})
+}
+
+// GetGameweekRecap reads leagueID's recap of gameweek this season, or of its
+// latest recapped gameweek when gameweek is 0. It also lists every gameweek
+// recapped so far, latest first.
+func GetGameweekRecap(dao *daos.Dao, leagueID int, gameweek int) (types.GameweekRecap, []int, error) {
+	var recap types.GameweekRecap
+
+	records, err := dao.FindRecordsByFilter(GameweekRecapsCollection,
+		"seasonStartYear = {:season} && leagueID = {:leagueID}", "-gameweek", 0, 0,
+		dbx.Params{"season": CurrentSeasonStartYear(dao), "leagueID": leagueID})
+	if err != nil {
+		return recap, nil, fmt.Errorf("error fetching recaps: %w", err)
+	}
+
+	var gameweeks []int
+	var found *models.Record
+	for _, record := range records {
+		gameweeks = append(gameweeks, record.GetInt("gameweek"))
+		if found == nil && (gameweek == 0 || record.GetInt("gameweek") == gameweek) {
+			found = record
+		}
+	}
+	if found == nil {
+		return recap, gameweeks, nil
+	}
+	if err := found.UnmarshalJSONField("summary", &recap); err != nil {
+		return recap, gameweeks, fmt.Errorf("error reading the recap of gameweek %d: %w", found.GetInt("gameweek"), err)
+	}
+	return recap, gameweeks, nil
+}
+
+func buildGameweekRecap(txDao *daos.Dao, settings *models.Record, gameweek int) (types.GameweekRecap, error) {
+	leagueID := settings.GetInt("leagueID")
+	recap := types.GameweekRecap{
+		LeagueID:   leagueID,
+		LeagueName: settings.GetString("displayName"),
+		Gameweek:   gameweek,
+	}
+
+	members, err := txDao.FindRecordsByExpr("leagues",
+		dbx.NewExp("leagueID = {:leagueID} AND hasLeft = FALSE", dbx.Params{"leagueID": leagueID}))
+	if err != nil {
+		return recap, fmt.Errorf("error fetching the members of league %d: %w", leagueID, err)
+	}
+	if len(members) == 0 {
+		return recap, nil
+	}
+	if recap.LeagueName == "" {
+		recap.LeagueName = members[0].GetString("leagueName")
+	}
+	teamIDs := make([]interface{}, 0, len(members))
+	for _, member := range members {
+		teamIDs = append(teamIDs, member.GetInt("teamID"))
+	}
+
+	startGameweek := max(settings.GetInt("startGameweek"), 1)
+
+	var weeks []recapWeek
+	err = txDao.DB().
+		Select(
+			"ag.userID",
+			"ag.gameweek",
+			"ag.points",
+			"ag.totalPoints",
+			"ag.isSuspendedNext",
+			"COALESCE(u.firstName, '') as firstName",
+			"COALESCE(u.lastName, '') as lastName",
+			"COALESCE(u.teamName, '') as teamName").
+		From("aggregated_results ag").
+		LeftJoin("users u", dbx.NewExp("ag.userID = u.id")).
+		Where(dbx.In("ag.gameweek", startGameweek-1, gameweek-1, gameweek)).
+		AndWhere(dbx.In("ag.teamID", teamIDs...)).
+		All(&weeks)
+	if err != nil {
+		return recap, fmt.Errorf("error fetching the gameweeks of league %d: %w", leagueID, err)
+	}
+
+	names := make(map[string]string)
+	startTotals := make(map[string]int)
+	previousTotals := make(map[string]int)
+	totals := make(map[string]int)
+	var scores []recapWeek
+	for _, week := range weeks {
+		names[week.UserID] = ManagerName(week.FirstName, week.LastName)
+		// the start week can also be last week, so this isn't a switch
+		if week.Gameweek == startGameweek-1 {
+			startTotals[week.UserID] = week.TotalPoints
+		}
+		if week.Gameweek == gameweek-1 {
+			previousTotals[week.UserID] = week.TotalPoints
+			if week.IsSuspendedNext {
+				recap.SuspensionsEnding = append(recap.SuspensionsEnding, names[week.UserID])
+			}
+		}
+		if week.Gameweek == gameweek {
+			totals[week.UserID] = week.TotalPoints
+			scores = append(scores, week)
+			if week.IsSuspendedNext {
+				recap.SuspensionsStarting = append(recap.SuspensionsStarting, names[week.UserID])
+			}
+		}
+	}
+	if len(scores) == 0 {
+		return recap, nil
+	}
+	sort.Strings(recap.SuspensionsStarting)
+	sort.Strings(recap.SuspensionsEnding)
+
+	// ranked the way the gameweek winner is picked
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Points != scores[j].Points {
+			return scores[i].Points > scores[j].Points
+		}
+		return scores[i].TotalPoints > scores[j].TotalPoints
+	})
+	recapScore := func(week recapWeek) types.RecapScore {
+		return types.RecapScore{Name: names[week.UserID], TeamName: week.TeamName, Points: week.Points}
+	}
+	recap.Winner = recapScore(scores[0])
+	if len(scores) > 1 {
+		recap.RunnerUp = recapScore(scores[1])
+		recap.LowestScorer = recapScore(scores[len(scores)-1])
+	}
+
+	if gameweek > startGameweek {
+		positions := standingPositions(totals, startTotals, names)
+		previousPositions := standingPositions(previousTotals, startTotals, names)
+		for userID, position := range positions {
+			previous, ok := previousPositions[userID]
+			if !ok || previous == position {
+				continue
+			}
+			recap.Movers = append(recap.Movers, types.RecapMover{Name: names[userID], Position: position, Change: previous - position})
+		}
+		sort.Slice(recap.Movers, func(i, j int) bool {
+			a, b := recap.Movers[i], recap.Movers[j]
+			if abs(a.Change) != abs(b.Change) {
+				return abs(a.Change) > abs(b.Change)
+			}
+			return a.Position < b.Position
+		})
+		recap.Movers = recap.Movers[:min(len(recap.Movers), recapMovers)]
+	}
+
+	var cards []struct {
+		UserID        string `db:"userID"`
+		Gameweek      int    `db:"gameweek"`
+		Type          string `db:"type"`
+		PlayerID      int    `db:"playerID"`
+		FixtureID     int    `db:"fixtureID"`
+		FirstName     string `db:"firstName"`
+		LastName      string `db:"lastName"`
+		NominatorName string `db:"nominatorName"`
+	}
+	err = txDao.DB().
+		Select(
+			"c.userID",
+			"c.gameweek",
+			"c.type",
+			"c.playerID",
+			"c.fixtureID",
+			"COALESCE(u.firstName, '') as firstName",
+			"COALESCE(u.lastName, '') as lastName",
+			"COALESCE(TRIM(nominator.firstName || ' ' || nominator.lastName), '') as nominatorName").
+		From("cards c").
+		LeftJoin("users u", dbx.NewExp("u.id = c.userID")).
+		LeftJoin("users nominator", dbx.NewExp("nominator.id = c.nominatorUserID")).
+		Where(dbx.HashExp{"c.leagueID": leagueID, "c.voided": false}).
+		AndWhere(dbx.In("c.gameweek", gameweek-1, gameweek)).
+		OrderBy("c.created asc").
+		All(&cards)
+	if err != nil {
+		return recap, fmt.Errorf("error fetching the cards of league %d: %w", leagueID, err)
+	}
+
+	for _, card := range cards {
+		name := ManagerName(card.FirstName, card.LastName)
+		switch {
+		case card.Type == "nomination" || card.Type == "reverse":
+			if card.Gameweek == gameweek-1 {
+				recap.Nominations = append(recap.Nominations, types.RecapNomination{Type: card.Type, From: card.NominatorName, To: name})
+			}
+		case card.Gameweek == gameweek:
+			recap.Cards = append(recap.Cards, types.RecapCard{
+				Name: name,
+				Type: card.Type,
+				Cause: cardIncident(txDao, card.FirstName+"'s", types.DatabaseCard{
+					Type:      card.Type,
+					PlayerID:  card.PlayerID,
+					FixtureID: card.FixtureID,
+				}),
+			})
+		}
+	}
+
+	return recap, nil
+}
+
+// standingPositions ranks managers by their points since the league started,
+// ties by name so the order doesn't change between runs
+func standingPositions(totals map[string]int, startTotals map[string]int, names map[string]string) map[string]int {
+	userIDs := make([]string, 0, len(totals))
+	for userID := range totals {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Slice(userIDs, func(i, j int) bool {
+		a := totals[userIDs[i]] - startTotals[userIDs[i]]
+		b := totals[userIDs[j]] - startTotals[userIDs[j]]
+		if a != b {
+			return a > b
+		}
+		return names[userIDs[i]] < names[userIDs[j]]
+	})
+
+	positions := make(map[string]int, len(userIDs))
+	for i, userID := range userIDs {
+		positions[userID] = i + 1
+	}
+	return positions
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Ordinal formats a position, e.g. 1st, 2nd or 11th
+func Ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
+
+// RecapMoverText says how a manager moved, e.g. "up 2 to 1st"
+func RecapMoverText(mover types.RecapMover) string {
+	if mover.Change > 0 {
+		return fmt.Sprintf("up %d to %s", mover.Change, Ordinal(mover.Position))
+	}
+	return fmt.Sprintf("down %d to %s", -mover.Change, Ordinal(mover.Position))
+}
+
+// RecapNominationText says who nominated or reversed a card onto whom
+func RecapNominationText(nomination types.RecapNomination) string {
+	if nomination.Type == "reverse" {
+		return nomination.From + " reversed a card back onto " + nomination.To
+	}
+	return nomination.From + " nominated " + nomination.To
+}
+
+// RecapMarkdown writes a recap as Markdown to paste into a group chat
+func RecapMarkdown(recap types.GameweekRecap) string {
+	var md strings.Builder
+	fmt.Fprintf(&md, "## %s: GW%d recap\n\n", recap.LeagueName, recap.Gameweek)
+
+	fmt.Fprintf(&md, "- **Winner:** %s, %d pts\n", recap.Winner.Name, recap.Winner.Points)
+	if recap.RunnerUp.Name != "" {
+		fmt.Fprintf(&md, "- **Runner-up:** %s, %d pts\n", recap.RunnerUp.Name, recap.RunnerUp.Points)
+		fmt.Fprintf(&md, "- **Lowest scorer:** %s, %d pts\n", recap.LowestScorer.Name, recap.LowestScorer.Points)
+	}
+
+	md.WriteString("\n### Cards\n\n")
+	if len(recap.Cards) == 0 {
+		md.WriteString("No cards this gameweek.\n")
+	}
+	for _, card := range recap.Cards {
+		fmt.Fprintf(&md, "- %s, %s: %s\n", card.Name, ReplaceUnderscoresWithSpaces(card.Type), card.Cause)
+	}
+
+	if len(recap.SuspensionsStarting) > 0 || len(recap.SuspensionsEnding) > 0 {
+		md.WriteString("\n### Suspensions\n\n")
+		for _, name := range recap.SuspensionsStarting {
+			fmt.Fprintf(&md, "- %s sits out GW%d\n", name, recap.Gameweek+1)
+		}
+		for _, name := range recap.SuspensionsEnding {
+			fmt.Fprintf(&md, "- %s is back after serving a suspension\n", name)
+		}
+	}
+
+	if len(recap.Nominations) > 0 {
+		md.WriteString("\n### Nominations and reverses\n\n")
+		for _, nomination := range recap.Nominations {
+			fmt.Fprintf(&md, "- %s\n", RecapNominationText(nomination))
+		}
+	}
+
+	if len(recap.Movers) > 0 {
+		md.WriteString("\n### Biggest movers\n\n")
+		for _, mover := range recap.Movers {
+			fmt.Fprintf(&md, "- %s %s\n", mover.Name, RecapMoverText(mover))
+		}
+	}
+
+	return md.String()
+}
@@ -0,0 +1,187 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"sync"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	recapImageWidth  = 1200
+	recapImageHeight = 630
+	recapImageMargin = 60
+	recapColumnWidth = (recapImageWidth - 3*recapImageMargin) / 2
+	// lines per section before the rest is summed up as "and N more"
+	recapImageLines = 4
+)
+
+var (
+	recapBackground = color.RGBA{0x1d, 0x23, 0x2a, 0xff}
+	recapAccent     = color.RGBA{0x64, 0x19, 0xe6, 0xff}
+	recapText       = color.RGBA{0xa6, 0xad, 0xbb, 0xff}
+	recapHeading    = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	recapWarning    = color.RGBA{0xfb, 0xbd, 0x23, 0xff}
+)
+
+type recapFaces struct {
+	title   font.Face
+	heading font.Face
+	body    font.Face
+}
+
+var (
+	recapFacesOnce sync.Once
+	recapFacesErr  error
+	recapFontFaces recapFaces
+)
+
+func loadRecapFaces() (recapFaces, error) {
+	recapFacesOnce.Do(func() {
+		regular, err := opentype.Parse(goregular.TTF)
+		if err != nil {
+			recapFacesErr = fmt.Errorf("error parsing regular font: %w", err)
+			return
+		}
+		bold, err := opentype.Parse(gobold.TTF)
+		if err != nil {
+			recapFacesErr = fmt.Errorf("error parsing bold font: %w", err)
+			return
+		}
+		face := func(f *opentype.Font, size float64) font.Face {
+			if recapFacesErr != nil {
+				return nil
+			}
+			var face font.Face
+			face, recapFacesErr = opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+			return face
+		}
+		recapFontFaces = recapFaces{
+			title:   face(bold, 52),
+			heading: face(bold, 30),
+			body:    face(regular, 26),
+		}
+	})
+	return recapFontFaces, recapFacesErr
+}
+
+// recapCanvas writes lines of text down a column, cutting any too wide for it
+type recapCanvas struct {
+	img *image.RGBA
+	x   int
+	y   int
+}
+
+func (canvas *recapCanvas) line(face font.Face, colour color.Color, text string, width int) {
+	drawer := &font.Drawer{Dst: canvas.img, Src: image.NewUniform(colour), Face: face}
+	text = fitText(drawer, text, width)
+	metrics := face.Metrics()
+	canvas.y += metrics.Ascent.Ceil()
+	drawer.Dot = fixed.P(canvas.x, canvas.y)
+	drawer.DrawString(text)
+	canvas.y += metrics.Descent.Ceil() + 10
+}
+
+func (canvas *recapCanvas) section(faces recapFaces, heading string, lines []string) {
+	canvas.y += 14
+	canvas.line(faces.heading, recapHeading, heading, recapColumnWidth)
+	canvas.lines(faces, recapText, lines)
+}
+
+// lines draws up to recapImageLines lines, so a long list can't run off the
+// bottom of the card
+func (canvas *recapCanvas) lines(faces recapFaces, colour color.Color, lines []string) {
+	for i, text := range lines {
+		if i == recapImageLines-1 && len(lines) > recapImageLines {
+			canvas.line(faces.body, colour, fmt.Sprintf("and %d more", len(lines)-i), recapColumnWidth)
+			break
+		}
+		canvas.line(faces.body, colour, text, recapColumnWidth)
+	}
+}
+
+func fitText(drawer *font.Drawer, text string, width int) string {
+	if drawer.MeasureString(text).Ceil() <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if cut := string(runes) + "…"; drawer.MeasureString(cut).Ceil() <= width {
+			return cut
+		}
+	}
+	return ""
+}
+
+// RecapPNG draws a recap as a card sized for link previews in group chats:
+// the podium on the left, cards, suspensions and movers on the right.
+func RecapPNG(recap types.GameweekRecap) ([]byte, error) {
+	faces, err := loadRecapFaces()
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, recapImageWidth, recapImageHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(recapBackground), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, recapImageWidth, 12), image.NewUniform(recapAccent), image.Point{}, draw.Src)
+
+	header := &recapCanvas{img: img, x: recapImageMargin, y: recapImageMargin - 10}
+	header.line(faces.title, recapHeading, fmt.Sprintf("%s: GW%d recap", recap.LeagueName, recap.Gameweek), recapImageWidth-2*recapImageMargin)
+	top := header.y + 10
+
+	left := &recapCanvas{img: img, x: recapImageMargin, y: top}
+	score := func(label string, score types.RecapScore) {
+		left.y += 14
+		left.line(faces.heading, recapHeading, label, recapColumnWidth)
+		left.line(faces.body, recapText, fmt.Sprintf("%s, %d pts", score.Name, score.Points), recapColumnWidth)
+	}
+	score("Winner", recap.Winner)
+	if recap.RunnerUp.Name != "" {
+		score("Runner-up", recap.RunnerUp)
+		score("Lowest scorer", recap.LowestScorer)
+	}
+
+	right := &recapCanvas{img: img, x: 2*recapImageMargin + recapColumnWidth, y: top}
+	var cards []string
+	for _, card := range recap.Cards {
+		cards = append(cards, fmt.Sprintf("%s: %s", card.Name, ReplaceUnderscoresWithSpaces(card.Type)))
+	}
+	if len(cards) == 0 {
+		cards = append(cards, "No cards this gameweek")
+	}
+	right.section(faces, "Cards", cards)
+
+	if len(recap.SuspensionsStarting) > 0 {
+		var suspensions []string
+		for _, name := range recap.SuspensionsStarting {
+			suspensions = append(suspensions, fmt.Sprintf("%s sits out GW%d", name, recap.Gameweek+1))
+		}
+		right.y += 14
+		right.lines(faces, recapWarning, suspensions)
+	}
+
+	if len(recap.Movers) > 0 {
+		var movers []string
+		for _, mover := range recap.Movers {
+			movers = append(movers, mover.Name+" "+RecapMoverText(mover))
+		}
+		right.section(faces, "Biggest movers", movers)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("error encoding recap image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,123 @@
+package lib
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/daos"
+)
+
+// The number of outstanding cards that gets a manager suspended, as applied
+// by updateResultsAggregated
+const suspensionCardThreshold = 2
+
+// The number of open cards that gets a manager suspended however few
+// gameweeks and types they span, counting every league's copy
+const suspensionRawCardThreshold = 4
+
+// ReconcileCards picks up match stats FPL revised after a gameweek was
+// processed, such as an own goal reassigned or a red card rescinded on
+// appeal. When anything changed the cards are derived again, which voids the
+// ones that no longer stand, and the standings are rebuilt without any
+// suspensions that relied on them.
+func ReconcileCards(pb *pocketbase.PocketBase) error {
+	log.Println("[CardReconciliation] Checking for revised match stats")
+
+	revisions, err := checkForEventsUpdate(pb)
+	if err != nil {
+		log.Printf("[CardReconciliation] Failed to update events: %v", err)
+		return err
+	}
+	if revisions == 0 {
+		log.Println("[CardReconciliation] No match stats were revised")
+		return nil
+	}
+
+	if err := updateCards(pb); err != nil {
+		log.Printf("[CardReconciliation] Failed to update cards: %v", err)
+		return err
+	}
+	if err := updateResultsAggregated(pb); err != nil {
+		log.Printf("[CardReconciliation] Failed to update results aggregated: %v", err)
+		return err
+	}
+	// charges for voided cards are cancelled, restored ones charged again
+	if err := SyncFineLedgers(pb); err != nil {
+		log.Printf("[CardReconciliation] Failed to update fine ledgers: %v", err)
+	}
+
+	log.Printf("[CardReconciliation] Reconciled cards after %d revised events", revisions)
+	return nil
+}
+
+// liftUnsupportedSuspensions goes through userID's suspensions from
+// fromGameweek on and lifts the ones that no longer have enough cards behind
+// them once voided cards are left out. A suspension after gameweek G is
+// earned by the cards since the previous one, up to and including G.
+func liftUnsupportedSuspensions(txDao *daos.Dao, userID string, fromGameweek int) error {
+	suspensions, err := txDao.FindRecordsByFilter(
+		"aggregated_results",
+		"userID = {:userID} && isSuspendedNext = true",
+		"gameweek",
+		0,
+		0,
+		dbx.Params{"userID": userID},
+	)
+	if err != nil {
+		return fmt.Errorf("error finding suspensions: %w", err)
+	}
+
+	previous := 0
+	for _, suspension := range suspensions {
+		gameweek := suspension.GetInt("gameweek")
+		if gameweek < fromGameweek {
+			previous = gameweek
+			continue
+		}
+
+		// counted the way updateResultsAggregated suspends: each league has its
+		// own copy of a card, and two cards of a type in a gameweek count once
+		var cards struct {
+			Distinct int `db:"distinctCards"`
+			Raw      int `db:"rawCards"`
+		}
+		err := txDao.DB().NewQuery(`
+SELECT
+    COUNT(DISTINCT gameweek || ':' || type) as distinctCards,
+    COUNT(*) as rawCards
+FROM {{cards}}
+WHERE userID = {:userID} AND voided = FALSE AND gameweek > {:from} AND gameweek <= {:to}`).
+			Bind(dbx.Params{"userID": userID, "from": previous, "to": gameweek}).
+			One(&cards)
+		if err != nil {
+			return fmt.Errorf("error counting cards: %w", err)
+		}
+		if isSuspendable(cards.Distinct, cards.Raw) {
+			previous = gameweek
+			continue
+		}
+
+		suspension.Set("isSuspendedNext", false)
+		if err := txDao.SaveRecord(suspension); err != nil {
+			return fmt.Errorf("error lifting suspension: %w", err)
+		}
+		log.Printf("[CardReconciliation] Lifted suspension after gameweek %d for user %s, %d cards left", gameweek, userID, cards.Distinct)
+
+		err = WriteAuditLog(txDao, "", "suspension_lifted", userID, map[string]any{
+			"gameweek": gameweek,
+			"cards":    cards.Distinct,
+			"copies":   cards.Raw,
+		})
+		if err != nil {
+			return err
+		}
+		message := fmt.Sprintf("Your suspension for gameweek %d has been lifted because a card behind it was voided.", gameweek+1)
+		if err := Notify(txDao, userID, "suspension_lifted", message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
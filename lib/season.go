@@ -0,0 +1,286 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+const (
+	SeasonsCollection = "seasons"
+
+	// The prefix of the collections last season's rows are moved into
+	archivePrefix = "archived_"
+
+	rolloverTimeout = 2 * time.Minute
+)
+
+// The collections a rollover archives. Fixtures and events aren't kept, FPL
+// numbers them from 1 again every season and they can be fetched again.
+var archivedCollections = []string{"cards", "results", "aggregated_results"}
+
+// SeasonName formats a season the way FPL does, e.g. 2024/25
+func SeasonName(startYear int) string {
+	return fmt.Sprintf("%d/%02d", startYear, (startYear+1)%100)
+}
+
+// CurrentSeasonStartYear returns the year the season the app is running
+// kicked off. Before the first season is recorded it guesses from the date,
+// FPL seasons start in August.
+func CurrentSeasonStartYear(dao *daos.Dao) int {
+	season, err := dao.FindFirstRecordByFilter(SeasonsCollection, "isCurrent = true")
+	if err == nil {
+		return season.GetInt("startYear")
+	}
+
+	now := time.Now()
+	if now.Month() < time.July {
+		return now.Year() - 1
+	}
+	return now.Year()
+}
+
+// recordSeason records startYear as the current season if no season has been
+// recorded yet, and warns when FPL has moved past the current one. Moving on
+// to a new season is left to RolloverSeason so nothing is archived by
+// surprise.
+func recordSeason(pb *pocketbase.PocketBase, startYear int, fplStartYear int) error {
+	current, err := pb.Dao().FindFirstRecordByFilter(SeasonsCollection, "isCurrent = true")
+	if err == nil {
+		if current.GetInt("startYear") < fplStartYear {
+			log.Printf("[Season] FPL has moved on to %s, run `rollover` to archive %s",
+				SeasonName(fplStartYear), SeasonName(current.GetInt("startYear")))
+		}
+		return nil
+	}
+
+	collection, err := pb.Dao().FindCollectionByNameOrId(SeasonsCollection)
+	if err != nil {
+		return fmt.Errorf("error finding collection: %w", err)
+	}
+	season := models.NewRecord(collection)
+	season.Set("startYear", startYear)
+	season.Set("name", SeasonName(startYear))
+	season.Set("isCurrent", true)
+	if err := pb.Dao().SaveRecord(season); err != nil {
+		return fmt.Errorf("error saving season: %w", err)
+	}
+	log.Printf("[Season] Recorded %s as the current season", SeasonName(startYear))
+	return nil
+}
+
+// RolloverSeason moves the app on to the season FPL is running now. How each
+// league finished is summarised for the archive pages, last season's cards,
+// results and standings are moved into their archives, the players and
+// fixtures of the new season are imported, everyone's reverse card is taken
+// back and every league is asked to confirm it's carrying on.
+// Leagues don't earn cards until an admin has confirmed. It refuses to run
+// while FPL is still on the current season.
+func RolloverSeason(pb *pocketbase.PocketBase) error {
+	if err := runMigrations(pb); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rolloverTimeout)
+	defer cancel()
+
+	var fplData types.FPLResponse
+	if err := fetchFPLJSON(ctx, FPLAPIBase+"/bootstrap-static/", &fplData); err != nil {
+		return fmt.Errorf("error fetching players: %w", err)
+	}
+	newYear, err := seasonStartYear(&fplData)
+	if err != nil {
+		return err
+	}
+
+	var fixtures []types.Fixtures
+	if err := fetchFPLJSON(ctx, FPLAPIBase+"/fixtures/", &fixtures); err != nil {
+		return fmt.Errorf("error fetching fixtures: %w", err)
+	}
+
+	return rolloverSeason(pb, newYear, teamRows(fplData.Teams, newYear), playerRows(fplData.Elements, newYear), fixtureRows(fixtures))
+}
+
+func rolloverSeason(pb *pocketbase.PocketBase, newYear int, teams []dbx.Params, players []dbx.Params, fixtures []dbx.Params) error {
+	return pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		current, err := txDao.FindFirstRecordByFilter(SeasonsCollection, "isCurrent = true")
+		if err != nil {
+			return fmt.Errorf("no current season is recorded yet, start the server once so it can record one: %w", err)
+		}
+		oldYear := current.GetInt("startYear")
+		if newYear <= oldYear {
+			return fmt.Errorf("FPL is still on %s, there's no new season to roll over to", SeasonName(oldYear))
+		}
+		log.Printf("[Season] Rolling over from %s to %s", SeasonName(oldYear), SeasonName(newYear))
+
+		if err := snapshotSeasonArchives(txDao, oldYear); err != nil {
+			return err
+		}
+
+		archived := make(map[string]int64, len(archivedCollections))
+		for _, name := range archivedCollections {
+			count, err := archiveCollection(txDao, name, oldYear)
+			if err != nil {
+				return err
+			}
+			archived[name] = count
+			log.Printf("[Season] Archived %d %s", count, name)
+		}
+
+		for _, name := range []string{"events", "fixtures", ProvisionalCardsCollection} {
+			if _, err := txDao.DB().NewQuery(fmt.Sprintf("DELETE FROM {{%s}}", name)).Execute(); err != nil {
+				return fmt.Errorf("error clearing %s: %w", name, err)
+			}
+		}
+		if err := UpsertRows(txDao, "fixtures", fixtureConflictColumns, fixtureUpdateColumns, fixtures); err != nil {
+			return fmt.Errorf("error saving fixtures: %w", err)
+		}
+		if err := UpsertRows(txDao, "teams", teamConflictColumns, teamUpdateColumns, teams); err != nil {
+			return fmt.Errorf("error saving teams: %w", err)
+		}
+		if err := UpsertRows(txDao, "players", playerConflictColumns, playerUpdateColumns, players); err != nil {
+			return fmt.Errorf("error saving players: %w", err)
+		}
+
+		if _, err := txDao.DB().NewQuery("UPDATE {{users}} SET [[hasReverse]] = FALSE").Execute(); err != nil {
+			return fmt.Errorf("error resetting reverse cards: %w", err)
+		}
+
+		if err := askLeaguesToContinue(txDao, newYear); err != nil {
+			return err
+		}
+		if err := startSeason(txDao, oldYear, newYear); err != nil {
+			return err
+		}
+
+		return WriteAuditLog(txDao, "", "season_rollover", "", map[string]any{
+			"from":     oldYear,
+			"to":       newYear,
+			"archived": archived,
+			"teams":    len(teams),
+			"players":  len(players),
+			"fixtures": len(fixtures),
+		})
+	})
+}
+
+// archiveCollection moves every row of a collection into its archive, tagged
+// with the season it belongs to, and returns how many it moved. Fields added
+// to the collection since its archive was created are added to the archive
+// first, so nothing is dropped.
+func archiveCollection(txDao *daos.Dao, name string, seasonStartYear int) (int64, error) {
+	source, err := txDao.FindCollectionByNameOrId(name)
+	if err != nil {
+		return 0, fmt.Errorf("error finding %s: %w", name, err)
+	}
+	archive, err := txDao.FindCollectionByNameOrId(archivePrefix + name)
+	if err != nil {
+		return 0, fmt.Errorf("error finding the archive of %s: %w", name, err)
+	}
+
+	columns := []string{"[[id]]", "[[created]]", "[[updated]]"}
+	missing := false
+	for _, field := range source.Schema.Fields() {
+		if field.Name == "seasonStartYear" {
+			continue
+		}
+		if archive.Schema.GetFieldByName(field.Name) == nil {
+			archive.Schema.AddField(&schema.SchemaField{Name: field.Name, Type: field.Type, Options: field.Options})
+			missing = true
+		}
+		columns = append(columns, "[["+field.Name+"]]")
+	}
+	if missing {
+		if err := txDao.SaveCollection(archive); err != nil {
+			return 0, fmt.Errorf("error updating the archive of %s: %w", name, err)
+		}
+	}
+
+	list := strings.Join(columns, ", ")
+	result, err := txDao.DB().
+		NewQuery(fmt.Sprintf("INSERT INTO {{%s}} (%s, [[seasonStartYear]]) SELECT %s, {:season} FROM {{%s}}",
+			archive.Name, list, list, name)).
+		Bind(dbx.Params{"season": seasonStartYear}).
+		Execute()
+	if err != nil {
+		return 0, fmt.Errorf("error archiving %s: %w", name, err)
+	}
+	if _, err := txDao.DB().NewQuery(fmt.Sprintf("DELETE FROM {{%s}}", name)).Execute(); err != nil {
+		return 0, fmt.Errorf("error clearing %s: %w", name, err)
+	}
+
+	return result.RowsAffected()
+}
+
+// askLeaguesToContinue holds every league's cards until one of its admins
+// confirms the league is carrying on into the new season, and lets the admins
+// know. Start gameweeks and admin activity are counted from the new season's
+// first gameweek.
+func askLeaguesToContinue(txDao *daos.Dao, newYear int) error {
+	leagues, err := txDao.FindRecordsByExpr("league_settings")
+	if err != nil {
+		return fmt.Errorf("error fetching league settings: %w", err)
+	}
+
+	for _, settings := range leagues {
+		settings.Set("seasonConfirmationPending", true)
+		settings.Set("startGameweek", 1)
+		settings.Set("lastAdminActiveGameweek", 0)
+		if err := txDao.SaveRecord(settings); err != nil {
+			return fmt.Errorf("error saving league settings: %w", err)
+		}
+
+		name := settings.GetString("displayName")
+		if name == "" {
+			name = "your league"
+		}
+		message := fmt.Sprintf("The %s season has started. Confirm on the league settings page that %s is carrying on, it won't hand out cards until you do.",
+			SeasonName(newYear), name)
+		for _, adminID := range settings.GetStringSlice("adminUserIDs") {
+			if err := Notify(txDao, adminID, "season_confirmation", message); err != nil {
+				return err
+			}
+		}
+	}
+
+	log.Printf("[Season] Asked %d leagues to confirm they're continuing", len(leagues))
+	return nil
+}
+
+// startSeason retires the current season and records the new one
+func startSeason(txDao *daos.Dao, oldYear, newYear int) error {
+	collection, err := txDao.FindCollectionByNameOrId(SeasonsCollection)
+	if err != nil {
+		return fmt.Errorf("error finding collection: %w", err)
+	}
+
+	seasons := []struct {
+		startYear int
+		isCurrent bool
+	}{{oldYear, false}, {newYear, true}}
+	for _, entry := range seasons {
+		season, err := txDao.FindFirstRecordByData(SeasonsCollection, "startYear", entry.startYear)
+		if err != nil {
+			season = models.NewRecord(collection)
+			season.Set("startYear", entry.startYear)
+			season.Set("name", SeasonName(entry.startYear))
+		}
+		season.Set("isCurrent", entry.isCurrent)
+		if !entry.isCurrent {
+			season.Set("archivedAt", time.Now().UTC())
+		}
+		if err := txDao.SaveRecord(season); err != nil {
+			return fmt.Errorf("error saving season %s: %w", SeasonName(entry.startYear), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package lib
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+const SeasonArchivesCollection = "season_archives"
+
+// ManagerName is how archives and the hall of fame show a manager
+func ManagerName(firstName, lastName string) string {
+	return strings.TrimSpace(firstName + " " + lastName)
+}
+
+// snapshotSeasonArchives records how every league finished the season, so
+// the archive pages can still show it once the season's cards and results
+// have been moved out. It has to run before they're archived.
+func snapshotSeasonArchives(txDao *daos.Dao, seasonStartYear int) error {
+	leagues, err := txDao.FindRecordsByExpr("league_settings")
+	if err != nil {
+		return fmt.Errorf("error fetching league settings: %w", err)
+	}
+
+	lastGameweek := 0
+	if err := txDao.DB().NewQuery("SELECT COALESCE(MAX(gameweek), 0) FROM {{aggregated_results}}").Row(&lastGameweek); err != nil {
+		return fmt.Errorf("error finding the last gameweek: %w", err)
+	}
+	if lastGameweek == 0 {
+		log.Printf("[Season] No results to archive for %s", SeasonName(seasonStartYear))
+		return nil
+	}
+
+	collection, err := txDao.FindCollectionByNameOrId(SeasonArchivesCollection)
+	if err != nil {
+		return fmt.Errorf("error finding collection: %w", err)
+	}
+
+	for _, settings := range leagues {
+		archive, err := buildSeasonArchive(txDao, settings, seasonStartYear, lastGameweek)
+		if err != nil {
+			return err
+		}
+		if len(archive.Standings) == 0 {
+			continue
+		}
+
+		record, err := txDao.FindFirstRecordByFilter(SeasonArchivesCollection,
+			"seasonStartYear = {:season} && leagueID = {:leagueID}",
+			dbx.Params{"season": seasonStartYear, "leagueID": archive.LeagueID})
+		if err != nil {
+			record = models.NewRecord(collection)
+		}
+		record.Set("seasonStartYear", seasonStartYear)
+		record.Set("leagueID", archive.LeagueID)
+		record.Set("leagueName", archive.LeagueName)
+		record.Set("summary", archive)
+		if err := txDao.SaveRecord(record); err != nil {
+			return fmt.Errorf("error saving the archive of league %d: %w", archive.LeagueID, err)
+		}
+	}
+
+	log.Printf("[Season] Archived how %d leagues finished %s", len(leagues), SeasonName(seasonStartYear))
+	return nil
+}
+
+func buildSeasonArchive(txDao *daos.Dao, settings *models.Record, seasonStartYear int, lastGameweek int) (types.SeasonArchive, error) {
+	leagueID := settings.GetInt("leagueID")
+	archive := types.SeasonArchive{
+		SeasonStartYear: seasonStartYear,
+		LeagueID:        leagueID,
+		LeagueName:      settings.GetString("displayName"),
+		LastGameweek:    lastGameweek,
+	}
+
+	members, err := txDao.FindRecordsByExpr("leagues",
+		dbx.NewExp("leagueID = {:leagueID} AND hasLeft = FALSE", dbx.Params{"leagueID": leagueID}))
+	if err != nil {
+		return archive, fmt.Errorf("error fetching the members of league %d: %w", leagueID, err)
+	}
+	if len(members) == 0 {
+		return archive, nil
+	}
+	if archive.LeagueName == "" {
+		archive.LeagueName = members[0].GetString("leagueName")
+	}
+	teamIDs := make([]interface{}, 0, len(members))
+	for _, member := range members {
+		teamIDs = append(teamIDs, member.GetInt("teamID"))
+	}
+
+	startGameweek := settings.GetInt("startGameweek")
+	if startGameweek < 1 {
+		startGameweek = 1
+	}
+
+	// points scored before the league's start gameweek don't count
+	err = txDao.DB().
+		Select(
+			"ROW_NUMBER() OVER (ORDER BY ag.totalPoints - COALESCE(start.totalPoints, 0) desc) as position",
+			"ag.userID",
+			"COALESCE(u.firstName, '') as firstName",
+			"COALESCE(u.lastName, '') as lastName",
+			"COALESCE(u.teamName, '') as teamName",
+			"ag.totalPoints - COALESCE(start.totalPoints, 0) as totalPoints").
+		From("aggregated_results ag").
+		LeftJoin("users u", dbx.NewExp("ag.userID = u.id")).
+		LeftJoin("aggregated_results start", dbx.NewExp("start.userID = ag.userID AND start.gameweek = {:startGW} - 1", dbx.Params{"startGW": startGameweek})).
+		Where(dbx.NewExp("ag.gameweek = {:lastGW}", dbx.Params{"lastGW": lastGameweek})).
+		AndWhere(dbx.In("ag.teamID", teamIDs...)).
+		OrderBy("totalPoints desc").
+		All(&archive.Standings)
+	if err != nil {
+		return archive, fmt.Errorf("error fetching the standings of league %d: %w", leagueID, err)
+	}
+
+	standings := make(map[string]*types.ArchivedStanding, len(archive.Standings))
+	for i := range archive.Standings {
+		standings[archive.Standings[i].UserID] = &archive.Standings[i]
+	}
+
+	var weeks []struct {
+		UserID          string `db:"userID"`
+		Gameweek        int    `db:"gameweek"`
+		Points          int    `db:"points"`
+		IsSuspendedNext bool   `db:"isSuspendedNext"`
+	}
+	err = txDao.DB().
+		Select("userID", "gameweek", "points", "isSuspendedNext").
+		From("aggregated_results").
+		Where(dbx.Between("gameweek", startGameweek-1, lastGameweek)).
+		AndWhere(dbx.In("teamID", teamIDs...)).
+		All(&weeks)
+	if err != nil {
+		return archive, fmt.Errorf("error fetching the gameweeks of league %d: %w", leagueID, err)
+	}
+
+	topScores := make(map[int]int)
+	for _, week := range weeks {
+		if week.Gameweek >= startGameweek && week.Points > topScores[week.Gameweek] {
+			topScores[week.Gameweek] = week.Points
+		}
+	}
+	for _, week := range weeks {
+		standing, ok := standings[week.UserID]
+		if !ok {
+			continue
+		}
+		if week.Gameweek >= startGameweek && week.Points == topScores[week.Gameweek] {
+			standing.GameweekWins++
+		}
+		// a suspension is served the gameweek after it's earned
+		if week.IsSuspendedNext && week.Gameweek < lastGameweek {
+			standing.SuspensionsServed++
+		}
+	}
+
+	var cards []struct {
+		UserID          string `db:"userID"`
+		NominatorUserID string `db:"nominatorUserID"`
+		Type            string `db:"type"`
+	}
+	err = txDao.DB().
+		Select("userID", "nominatorUserID", "type").
+		From("cards").
+		Where(dbx.HashExp{"leagueID": leagueID, "voided": false}).
+		All(&cards)
+	if err != nil {
+		return archive, fmt.Errorf("error fetching the cards of league %d: %w", leagueID, err)
+	}
+
+	byType := make(map[string]int)
+	for _, card := range cards {
+		byType[card.Type]++
+		if standing, ok := standings[card.UserID]; ok {
+			standing.Cards++
+			if card.Type == "nomination" {
+				standing.NominationsReceived++
+			}
+		}
+		if standing, ok := standings[card.NominatorUserID]; ok && card.Type == "reverse" {
+			standing.ReversesLanded++
+		}
+	}
+	for cardType, count := range byType {
+		archive.CardsByType = append(archive.CardsByType, types.ArchivedCardCount{Type: cardType, Count: count})
+	}
+	sort.Slice(archive.CardsByType, func(i, j int) bool {
+		if archive.CardsByType[i].Count != archive.CardsByType[j].Count {
+			return archive.CardsByType[i].Count > archive.CardsByType[j].Count
+		}
+		return archive.CardsByType[i].Type < archive.CardsByType[j].Type
+	})
+
+	archive.Awards = seasonAwards(archive.Standings)
+	return archive, nil
+}
+
+// seasonAwards hands out the season's awards. Ties share an award, and an
+// award nobody earned isn't given.
+func seasonAwards(standings []types.ArchivedStanding) []types.ArchivedAward {
+	awards := []struct {
+		title  string
+		suffix string
+		count  func(types.ArchivedStanding) int
+	}{
+		{"Champion", "pts", func(s types.ArchivedStanding) int { return s.TotalPoints }},
+		{"Most gameweek wins", "wins", func(s types.ArchivedStanding) int { return s.GameweekWins }},
+		{"Most carded", "cards", func(s types.ArchivedStanding) int { return s.Cards }},
+		{"Most suspensions served", "suspensions", func(s types.ArchivedStanding) int { return s.SuspensionsServed }},
+		{"Most nominated", "nominations", func(s types.ArchivedStanding) int { return s.NominationsReceived }},
+		{"Most successful reverser", "reverses", func(s types.ArchivedStanding) int { return s.ReversesLanded }},
+	}
+
+	var given []types.ArchivedAward
+	for _, award := range awards {
+		best := 0
+		var names []string
+		for _, standing := range standings {
+			count := award.count(standing)
+			name := ManagerName(standing.FirstName, standing.LastName)
+			switch {
+			case count > best:
+				best = count
+				names = []string{name}
+			case count == best && count > 0:
+				names = append(names, name)
+			}
+		}
+		if best > 0 {
+			given = append(given, types.ArchivedAward{Title: award.title, Names: names, Count: best, Suffix: award.suffix})
+		}
+	}
+	return given
+}
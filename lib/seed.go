@@ -0,0 +1,321 @@
+package lib
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/migrate"
+)
+
+const (
+	SeedPassword = "demo-password"
+
+	seedLeagueID        = 999001
+	seedLeagueName      = "Demo League"
+	seedSeasonStartYear = 2024
+	seedGameweeks       = 4
+	seedPlayerCount     = 30
+)
+
+type seedManager struct {
+	username  string
+	firstName string
+	lastName  string
+	teamName  string
+	teamID    int
+}
+
+// The first manager owns the league
+var seedManagers = []seedManager{
+	{"demo", "Dana", "Demo", "Demo Dynamos", 990001},
+	{"alex", "Alex", "Smith", "Smith Rowe Rovers", 990002},
+	{"sam", "Sam", "Jones", "Jones Town", 990003},
+	{"chris", "Chris", "Lee", "Lee Side", 990004},
+}
+
+// Each event lands on a starter of the manager with the same index, so every
+// card type shows up in the demo
+var seedEvents = []struct {
+	manager   int
+	gameweek  int
+	eventType types.StatIdentifier
+}{
+	{1, 1, types.RedCards},
+	{2, 2, types.OwnGoals},
+	{3, 2, types.PenaltiesMissed},
+	{1, 3, types.OwnGoals},
+}
+
+// The chips some managers play, keyed by manager index and gameweek
+var seedChips = map[[2]int]string{
+	{1, 3}: ChipTripleCaptain,
+	{2, 4}: ChipBenchBoost,
+}
+
+// SeedDemoLeague fills an empty database with a small league (managers,
+// players, fixtures, a few gameweeks of results and the events that earn
+// cards) so a fresh clone has something to click through. Every manager can
+// sign in with SeedPassword. It does nothing if the demo league is already
+// there.
+func SeedDemoLeague(pb *pocketbase.PocketBase) error {
+	// The seed command can run before the server has ever started
+	if err := runMigrations(pb); err != nil {
+		return err
+	}
+
+	existing, _ := pb.Dao().FindAuthRecordByUsername("users", seedManagers[0].username)
+	if existing != nil {
+		log.Printf("[Seed] Demo league already seeded, sign in as %q", seedManagers[0].username)
+		return nil
+	}
+
+	err := pb.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		users, err := seedUsers(txDao)
+		if err != nil {
+			return err
+		}
+		if err := seedLeague(txDao, users); err != nil {
+			return err
+		}
+		return seedGameData(txDao, users)
+	})
+	if err != nil {
+		return fmt.Errorf("error seeding demo league: %w", err)
+	}
+
+	if err := recordSeason(pb, seedSeasonStartYear, seedSeasonStartYear); err != nil {
+		return err
+	}
+
+	// Cards and standings come out of the same pipeline the daily ETL runs
+	if err := updateCards(pb); err != nil {
+		return fmt.Errorf("error creating demo cards: %w", err)
+	}
+	if err := updateResultsAggregated(pb); err != nil {
+		return fmt.Errorf("error aggregating demo results: %w", err)
+	}
+	for gameweek := 1; gameweek <= seedGameweeks; gameweek++ {
+		if err := GenerateRecaps(pb, gameweek); err != nil {
+			return fmt.Errorf("error building demo recaps: %w", err)
+		}
+	}
+
+	log.Printf("[Seed] Seeded %q, sign in as %q with password %q", seedLeagueName, seedManagers[0].username, SeedPassword)
+	return nil
+}
+
+// runMigrations applies any migrations that haven't run yet, for commands
+// that can run before the server has started
+func runMigrations(pb *pocketbase.PocketBase) error {
+	runner, err := migrate.NewRunner(pb.DB(), migrations.AppMigrations)
+	if err != nil {
+		return fmt.Errorf("error creating migrations runner: %w", err)
+	}
+	if _, err := runner.Up(); err != nil {
+		return fmt.Errorf("error running migrations: %w", err)
+	}
+	return nil
+}
+
+func seedUsers(txDao *daos.Dao) ([]*models.Record, error) {
+	collection, err := txDao.FindCollectionByNameOrId("users")
+	if err != nil {
+		return nil, fmt.Errorf("error finding collection: %w", err)
+	}
+
+	users := make([]*models.Record, 0, len(seedManagers))
+	for _, manager := range seedManagers {
+		user := models.NewRecord(collection)
+		user.SetUsername(manager.username)
+		user.SetPassword(SeedPassword)
+		user.Set("teamID", manager.teamID)
+		user.Set("firstName", manager.firstName)
+		user.Set("lastName", manager.lastName)
+		user.Set("teamName", manager.teamName)
+		if err := txDao.SaveRecord(user); err != nil {
+			return nil, fmt.Errorf("error saving user %s: %w", manager.username, err)
+		}
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+func seedLeague(txDao *daos.Dao, users []*models.Record) error {
+	owner := users[0]
+
+	leagues, err := txDao.FindCollectionByNameOrId("leagues")
+	if err != nil {
+		return fmt.Errorf("error finding collection: %w", err)
+	}
+	members, err := txDao.FindCollectionByNameOrId(LeagueMembersCollection)
+	if err != nil {
+		return fmt.Errorf("error finding collection: %w", err)
+	}
+
+	for i, user := range users {
+		manager := seedManagers[i]
+
+		league := models.NewRecord(leagues)
+		league.Set("leagueID", seedLeagueID)
+		league.Set("adminUserID", owner.Id)
+		league.Set("teamID", manager.teamID)
+		league.Set("leagueName", seedLeagueName)
+		league.Set("seasonStartYear", seedSeasonStartYear)
+		league.Set("userID", user.Id)
+		league.Set("isLinked", true)
+		league.Set("isActive", true)
+		league.Set("isDefault", true)
+		league.Set("hasLeft", false)
+		if err := txDao.SaveRecord(league); err != nil {
+			return fmt.Errorf("error saving league: %w", err)
+		}
+
+		member := models.NewRecord(members)
+		member.Set("leagueID", seedLeagueID)
+		member.Set("entryID", manager.teamID)
+		member.Set("entryName", manager.teamName)
+		member.Set("playerName", manager.firstName+" "+manager.lastName)
+		member.Set("userID", user.Id)
+		member.Set("hasLeft", false)
+		member.Set("lastSyncedAt", time.Now())
+		if err := txDao.SaveRecord(member); err != nil {
+			return fmt.Errorf("error saving league member: %w", err)
+		}
+	}
+
+	settingsCollection, err := txDao.FindCollectionByNameOrId("league_settings")
+	if err != nil {
+		return fmt.Errorf("error finding collection: %w", err)
+	}
+	settings := models.NewRecord(settingsCollection)
+	settings.Set("leagueID", seedLeagueID)
+	settings.Set("ownerUserID", owner.Id)
+	settings.Set("adminUserIDs", []string{owner.Id})
+	settings.Set("adminInactivityGameweeks", 3)
+	settings.Set("lastAdminActiveGameweek", seedGameweeks)
+	settings.Set("startGameweek", 1)
+	settings.Set("randomNominationEnabled", true)
+	settings.Set("randomNominationCount", 3)
+	if err := txDao.SaveRecord(settings); err != nil {
+		return fmt.Errorf("error saving league settings: %w", err)
+	}
+
+	return nil
+}
+
+// seedGameData writes the rows the ETL would normally pull from FPL. Managers
+// pick overlapping squads from a small pool of players, so one player's event
+// can hand out cards to more than one of them.
+func seedGameData(txDao *daos.Dao, users []*models.Record) error {
+	players := make([]dbx.Params, 0, seedPlayerCount)
+	for playerID := 1; playerID <= seedPlayerCount; playerID++ {
+		players = append(players, dbx.Params{
+			"playerID":        playerID,
+			"playerTeamID":    (playerID-1)%2 + 1,
+			"playerName":      fmt.Sprintf("Player %d", playerID),
+			"seasonStartYear": seedSeasonStartYear,
+		})
+	}
+	if err := UpsertRows(txDao, "players", playerConflictColumns, playerUpdateColumns, players); err != nil {
+		return err
+	}
+	teams := []types.FPLTeam{{ID: 1, Name: "Demo City", ShortName: "DMC"}, {ID: 2, Name: "Seed United", ShortName: "SDU"}}
+	if err := UpsertRows(txDao, "teams", teamConflictColumns, teamUpdateColumns, teamRows(teams, seedSeasonStartYear)); err != nil {
+		return err
+	}
+
+	// Gameweeks are a week apart and all finished
+	firstKickoff := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -7*seedGameweeks)
+	fixtures := make([]dbx.Params, 0, seedGameweeks)
+	for gameweek := 1; gameweek <= seedGameweeks; gameweek++ {
+		kickoff := firstKickoff.AddDate(0, 0, 7*(gameweek-1)).Add(15 * time.Hour)
+		fixtures = append(fixtures, dbx.Params{
+			"fixtureID":  gameweek,
+			"gameweek":   gameweek,
+			"kickoff":    kickoff.Format("2006-01-02 15:04:05.000Z"),
+			"homeTeamID": 1,
+			"awayTeamID": 2,
+		})
+	}
+	if err := UpsertRows(txDao, "fixtures", fixtureConflictColumns, fixtureUpdateColumns, fixtures); err != nil {
+		return err
+	}
+
+	results := make([]dbx.Params, 0, len(users)*seedGameweeks)
+	for i, user := range users {
+		for gameweek := 1; gameweek <= seedGameweeks; gameweek++ {
+			result := types.DatabaseResults{
+				Gameweek:    gameweek,
+				UserID:      user.Id,
+				TeamID:      seedManagers[i].teamID,
+				Points:      40 + (i*13+gameweek*7)%35,
+				Transfers:   gameweek % 2,
+				BenchPoints: (i + gameweek) % 9,
+				ActiveChip:  seedChips[[2]int{i, gameweek}],
+			}
+			picks := seedPicks(i)
+			// Everyone captains their first pick, the player seedEvents lands on
+			result.Captain, result.ViceCaptain = picks[0], picks[1]
+			multipliers := make([]int, len(picks))
+			for pos := range multipliers {
+				if pos < 11 || result.ActiveChip == ChipBenchBoost {
+					multipliers[pos] = 1
+				}
+			}
+			multipliers[0] = 2
+			if result.ActiveChip == ChipTripleCaptain {
+				multipliers[0] = 3
+			}
+			result.Multipliers = encodePicksJSON(multipliers)
+			result.AutomaticSubs = encodePicksJSON([]types.AutomaticSub{})
+			for _, pos := range []*int{
+				&result.Pos1, &result.Pos2, &result.Pos3, &result.Pos4, &result.Pos5,
+				&result.Pos6, &result.Pos7, &result.Pos8, &result.Pos9, &result.Pos10, &result.Pos11,
+				&result.Pos12, &result.Pos13, &result.Pos14, &result.Pos15,
+			} {
+				*pos, picks = picks[0], picks[1:]
+			}
+			results = append(results, resultRow(result))
+		}
+	}
+	if err := UpsertRows(txDao, "results", resultConflictColumns, resultUpdateColumns, results); err != nil {
+		return err
+	}
+
+	events := make([]dbx.Params, 0, len(seedEvents))
+	for _, event := range seedEvents {
+		playerID := seedPicks(event.manager)[0]
+		side := "h"
+		if playerID%2 == 0 {
+			side = "a"
+		}
+		events = append(events, dbx.Params{
+			"eventHash":  CreateEventHash(event.gameweek, playerID, string(event.eventType), side),
+			"fixtureID":  event.gameweek,
+			"gameweek":   event.gameweek,
+			"playerID":   playerID,
+			"eventType":  string(event.eventType),
+			"side":       side,
+			"eventValue": 1,
+		})
+	}
+	return UpsertRows(txDao, "events", eventConflictColumns, eventUpdateColumns, events)
+}
+
+// seedPicks returns manager's fifteen player IDs, starters first. Managers
+// share most of their squad with the next one along.
+func seedPicks(manager int) []int {
+	picks := make([]int, 0, 15)
+	for i := 0; i < 15; i++ {
+		picks = append(picks, (manager*5+i)%seedPlayerCount+1)
+	}
+	return picks
+}
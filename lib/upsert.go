@@ -0,0 +1,103 @@
+package lib
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/security"
+	pbtypes "github.com/pocketbase/pocketbase/tools/types"
+)
+
+// SQLite's default limit on bound parameters in one statement
+const upsertMaxParams = 999
+
+// UpsertRows writes rows into a collection's table with batched
+// INSERT ... ON CONFLICT statements instead of one SaveRecord per row.
+// conflictColumns must be covered by a unique index on the table. Rows that
+// already exist have updateColumns overwritten, and only when one of them
+// actually changed; with no updateColumns existing rows are left alone.
+//
+// Rows skip record hooks and validation, so this is only for the collections
+// the ETL owns. Every row must have the same keys.
+func UpsertRows(dao *daos.Dao, table string, conflictColumns []string, updateColumns []string, rows []dbx.Params) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	columns := make([]string, 0, len(rows[0]))
+	for column := range rows[0] {
+		columns = append(columns, column)
+	}
+	slices.Sort(columns)
+	columns = append([]string{"id"}, append(columns, "created", "updated")...)
+
+	batchSize := max(upsertMaxParams/len(columns), 1)
+	for start := 0; start < len(rows); start += batchSize {
+		end := min(start+batchSize, len(rows))
+		if err := upsertBatch(dao, table, columns, conflictColumns, updateColumns, rows[start:end]); err != nil {
+			return fmt.Errorf("error upserting %s rows %d-%d: %w", table, start, end, err)
+		}
+	}
+
+	return nil
+}
+
+func upsertBatch(dao *daos.Dao, table string, columns []string, conflictColumns []string, updateColumns []string, rows []dbx.Params) error {
+	now := pbtypes.NowDateTime().String()
+	params := dbx.Params{}
+	values := make([]string, 0, len(rows))
+
+	for i, row := range rows {
+		placeholders := make([]string, 0, len(columns))
+		for j, column := range columns {
+			name := fmt.Sprintf("p%d_%d", i, j)
+			placeholders = append(placeholders, "{:"+name+"}")
+
+			switch column {
+			case "id":
+				params[name] = security.RandomStringWithAlphabet(models.DefaultIdLength, models.DefaultIdAlphabet)
+			case "created", "updated":
+				params[name] = now
+			default:
+				value, ok := row[column]
+				if !ok {
+					return fmt.Errorf("row %d is missing column %s", i, column)
+				}
+				params[name] = value
+			}
+		}
+		values = append(values, "("+strings.Join(placeholders, ", ")+")")
+	}
+
+	var sql strings.Builder
+	fmt.Fprintf(&sql, "INSERT INTO {{%s}} (%s) VALUES %s ON CONFLICT (%s) ",
+		table, quoteColumns(columns), strings.Join(values, ", "), quoteColumns(conflictColumns))
+
+	if len(updateColumns) == 0 {
+		sql.WriteString("DO NOTHING")
+	} else {
+		sets := make([]string, 0, len(updateColumns)+1)
+		changed := make([]string, 0, len(updateColumns))
+		for _, column := range updateColumns {
+			sets = append(sets, fmt.Sprintf("[[%s]] = excluded.[[%s]]", column, column))
+			changed = append(changed, fmt.Sprintf("[[%s]] IS NOT excluded.[[%s]]", column, column))
+		}
+		sets = append(sets, "[[updated]] = excluded.[[updated]]")
+		fmt.Fprintf(&sql, "DO UPDATE SET %s WHERE %s", strings.Join(sets, ", "), strings.Join(changed, " OR "))
+	}
+
+	_, err := dao.DB().NewQuery(sql.String()).Bind(params).Execute()
+	return err
+}
+
+func quoteColumns(columns []string) string {
+	quoted := make([]string, 0, len(columns))
+	for _, column := range columns {
+		quoted = append(quoted, "[["+column+"]]")
+	}
+	return strings.Join(quoted, ", ")
+}
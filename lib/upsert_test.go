@@ -0,0 +1,90 @@
+package lib
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cmcd97/bytesize/app/types"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+// about the size of a season's bootstrap-static elements
+const benchmarkPlayers = 700
+
+func benchmarkPlayerRows() []types.Player {
+	players := make([]types.Player, 0, benchmarkPlayers)
+	for i := 1; i <= benchmarkPlayers; i++ {
+		players = append(players, types.Player{ID: i, Team: i%20 + 1, WebName: fmt.Sprintf("Player %d", i)})
+	}
+	return players
+}
+
+func clearPlayers(b *testing.B, pb *pocketbase.PocketBase) {
+	b.Helper()
+	if _, err := pb.DB().NewQuery("DELETE FROM players").Execute(); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// BenchmarkSaveRecordPlayers writes a season's players one SaveRecord at a
+// time, the way the ETL did before UpsertRows.
+func BenchmarkSaveRecordPlayers(b *testing.B) {
+	pb := newTestApp(b)
+	players := benchmarkPlayerRows()
+	collection, err := pb.Dao().FindCollectionByNameOrId("players")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		clearPlayers(b, pb)
+		b.StartTimer()
+
+		for _, player := range players {
+			record := models.NewRecord(collection)
+			record.Set("playerID", player.ID)
+			record.Set("playerTeamID", player.Team)
+			record.Set("playerName", player.WebName)
+			record.Set("seasonStartYear", 2024)
+			if err := pb.Dao().SaveRecord(record); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkUpsertRowsPlayers writes a season's players into an empty table
+func BenchmarkUpsertRowsPlayers(b *testing.B) {
+	pb := newTestApp(b)
+	rows := playerRows(benchmarkPlayerRows(), 2024)
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		clearPlayers(b, pb)
+		b.StartTimer()
+
+		if err := UpsertRows(pb.Dao(), "players", playerConflictColumns, playerUpdateColumns, rows); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkUpsertRowsPlayersUnchanged writes the same players again, as each
+// ETL run does once they're stored, with one player renamed
+func BenchmarkUpsertRowsPlayersUnchanged(b *testing.B) {
+	pb := newTestApp(b)
+	players := benchmarkPlayerRows()
+	if err := UpsertRows(pb.Dao(), "players", playerConflictColumns, playerUpdateColumns, playerRows(players, 2024)); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		players[0].WebName = fmt.Sprintf("Renamed %d", i)
+		if err := UpsertRows(pb.Dao(), "players", playerConflictColumns, playerUpdateColumns, playerRows(players, 2024)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -25,26 +25,30 @@ func ReplaceUnderscoresWithSpaces(name string) string {
 	return strings.TrimSuffix(name, "s")
 }
 
-// CreateEventHash generates a unique 32-character identifier from fixture data.
+// CreateEventHash generates a unique 32-character identifier for one player's
+// stat in a fixture. side is "h" or "a", the list FPL reported the stat in.
+// The gameweek is left out because postponed fixtures move between gameweeks.
 // Uses SHA-256 hashing with ':' as delimiter between fields.
 // Returns empty string if any inputs are invalid.
-func CreateEventHash(fixtureID, gameweek int, identifier string) string {
+func CreateEventHash(fixtureID, playerID int, identifier string, side string) string {
 	// Validate inputs
-	if fixtureID <= 0 || gameweek <= 0 || identifier == "" {
-		log.Printf("[CreateEventHash] Invalid inputs: fixtureID=%d, gameweek=%d, identifier=%s",
-			fixtureID, gameweek, identifier)
+	if fixtureID <= 0 || playerID <= 0 || identifier == "" || side == "" {
+		log.Printf("[CreateEventHash] Invalid inputs: fixtureID=%d, playerID=%d, identifier=%s, side=%s",
+			fixtureID, playerID, identifier, side)
 		return ""
 	}
 
 	const delimiter = ":"
 
 	// Concatenate fields with delimiter
-	input := fmt.Sprintf("%d%s%d%s%s",
+	input := fmt.Sprintf("%d%s%d%s%s%s%s",
 		fixtureID,
 		delimiter,
-		gameweek,
+		playerID,
 		delimiter,
 		identifier,
+		delimiter,
+		side,
 	)
 
 	// Create SHA-256 hash
@@ -54,3 +58,43 @@ func CreateEventHash(fixtureID, gameweek int, identifier string) string {
 	fullHash := hex.EncodeToString(hash[:])
 	return fullHash[:32] // Return first 32 chars for shorter hash
 }
+
+// DescribeUserAgent turns a raw User-Agent header into a short label such as
+// "Safari on iPhone" for the sessions page.
+func DescribeUserAgent(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(userAgent, "Firefox/"), strings.Contains(userAgent, "FxiOS/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome/"), strings.Contains(userAgent, "CriOS/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	}
+
+	device := "Unknown OS"
+	switch {
+	case strings.Contains(userAgent, "iPhone"):
+		device = "iPhone"
+	case strings.Contains(userAgent, "iPad"):
+		device = "iPad"
+	case strings.Contains(userAgent, "Android"):
+		device = "Android"
+	case strings.Contains(userAgent, "Mac OS X"):
+		device = "Mac"
+	case strings.Contains(userAgent, "Windows"):
+		device = "Windows"
+	case strings.Contains(userAgent, "Linux"):
+		device = "Linux"
+	}
+
+	return fmt.Sprintf("%s on %s", browser, device)
+}
@@ -2,17 +2,22 @@ package main
 
 import (
 	"log"
+	"os"
+	"strings"
 
 	"github.com/cmcd97/bytesize/app"
 	"github.com/cmcd97/bytesize/auth"
 	"github.com/cmcd97/bytesize/lib"
 	"github.com/cmcd97/bytesize/middleware"
+	_ "github.com/cmcd97/bytesize/migrations"
 	"github.com/labstack/echo/v5"
 
 	"github.com/joho/godotenv"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/plugins/migratecmd"
 	"github.com/pocketbase/pocketbase/tools/cron"
+	"github.com/spf13/cobra"
 )
 
 func main() {
@@ -23,6 +28,67 @@ func main() {
 
 	pb := pocketbase.New()
 
+	// Collections are defined in ./migrations. When running with go run, changes
+	// made in the admin UI are written back there as new migrations.
+	isGoRun := strings.HasPrefix(os.Args[0], os.TempDir())
+	migratecmd.MustRegister(pb, pb.RootCmd, migratecmd.Config{
+		TemplateLang: migratecmd.TemplateLangGo,
+		Dir:          "migrations",
+		Automigrate:  isGoRun,
+	})
+
+	pb.RootCmd.AddCommand(&cobra.Command{
+		Use:   "seed",
+		Short: "Loads a demo league into an empty database",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := lib.SeedDemoLeague(pb); err != nil {
+				log.Fatal(err)
+			}
+		},
+	})
+
+	pb.RootCmd.AddCommand(&cobra.Command{
+		Use:   "rollover",
+		Short: "Archives the finished season and moves on to the one FPL is running",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := lib.RolloverSeason(pb); err != nil {
+				log.Fatal(err)
+			}
+		},
+	})
+
+	export := lib.NewExportRequest(0, "cards", lib.ExportCSV)
+	var exportOutput string
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Writes a league's cards, standings, fines or nominations as CSV or JSON",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := export.Validate(); err != nil {
+				log.Fatal(err)
+			}
+			output := os.Stdout
+			if exportOutput != "" {
+				file, err := os.Create(exportOutput)
+				if err != nil {
+					log.Fatal(err)
+				}
+				defer file.Close()
+				output = file
+			}
+			if err := lib.ExportLeague(pb, output, export); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	exportCmd.Flags().IntVar(&export.LeagueID, "league", 0, "FPL ID of the league to export")
+	exportCmd.Flags().StringVar(&export.Dataset, "dataset", export.Dataset, "one of "+strings.Join(lib.ExportDatasets, ", "))
+	exportCmd.Flags().StringVar(&export.Format, "format", export.Format, "csv or json")
+	exportCmd.Flags().IntVar(&export.FromGameweek, "from", export.FromGameweek, "first gameweek to export")
+	exportCmd.Flags().IntVar(&export.ToGameweek, "to", export.ToGameweek, "last gameweek to export")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "file to write to instead of stdout")
+	exportCmd.MarkFlagRequired("league")
+	pb.RootCmd.AddCommand(exportCmd)
+
 	// serves static files from the provided public dir (if exists)
 	pb.OnBeforeServe().Add(func(e *core.ServeEvent) error {
 		e.Router.Static("/public", "public")
@@ -34,7 +100,7 @@ func main() {
 			}
 		})
 
-		authGroup := e.Router.Group("/auth", middleware.LoadAuthContextFromCookie(pb))
+		authGroup := e.Router.Group("/auth", middleware.LoadAuthContextFromCookie(pb), middleware.CSRF)
 		auth.RegisterLoginRoutes(e, *authGroup)
 		auth.RegisterRegisterRoutes(e, *authGroup)
 		auth.RegisterLearnRoutes(e, *authGroup)
@@ -56,6 +122,18 @@ func main() {
 			lib.CheckForPlayerUpdates(e, pb)
 		})
 
+		c.MustAdd("League Membership Sync", "0 */6 * * *", func() {
+			lib.SyncLeagueMembers(pb)
+		})
+
+		c.MustAdd("Card Reconciliation", "0 3 * * *", func() {
+			lib.ReconcileCards(pb)
+		})
+
+		c.MustAdd("Live Cards", "*/5 * * * *", func() {
+			lib.PollLiveCards(pb)
+		})
+
 		// Add cron job to run daily ETL
 		c.MustAdd("daily ETL", "0 1 * * *", func() {
 			lib.DailyDataCheck(e, pb)
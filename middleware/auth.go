@@ -1,12 +1,32 @@
 package middleware
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"time"
+
 	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	AuthCookieName       = "Auth"
+	SessionsCollection   = "sessions"
+	SessionContextKey    = "sessionID"
+	sessionTouchInterval = 5 * time.Minute
 )
 
-const AuthCookieName = "Auth"
+// SessionTokenID returns the identifier a session is stored under. Only the
+// hash of the auth token is persisted so a leaked sessions table can't be
+// replayed as cookies.
+func SessionTokenID(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
 
 func LoadAuthContextFromCookie(app core.App) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -26,7 +46,29 @@ func LoadAuthContextFromCookie(app core.App) echo.MiddlewareFunc {
 				return next(c)
 			}
 
+			// The token must belong to a session that hasn't been signed out
+			session, err := app.Dao().FindFirstRecordByFilter(
+				SessionsCollection,
+				"tokenID = {:tokenID} && userID = {:userID} && revoked = false",
+				dbx.Params{"tokenID": SessionTokenID(token), "userID": record.Id},
+			)
+			if err != nil || session == nil {
+				return next(c)
+			}
+
+			if expires := session.GetDateTime("expires"); !expires.IsZero() && expires.Time().Before(time.Now()) {
+				return next(c)
+			}
+
+			if time.Since(session.GetDateTime("lastSeen").Time()) > sessionTouchInterval {
+				session.Set("lastSeen", types.NowDateTime())
+				if err := app.Dao().SaveRecord(session); err != nil {
+					log.Printf("Failed to update session %s last seen: %v", session.Id, err)
+				}
+			}
+
 			c.Set(apis.ContextAuthRecordKey, record)
+			c.Set(SessionContextKey, session.Id)
 			return next(c)
 		}
 	}
@@ -0,0 +1,173 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+	pbtypes "github.com/pocketbase/pocketbase/tools/types"
+)
+
+func resultsFields() []*schema.SchemaField {
+	fields := []*schema.SchemaField{
+		numberField("gameweek"),
+		textField("userID"),
+		numberField("teamID"),
+		numberField("points"),
+		numberField("transfers"),
+		numberField("hits"),
+		numberField("benchPoints"),
+		textField("activeChip"),
+	}
+	for pos := 1; pos <= 15; pos++ {
+		fields = append(fields, numberField(fmt.Sprintf("pos_%d", pos)))
+	}
+	return fields
+}
+
+// The collections the app started out with, which used to be set up by hand
+// in the admin UI. The ones added since have their own migrations, which run
+// after this one. The app renders everything on the server through the DAO,
+// so apart from users reading their own record all API rules are left admin
+// only. Users can't update themselves through the API because that would skip
+// the team ownership check.
+var collectionSpecs = []collectionSpec{
+	{
+		name: "users",
+		auth: true,
+		fields: []*schema.SchemaField{
+			numberField("teamID"),
+			textField("firstName"),
+			textField("lastName"),
+			textField("teamName"),
+			boolField("hasReverse"),
+		},
+		indexes:  []string{collectionIndex("users", false, "idx_users_team", "teamID")},
+		listRule: pbtypes.Pointer("id = @request.auth.id"),
+		viewRule: pbtypes.Pointer("id = @request.auth.id"),
+	},
+	{
+		name: "leagues",
+		fields: []*schema.SchemaField{
+			numberField("leagueID"),
+			textField("adminUserID"),
+			numberField("teamID"),
+			textField("leagueName"),
+			numberField("seasonStartYear"),
+			textField("userID"),
+			boolField("isLinked"),
+			boolField("isActive"),
+			boolField("isDefault"),
+		},
+		indexes: []string{
+			collectionIndex("leagues", false, "idx_leagues_user", "userID"),
+			collectionIndex("leagues", false, "idx_leagues_league", "leagueID"),
+			collectionIndex("leagues", false, "idx_leagues_team", "teamID"),
+		},
+	},
+	{
+		name: "players",
+		fields: []*schema.SchemaField{
+			numberField("playerID"),
+			numberField("playerTeamID"),
+			textField("playerName"),
+			numberField("seasonStartYear"),
+		},
+	},
+	{
+		name: "fixtures",
+		fields: []*schema.SchemaField{
+			numberField("fixtureID"),
+			numberField("gameweek"),
+			dateField("kickoff"),
+			numberField("homeTeamID"),
+			numberField("awayTeamID"),
+		},
+	},
+	{
+		name: "events",
+		fields: []*schema.SchemaField{
+			textField("eventHash"),
+			numberField("fixtureID"),
+			numberField("gameweek"),
+			numberField("playerID"),
+			textField("eventType"),
+			numberField("eventValue"),
+		},
+		indexes: []string{collectionIndex("events", false, "idx_events_player_gameweek", "playerID", "gameweek")},
+	},
+	{
+		name:   "results",
+		fields: resultsFields(),
+		indexes: []string{
+			collectionIndex("results", false, "idx_results_user", "userID"),
+		},
+	},
+	{
+		name: "cards",
+		fields: []*schema.SchemaField{
+			numberField("teamID"),
+			textField("userID"),
+			numberField("nominatorTeamID"),
+			textField("nominatorUserID"),
+			numberField("gameweek"),
+			boolField("isCompleted"),
+			boolField("adminVerified"),
+			textField("type"),
+			numberField("leagueID"),
+			textField("cardHash"),
+		},
+		indexes: []string{
+			collectionIndex("cards", false, "idx_cards_user", "userID"),
+			collectionIndex("cards", false, "idx_cards_league_gameweek", "leagueID", "gameweek"),
+		},
+	},
+	{
+		name: "aggregated_results",
+		fields: []*schema.SchemaField{
+			numberField("gameweek"),
+			numberField("teamID"),
+			textField("userID"),
+			numberField("points"),
+			numberField("totalPoints"),
+			boolField("isSuspendedNext"),
+		},
+		indexes: []string{collectionIndex("aggregated_results", false, "idx_aggregated_results_user_gameweek", "userID", "gameweek")},
+	},
+}
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		for _, spec := range collectionSpecs {
+			if err := saveCollectionSpec(dao, spec); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		// users belongs to PocketBase's own init migration
+		for i := len(collectionSpecs) - 1; i >= 0; i-- {
+			spec := collectionSpecs[i]
+			if spec.auth {
+				continue
+			}
+
+			collection, err := dao.FindCollectionByNameOrId(spec.name)
+			if err != nil {
+				continue
+			}
+			if err := dao.DeleteCollection(collection); err != nil {
+				return fmt.Errorf("delete %s: %w", spec.name, err)
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,46 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+var sessionsSpec = collectionSpec{
+	name: "sessions",
+	fields: []*schema.SchemaField{
+		textField("userID"),
+		textField("tokenID"),
+		textField("userAgent"),
+		textField("ipAddress"),
+		dateField("lastSeen"),
+		dateField("expires"),
+		boolField("revoked"),
+	},
+	indexes: []string{
+		collectionIndex("sessions", true, "idx_sessions_token", "tokenID"),
+		collectionIndex("sessions", false, "idx_sessions_user", "userID"),
+	},
+}
+
+// Every sign-in is recorded as a session, which the auth middleware checks the
+// cookie's token against so a session can be signed out remotely.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		return saveCollectionSpec(daos.New(db), sessionsSpec)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		collection, err := dao.FindCollectionByNameOrId(sessionsSpec.name)
+		if err != nil {
+			return nil
+		}
+		if err := dao.DeleteCollection(collection); err != nil {
+			return fmt.Errorf("delete %s: %w", sessionsSpec.name, err)
+		}
+		return nil
+	})
+}
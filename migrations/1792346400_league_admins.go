@@ -0,0 +1,60 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+var leagueSettingsSpec = collectionSpec{
+	name: "league_settings",
+	fields: []*schema.SchemaField{
+		numberField("leagueID"),
+		textField("ownerUserID"),
+		jsonField("adminUserIDs"),
+		numberField("adminInactivityGameweeks"),
+		numberField("lastAdminActiveGameweek"),
+	},
+	indexes: []string{collectionIndex("league_settings", true, "idx_league_settings_league", "leagueID")},
+}
+
+var adminNominationsSpec = collectionSpec{
+	name: "admin_nominations",
+	fields: []*schema.SchemaField{
+		numberField("leagueID"),
+		textField("nominatorUserID"),
+		textField("nomineeUserID"),
+		numberField("gameweek"),
+	},
+	indexes: []string{collectionIndex("admin_nominations", false, "idx_admin_nominations_league", "leagueID")},
+}
+
+// Each league keeps its owner and co-admins in league_settings, which is
+// created the first time the league is looked at. Members nominate a new
+// owner in admin_nominations once the admins have gone quiet.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		if err := saveCollectionSpec(dao, leagueSettingsSpec); err != nil {
+			return err
+		}
+		return saveCollectionSpec(dao, adminNominationsSpec)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		for _, name := range []string{adminNominationsSpec.name, leagueSettingsSpec.name} {
+			collection, err := dao.FindCollectionByNameOrId(name)
+			if err != nil {
+				continue
+			}
+			if err := dao.DeleteCollection(collection); err != nil {
+				return fmt.Errorf("delete %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,55 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+var leagueSettingsVersionsSpec = collectionSpec{
+	name: "league_settings_versions",
+	fields: []*schema.SchemaField{
+		numberField("leagueID"),
+		numberField("version"),
+		textField("changedByUserID"),
+		numberField("gameweek"),
+		jsonField("changes"),
+	},
+	indexes: []string{collectionIndex("league_settings_versions", false, "idx_league_settings_versions_league", "leagueID", "version")},
+}
+
+// Admins can rename their league, pick the gameweek it starts from and set
+// its nomination and fine rules. Every change bumps the settings' version and
+// records what changed in league_settings_versions.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		err := addFields(dao, "league_settings",
+			textField("displayName"),
+			numberField("startGameweek"),
+			boolField("randomNominationEnabled"),
+			numberField("randomNominationCount"),
+			textField("fineDescription"),
+			numberField("version"),
+		)
+		if err != nil {
+			return err
+		}
+		return saveCollectionSpec(dao, leagueSettingsVersionsSpec)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		collection, err := dao.FindCollectionByNameOrId(leagueSettingsVersionsSpec.name)
+		if err == nil {
+			if err := dao.DeleteCollection(collection); err != nil {
+				return fmt.Errorf("delete %s: %w", leagueSettingsVersionsSpec.name, err)
+			}
+		}
+		return removeFields(dao, "league_settings",
+			"displayName", "startGameweek", "randomNominationEnabled", "randomNominationCount", "fineDescription", "version")
+	})
+}
@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+var leagueMembersSpec = collectionSpec{
+	name: "league_members",
+	fields: []*schema.SchemaField{
+		numberField("leagueID"),
+		numberField("entryID"),
+		textField("entryName"),
+		textField("playerName"),
+		textField("userID"),
+		boolField("hasLeft"),
+		dateField("lastSyncedAt"),
+	},
+	indexes: []string{collectionIndex("league_members", true, "idx_league_members_league_entry", "leagueID", "entryID")},
+}
+
+// league_members mirrors each league's FPL classic standings, and a leagues
+// row is marked hasLeft once its team drops out of them.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		if err := saveCollectionSpec(dao, leagueMembersSpec); err != nil {
+			return err
+		}
+		return addFields(dao, "leagues", boolField("hasLeft"))
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		collection, err := dao.FindCollectionByNameOrId(leagueMembersSpec.name)
+		if err == nil {
+			if err := dao.DeleteCollection(collection); err != nil {
+				return fmt.Errorf("delete %s: %w", leagueMembersSpec.name, err)
+			}
+		}
+		return removeFields(dao, "leagues", "hasLeft")
+	})
+}
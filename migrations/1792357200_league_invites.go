@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+var leagueInvitesSpec = collectionSpec{
+	name: "league_invites",
+	fields: []*schema.SchemaField{
+		numberField("leagueID"),
+		textField("code"),
+		textField("createdByUserID"),
+		numberField("entryID"),
+		dateField("expires"),
+		numberField("maxUses"),
+		numberField("uses"),
+		boolField("revoked"),
+	},
+	indexes: []string{collectionIndex("league_invites", true, "idx_league_invites_code", "code")},
+}
+
+// Invite links admins share, looked up by their code when someone signs up
+// through one.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		return saveCollectionSpec(daos.New(db), leagueInvitesSpec)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		collection, err := dao.FindCollectionByNameOrId(leagueInvitesSpec.name)
+		if err != nil {
+			return nil
+		}
+		if err := dao.DeleteCollection(collection); err != nil {
+			return fmt.Errorf("delete %s: %w", leagueInvitesSpec.name, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+var onboardingDraftsSpec = collectionSpec{
+	name: "onboarding_drafts",
+	fields: []*schema.SchemaField{
+		textField("userID"),
+		numberField("teamID"),
+		textField("firstName"),
+		textField("lastName"),
+		textField("teamName"),
+		jsonField("leagues"),
+		dateField("expires"),
+	},
+	indexes: []string{collectionIndex("onboarding_drafts", true, "idx_onboarding_drafts_user", "userID")},
+}
+
+// The team and leagues a user picked while signing up are kept here between
+// the onboarding steps, instead of in a cookie.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		return saveCollectionSpec(daos.New(db), onboardingDraftsSpec)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		collection, err := dao.FindCollectionByNameOrId(onboardingDraftsSpec.name)
+		if err != nil {
+			return nil
+		}
+		if err := dao.DeleteCollection(collection); err != nil {
+			return fmt.Errorf("delete %s: %w", onboardingDraftsSpec.name, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,62 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+var auditLogsSpec = collectionSpec{
+	name: "audit_logs",
+	fields: []*schema.SchemaField{
+		textField("actorUserID"),
+		textField("action"),
+		textField("subjectUserID"),
+		jsonField("details"),
+	},
+	indexes: []string{collectionIndex("audit_logs", false, "idx_audit_logs_subject", "subjectUserID")},
+}
+
+var teamRelinksSpec = collectionSpec{
+	name: "team_relinks",
+	fields: []*schema.SchemaField{
+		textField("userID"),
+		numberField("oldTeamID"),
+		numberField("newTeamID"),
+		textField("newTeamName"),
+		textField("firstName"),
+		textField("lastName"),
+		textField("status"),
+		jsonField("pendingLeagueIDs"),
+	},
+	indexes: []string{collectionIndex("team_relinks", false, "idx_team_relinks_user_status", "userID", "status")},
+}
+
+// Moving to a new FPL team is a team_relinks request that waits on the
+// admins of the user's leagues, and every step of it goes in audit_logs.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		if err := saveCollectionSpec(dao, auditLogsSpec); err != nil {
+			return err
+		}
+		return saveCollectionSpec(dao, teamRelinksSpec)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		for _, name := range []string{teamRelinksSpec.name, auditLogsSpec.name} {
+			collection, err := dao.FindCollectionByNameOrId(name)
+			if err != nil {
+				continue
+			}
+			if err := dao.DeleteCollection(collection); err != nil {
+				return fmt.Errorf("delete %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,60 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+var ownershipChallengesSpec = collectionSpec{
+	name: "ownership_challenges",
+	fields: []*schema.SchemaField{
+		textField("userID"),
+		numberField("teamID"),
+		textField("code"),
+		dateField("expires"),
+	},
+	indexes: []string{collectionIndex("ownership_challenges", false, "idx_ownership_challenges_user_team", "userID", "teamID")},
+}
+
+var ownershipOverridesSpec = collectionSpec{
+	name: "ownership_overrides",
+	fields: []*schema.SchemaField{
+		numberField("entryID"),
+		numberField("leagueID"),
+		textField("grantedByUserID"),
+		textField("usedByUserID"),
+		boolField("used"),
+		dateField("expires"),
+	},
+	indexes: []string{collectionIndex("ownership_overrides", false, "idx_ownership_overrides_entry", "entryID")},
+}
+
+// Linking a team needs the code from ownership_challenges in the team's name,
+// unless a league admin vouched for the team in ownership_overrides.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		if err := saveCollectionSpec(dao, ownershipChallengesSpec); err != nil {
+			return err
+		}
+		return saveCollectionSpec(dao, ownershipOverridesSpec)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		for _, name := range []string{ownershipOverridesSpec.name, ownershipChallengesSpec.name} {
+			collection, err := dao.FindCollectionByNameOrId(name)
+			if err != nil {
+				continue
+			}
+			if err := dao.DeleteCollection(collection); err != nil {
+				return fmt.Errorf("delete %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+var backfillJobsSpec = collectionSpec{
+	name: "backfill_jobs",
+	fields: []*schema.SchemaField{
+		textField("userID"),
+		numberField("teamID"),
+		textField("status"),
+		numberField("total"),
+		numberField("completed"),
+		jsonField("failedGameweeks"),
+	},
+	indexes: []string{collectionIndex("backfill_jobs", false, "idx_backfill_jobs_user_status", "userID", "status")},
+}
+
+// A background job per team whose past gameweeks are being imported, with
+// its progress and the gameweeks that still need a retry.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		return saveCollectionSpec(daos.New(db), backfillJobsSpec)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		collection, err := dao.FindCollectionByNameOrId(backfillJobsSpec.name)
+		if err != nil {
+			return nil
+		}
+		if err := dao.DeleteCollection(collection); err != nil {
+			return fmt.Errorf("delete %s: %w", backfillJobsSpec.name, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,84 @@
+package migrations
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+type uniqueIndex struct {
+	collection string
+	name       string
+	columns    []string
+}
+
+func (index uniqueIndex) sql() string {
+	return fmt.Sprintf("CREATE UNIQUE INDEX `%s` ON `%s` (`%s`)",
+		index.name, index.collection, strings.Join(index.columns, "`, `"))
+}
+
+// The natural keys lib.UpsertRows resolves conflicts on
+var etlUniqueIndexes = []uniqueIndex{
+	{"players", "idx_players_player_season", []string{"playerID", "seasonStartYear"}},
+	{"fixtures", "idx_fixtures_fixture", []string{"fixtureID"}},
+	{"events", "idx_events_hash_player", []string{"eventHash", "playerID"}},
+	{"results", "idx_results_gameweek_user_team", []string{"gameweek", "userID", "teamID"}},
+	{"cards", "idx_cards_hash", []string{"cardHash"}},
+}
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		for _, index := range etlUniqueIndexes {
+			collection, err := dao.FindCollectionByNameOrId(index.collection)
+			if err != nil {
+				return fmt.Errorf("find %s: %w", index.collection, err)
+			}
+			if slices.ContainsFunc(collection.Indexes, func(existing string) bool {
+				return strings.Contains(existing, "`"+index.name+"`")
+			}) {
+				continue
+			}
+
+			// Earlier imports could write the same row twice. Keep the oldest
+			// copy so the index can be built.
+			_, err = db.NewQuery(fmt.Sprintf(
+				"DELETE FROM {{%s}} WHERE rowid NOT IN (SELECT MIN(rowid) FROM {{%s}} GROUP BY [[%s]])",
+				index.collection, index.collection, strings.Join(index.columns, "]], [["),
+			)).Execute()
+			if err != nil {
+				return fmt.Errorf("dedupe %s: %w", index.collection, err)
+			}
+
+			collection.Indexes = append(collection.Indexes, index.sql())
+			if err := dao.SaveCollection(collection); err != nil {
+				return fmt.Errorf("index %s: %w", index.collection, err)
+			}
+		}
+
+		return nil
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		for _, index := range etlUniqueIndexes {
+			collection, err := dao.FindCollectionByNameOrId(index.collection)
+			if err != nil {
+				return fmt.Errorf("find %s: %w", index.collection, err)
+			}
+
+			collection.Indexes = slices.DeleteFunc(collection.Indexes, func(existing string) bool {
+				return strings.Contains(existing, "`"+index.name+"`")
+			})
+			if err := dao.SaveCollection(collection); err != nil {
+				return fmt.Errorf("drop index %s: %w", index.collection, err)
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,93 @@
+package migrations
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+func withoutIndex(indexes []string, name string) []string {
+	return slices.DeleteFunc(indexes, func(existing string) bool {
+		return strings.Contains(existing, "`"+name+"`")
+	})
+}
+
+// Events are identified by fixture, player, stat and side rather than by
+// fixture, gameweek and stat, and cards remember the event that earned them.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		events, err := dao.FindCollectionByNameOrId("events")
+		if err != nil {
+			return fmt.Errorf("find events: %w", err)
+		}
+
+		// Old hashes can't be rebuilt without the side FPL reported the stat
+		// in. The events are imported again on the next start, and card hashes
+		// don't depend on them, so no card is lost or duplicated.
+		if _, err := db.NewQuery("DELETE FROM {{events}}").Execute(); err != nil {
+			return fmt.Errorf("clear events: %w", err)
+		}
+
+		if events.Schema.GetFieldByName("side") == nil {
+			events.Schema.AddField(textField("side"))
+		}
+		events.Indexes = withoutIndex(events.Indexes, "idx_events_hash_player")
+		events.Indexes = append(withoutIndex(events.Indexes, "idx_events_hash"),
+			collectionIndex("events", true, "idx_events_hash", "eventHash"))
+		if err := dao.SaveCollection(events); err != nil {
+			return fmt.Errorf("save events: %w", err)
+		}
+
+		cards, err := dao.FindCollectionByNameOrId("cards")
+		if err != nil {
+			return fmt.Errorf("find cards: %w", err)
+		}
+		for _, field := range []*schema.SchemaField{
+			textField("eventHash"),
+			boolField("voided"),
+			textField("voidReason"),
+		} {
+			if cards.Schema.GetFieldByName(field.Name) == nil {
+				cards.Schema.AddField(field)
+			}
+		}
+		return dao.SaveCollection(cards)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		if _, err := db.NewQuery("DELETE FROM {{events}}").Execute(); err != nil {
+			return fmt.Errorf("clear events: %w", err)
+		}
+
+		events, err := dao.FindCollectionByNameOrId("events")
+		if err != nil {
+			return fmt.Errorf("find events: %w", err)
+		}
+		if field := events.Schema.GetFieldByName("side"); field != nil {
+			events.Schema.RemoveField(field.Id)
+		}
+		events.Indexes = append(withoutIndex(events.Indexes, "idx_events_hash"),
+			collectionIndex("events", true, "idx_events_hash_player", "eventHash", "playerID"))
+		if err := dao.SaveCollection(events); err != nil {
+			return fmt.Errorf("save events: %w", err)
+		}
+
+		cards, err := dao.FindCollectionByNameOrId("cards")
+		if err != nil {
+			return fmt.Errorf("find cards: %w", err)
+		}
+		for _, name := range []string{"eventHash", "voided", "voidReason"} {
+			if field := cards.Schema.GetFieldByName(name); field != nil {
+				cards.Schema.RemoveField(field.Id)
+			}
+		}
+		return dao.SaveCollection(cards)
+	})
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+var notificationsSpec = collectionSpec{
+	name: "notifications",
+	fields: []*schema.SchemaField{
+		textField("userID"),
+		textField("kind"),
+		textField("message"),
+		boolField("dismissed"),
+	},
+	indexes: []string{collectionIndex("notifications", false, "idx_notifications_user", "userID", "dismissed")},
+}
+
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		return saveCollectionSpec(daos.New(db), notificationsSpec)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		collection, err := dao.FindCollectionByNameOrId(notificationsSpec.name)
+		if err != nil {
+			return nil
+		}
+		if err := dao.DeleteCollection(collection); err != nil {
+			return fmt.Errorf("delete %s: %w", notificationsSpec.name, err)
+		}
+		return nil
+	})
+}
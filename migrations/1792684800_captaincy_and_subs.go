@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Results keep the captaincy, multipliers and automatic substitutions from the
+// picks endpoint, and leagues can choose to double cards for captains.
+// Existing results pick them up the next time their gameweek is imported.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		err := addFields(dao, "results",
+			numberField("captain"),
+			numberField("viceCaptain"),
+			jsonField("multipliers"),
+			jsonField("automaticSubs"),
+		)
+		if err != nil {
+			return err
+		}
+		return addFields(dao, "league_settings", boolField("captainCardsDoubled"))
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		if err := removeFields(dao, "results", "captain", "viceCaptain", "multipliers", "automaticSubs"); err != nil {
+			return err
+		}
+		return removeFields(dao, "league_settings", "captainCardsDoubled")
+	})
+}
@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Leagues can let Bench Boost card the whole squad and Triple Captain triple
+// the captain's cards.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		return addFields(daos.New(db), "league_settings",
+			boolField("benchBoostCardsAll"),
+			boolField("tripleCaptainCardsTripled"),
+		)
+	}, func(db dbx.Builder) error {
+		return removeFields(daos.New(db), "league_settings", "benchBoostCardsAll", "tripleCaptainCardsTripled")
+	})
+}
@@ -0,0 +1,88 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+var seasonsSpec = collectionSpec{
+	name: "seasons",
+	fields: []*schema.SchemaField{
+		numberField("startYear"),
+		textField("name"),
+		boolField("isCurrent"),
+		dateField("archivedAt"),
+	},
+	indexes: []string{collectionIndex("seasons", true, "idx_seasons_start_year", "startYear")},
+}
+
+// The collections a season rollover moves last season's rows out of
+var archivedSources = []string{"cards", "results", "aggregated_results"}
+
+// archiveSpec mirrors a collection's fields, plus the season a row belongs to
+func archiveSpec(dao *daos.Dao, source string) (collectionSpec, error) {
+	collection, err := dao.FindCollectionByNameOrId(source)
+	if err != nil {
+		return collectionSpec{}, fmt.Errorf("find %s: %w", source, err)
+	}
+
+	name := "archived_" + source
+	spec := collectionSpec{
+		name: name,
+		indexes: []string{
+			collectionIndex(name, false, "idx_"+name+"_season_user", "seasonStartYear", "userID"),
+		},
+	}
+	for _, field := range collection.Schema.Fields() {
+		spec.fields = append(spec.fields, &schema.SchemaField{Name: field.Name, Type: field.Type, Options: field.Options})
+	}
+	spec.fields = append(spec.fields, numberField("seasonStartYear"))
+	return spec, nil
+}
+
+// Seasons are recorded explicitly, rollovers archive last season's cards,
+// results and standings, and leagues confirm they're carrying on into a new
+// season.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		if err := saveCollectionSpec(dao, seasonsSpec); err != nil {
+			return err
+		}
+		for _, source := range archivedSources {
+			spec, err := archiveSpec(dao, source)
+			if err != nil {
+				return err
+			}
+			if err := saveCollectionSpec(dao, spec); err != nil {
+				return err
+			}
+		}
+		return addFields(dao, "league_settings", boolField("seasonConfirmationPending"))
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		if err := removeFields(dao, "league_settings", "seasonConfirmationPending"); err != nil {
+			return err
+		}
+		names := []string{seasonsSpec.name}
+		for _, source := range archivedSources {
+			names = append(names, "archived_"+source)
+		}
+		for _, name := range names {
+			collection, err := dao.FindCollectionByNameOrId(name)
+			if err != nil {
+				continue
+			}
+			if err := dao.DeleteCollection(collection); err != nil {
+				return fmt.Errorf("delete %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+}
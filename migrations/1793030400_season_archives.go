@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+var seasonArchivesSpec = collectionSpec{
+	name: "season_archives",
+	fields: []*schema.SchemaField{
+		numberField("seasonStartYear"),
+		numberField("leagueID"),
+		textField("leagueName"),
+		jsonField("summary"),
+	},
+	indexes: []string{
+		collectionIndex("season_archives", true, "idx_season_archives_season_league", "seasonStartYear", "leagueID"),
+	},
+}
+
+// Rollovers keep a summary of how every league's season finished, the
+// archive and hall of fame pages are read from it.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		return saveCollectionSpec(daos.New(db), seasonArchivesSpec)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		collection, err := dao.FindCollectionByNameOrId(seasonArchivesSpec.name)
+		if err != nil {
+			return nil
+		}
+		if err := dao.DeleteCollection(collection); err != nil {
+			return fmt.Errorf("delete %s: %w", seasonArchivesSpec.name, err)
+		}
+		return nil
+	})
+}
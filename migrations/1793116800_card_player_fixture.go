@@ -0,0 +1,62 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+var teamsSpec = collectionSpec{
+	name: "teams",
+	fields: []*schema.SchemaField{
+		numberField("teamID"),
+		textField("name"),
+		textField("shortName"),
+		numberField("seasonStartYear"),
+	},
+	indexes: []string{collectionIndex("teams", true, "idx_teams_team_season", "teamID", "seasonStartYear")},
+}
+
+// Cards earned from match stats keep the player and fixture behind them, and
+// the Premier League teams are stored so a card can say who played whom.
+// Existing cards are filled in from their event where it's still stored, the
+// ETL fills in the rest the next time it derives them.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		if err := saveCollectionSpec(dao, teamsSpec); err != nil {
+			return err
+		}
+		if err := addFields(dao, "cards", numberField("playerID"), numberField("fixtureID")); err != nil {
+			return err
+		}
+
+		_, err := db.NewQuery(`
+UPDATE {{cards}} SET
+    [[playerID]] = (SELECT e.playerID FROM {{events}} e WHERE e.eventHash = cards.eventHash),
+    [[fixtureID]] = (SELECT e.fixtureID FROM {{events}} e WHERE e.eventHash = cards.eventHash)
+WHERE EXISTS (SELECT 1 FROM {{events}} e WHERE e.eventHash = cards.eventHash)`).Execute()
+		if err != nil {
+			return fmt.Errorf("fill in card players: %w", err)
+		}
+		return nil
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		if err := removeFields(dao, "cards", "playerID", "fixtureID"); err != nil {
+			return err
+		}
+		collection, err := dao.FindCollectionByNameOrId(teamsSpec.name)
+		if err != nil {
+			return nil
+		}
+		if err := dao.DeleteCollection(collection); err != nil {
+			return fmt.Errorf("delete %s: %w", teamsSpec.name, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+var provisionalCardsSpec = collectionSpec{
+	name: "provisional_cards",
+	fields: []*schema.SchemaField{
+		numberField("leagueID"),
+		textField("userID"),
+		numberField("teamID"),
+		numberField("gameweek"),
+		textField("type"),
+		textField("cardHash"),
+		textField("eventHash"),
+		numberField("playerID"),
+		numberField("fixtureID"),
+		boolField("fixtureFinished"),
+	},
+	indexes: []string{
+		collectionIndex("provisional_cards", true, "idx_provisional_cards_hash", "cardHash"),
+		collectionIndex("provisional_cards", false, "idx_provisional_cards_league_gameweek", "leagueID", "gameweek"),
+	},
+}
+
+// Cards the live match stats point to while a gameweek is being played. They
+// share their cardHash with the card they'll become, and are removed once a
+// manager's real cards for the gameweek are issued.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		return saveCollectionSpec(daos.New(db), provisionalCardsSpec)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		collection, err := dao.FindCollectionByNameOrId(provisionalCardsSpec.name)
+		if err != nil {
+			return nil
+		}
+		if err := dao.DeleteCollection(collection); err != nil {
+			return fmt.Errorf("delete %s: %w", provisionalCardsSpec.name, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+var gameweekRecapsSpec = collectionSpec{
+	name: "gameweek_recaps",
+	fields: []*schema.SchemaField{
+		numberField("seasonStartYear"),
+		numberField("leagueID"),
+		numberField("gameweek"),
+		jsonField("summary"),
+	},
+	indexes: []string{
+		collectionIndex("gameweek_recaps", true, "idx_gameweek_recaps_season_league_gameweek", "seasonStartYear", "leagueID", "gameweek"),
+	},
+}
+
+// Each league's recap of a gameweek is built once its results are in, for
+// the recap page, its Markdown and its image.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		return saveCollectionSpec(daos.New(db), gameweekRecapsSpec)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		collection, err := dao.FindCollectionByNameOrId(gameweekRecapsSpec.name)
+		if err != nil {
+			return nil
+		}
+		if err := dao.DeleteCollection(collection); err != nil {
+			return fmt.Errorf("delete %s: %w", gameweekRecapsSpec.name, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,21 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Cards record the admin who approved their fine and when, for the fine
+// approvals export. Fines approved before this can't be told apart from cards
+// cleared by a suspension.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		return addFields(daos.New(db), "cards",
+			textField("fineApprovedBy"),
+			dateField("fineApprovedAt"),
+		)
+	}, func(db dbx.Builder) error {
+		return removeFields(daos.New(db), "cards", "fineApprovedBy", "fineApprovedAt")
+	})
+}
@@ -0,0 +1,88 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+var fineLedgerSpec = collectionSpec{
+	name: "fine_ledger",
+	fields: []*schema.SchemaField{
+		numberField("leagueID"),
+		numberField("seasonStartYear"),
+		textField("userID"),
+		textField("kind"),
+		numberField("amount"),
+		textField("cardHash"),
+		textField("cardType"),
+		numberField("gameweek"),
+		textField("note"),
+		textField("createdBy"),
+		textField("settlementID"),
+		boolField("cancelled"),
+	},
+	indexes: []string{
+		collectionIndex("fine_ledger", false, "idx_fine_ledger_league_season_user", "leagueID", "seasonStartYear", "userID"),
+		collectionIndex("fine_ledger", false, "idx_fine_ledger_card", "cardHash"),
+	},
+}
+
+var fineSettlementsSpec = collectionSpec{
+	name: "fine_settlements",
+	fields: []*schema.SchemaField{
+		numberField("leagueID"),
+		numberField("seasonStartYear"),
+		numberField("amount"),
+		textField("currency"),
+		numberField("payments"),
+		textField("note"),
+		textField("settledBy"),
+	},
+	indexes: []string{
+		collectionIndex("fine_settlements", false, "idx_fine_settlements_league_season", "leagueID", "seasonStartYear"),
+	},
+}
+
+// Leagues can put money on their fines: an amount per card type in the
+// league's currency, plus a surcharge for each card a member has already been
+// charged for. fine_ledger keeps what each member has been charged, paid and
+// had waived, in the currency's minor units, and fine_settlements the pots
+// admins have paid out.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		err := addFields(dao, "league_settings",
+			boolField("finesEnabled"),
+			dateField("finesEnabledAt"),
+			textField("fineCurrency"),
+			jsonField("fineAmounts"),
+			numberField("fineEscalation"),
+		)
+		if err != nil {
+			return err
+		}
+		if err := saveCollectionSpec(dao, fineLedgerSpec); err != nil {
+			return err
+		}
+		return saveCollectionSpec(dao, fineSettlementsSpec)
+	}, func(db dbx.Builder) error {
+		dao := daos.New(db)
+
+		for _, name := range []string{fineSettlementsSpec.name, fineLedgerSpec.name} {
+			collection, err := dao.FindCollectionByNameOrId(name)
+			if err != nil {
+				continue
+			}
+			if err := dao.DeleteCollection(collection); err != nil {
+				return fmt.Errorf("delete %s: %w", name, err)
+			}
+		}
+		return removeFields(dao, "league_settings",
+			"finesEnabled", "finesEnabledAt", "fineCurrency", "fineAmounts", "fineEscalation")
+	})
+}
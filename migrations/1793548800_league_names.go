@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Leagues imported before the onboarding draft was kept on the server had the
+// spaces in their name swapped for underscores, to fit in a cookie. Every row
+// stored until now went through that, so they're decoded once here and the
+// name FPL gives is stored as is from now on.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		_, err := db.NewQuery(`UPDATE {{leagues}} SET [[leagueName]] = REPLACE([[leagueName]], '_', ' ')`).Execute()
+		if err != nil {
+			return fmt.Errorf("decode league names: %w", err)
+		}
+		return nil
+	}, nil)
+}
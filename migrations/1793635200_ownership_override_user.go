@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// An admin's vouch for a team is tied to the user they vouched for, so nobody
+// else can link the team with it. Unused overrides from before aren't tied to
+// anyone and can't be used, the admin has to vouch again.
+func init() {
+	m.Register(func(db dbx.Builder) error {
+		return addFields(daos.New(db), "ownership_overrides", textField("userID"))
+	}, func(db dbx.Builder) error {
+		return removeFields(daos.New(db), "ownership_overrides", "userID")
+	})
+}
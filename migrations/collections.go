@@ -0,0 +1,132 @@
+package migrations
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+// collectionSpec is a collection as the app expects it. API rules are left
+// admin only unless a rule is given, the app renders everything on the server
+// through the DAO.
+type collectionSpec struct {
+	name     string
+	auth     bool
+	fields   []*schema.SchemaField
+	indexes  []string
+	listRule *string
+	viewRule *string
+}
+
+func textField(name string) *schema.SchemaField {
+	return &schema.SchemaField{Name: name, Type: schema.FieldTypeText, Options: &schema.TextOptions{}}
+}
+
+func numberField(name string) *schema.SchemaField {
+	return &schema.SchemaField{Name: name, Type: schema.FieldTypeNumber, Options: &schema.NumberOptions{}}
+}
+
+func boolField(name string) *schema.SchemaField {
+	return &schema.SchemaField{Name: name, Type: schema.FieldTypeBool, Options: &schema.BoolOptions{}}
+}
+
+func dateField(name string) *schema.SchemaField {
+	return &schema.SchemaField{Name: name, Type: schema.FieldTypeDate, Options: &schema.DateOptions{}}
+}
+
+func jsonField(name string) *schema.SchemaField {
+	return &schema.SchemaField{Name: name, Type: schema.FieldTypeJson, Options: &schema.JsonOptions{MaxSize: 2000000}}
+}
+
+func collectionIndex(collection string, unique bool, name string, columns ...string) string {
+	kind := "INDEX"
+	if unique {
+		kind = "UNIQUE INDEX"
+	}
+	return fmt.Sprintf("CREATE %s `%s` ON `%s` (`%s`)", kind, name, collection, strings.Join(columns, "`, `"))
+}
+
+func indexName(index string) string {
+	start := strings.Index(index, "`")
+	end := strings.Index(index[start+1:], "`")
+	return index[start+1 : start+1+end]
+}
+
+// saveCollectionSpec creates spec's collection, or brings an existing one up
+// to date with it. Some databases already have the collection from setting it
+// up in the admin UI, possibly with extra fields of their own, so only what's
+// missing is added.
+func saveCollectionSpec(dao *daos.Dao, spec collectionSpec) error {
+	collection, err := dao.FindCollectionByNameOrId(spec.name)
+	if err != nil {
+		collection = &models.Collection{Name: spec.name, Type: models.CollectionTypeBase}
+		if spec.auth {
+			collection.Type = models.CollectionTypeAuth
+			collection.SetOptions(models.CollectionAuthOptions{
+				AllowUsernameAuth: true,
+				MinPasswordLength: 8,
+			})
+		}
+	}
+
+	for _, field := range spec.fields {
+		if collection.Schema.GetFieldByName(field.Name) == nil {
+			collection.Schema.AddField(field)
+		}
+	}
+	for _, index := range spec.indexes {
+		if !slices.ContainsFunc(collection.Indexes, func(existing string) bool {
+			return strings.Contains(existing, "`"+indexName(index)+"`")
+		}) {
+			collection.Indexes = append(collection.Indexes, index)
+		}
+	}
+
+	collection.ListRule = spec.listRule
+	collection.ViewRule = spec.viewRule
+	collection.CreateRule = nil
+	collection.UpdateRule = nil
+	collection.DeleteRule = nil
+
+	if err := dao.SaveCollection(collection); err != nil {
+		return fmt.Errorf("save %s: %w", spec.name, err)
+	}
+	return nil
+}
+
+// addFields adds the fields a collection doesn't have yet
+func addFields(dao *daos.Dao, name string, fields ...*schema.SchemaField) error {
+	collection, err := dao.FindCollectionByNameOrId(name)
+	if err != nil {
+		return fmt.Errorf("find %s: %w", name, err)
+	}
+	for _, field := range fields {
+		if collection.Schema.GetFieldByName(field.Name) == nil {
+			collection.Schema.AddField(field)
+		}
+	}
+	if err := dao.SaveCollection(collection); err != nil {
+		return fmt.Errorf("save %s: %w", name, err)
+	}
+	return nil
+}
+
+func removeFields(dao *daos.Dao, name string, fieldNames ...string) error {
+	collection, err := dao.FindCollectionByNameOrId(name)
+	if err != nil {
+		return fmt.Errorf("find %s: %w", name, err)
+	}
+	for _, fieldName := range fieldNames {
+		if field := collection.Schema.GetFieldByName(fieldName); field != nil {
+			collection.Schema.RemoveField(field.Id)
+		}
+	}
+	if err := dao.SaveCollection(collection); err != nil {
+		return fmt.Errorf("save %s: %w", name, err)
+	}
+	return nil
+}